@@ -2,23 +2,43 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
 	"project/internal/app-cache"
 	"project/internal/cache/rds"
+	"project/internal/clients/rss"
 	"project/internal/clients/vk"
 	"project/internal/clients/vk_api"
+	"project/internal/events"
+	"project/internal/files"
+	"project/internal/files/localfs"
+	"project/internal/files/memory"
 	"project/internal/files/minio"
+	"project/internal/filter"
+	"project/internal/media"
+	"project/internal/outbox"
+	"project/internal/retention"
 	"project/internal/server"
 	"project/internal/server/telegram"
 	"project/internal/server/telegram/channel"
 	"project/internal/server/telegram/chat"
 	"project/internal/server/telegram/group"
+	"project/internal/server/telegram/moderation"
 	"project/internal/server/telegram/sup"
 	"project/internal/server/web"
+	webmedia "project/internal/server/web/handlers/media"
+	news_gatherer "project/internal/server/web/handlers/news-gatherer"
+	"project/internal/sources"
+	"project/internal/storage"
 	"project/internal/storage/psql"
+	"project/internal/storage/sqlite"
+	"project/pkg/e"
+	"strconv"
+	"strings"
 	"syscall"
 
 	"project/internal/clients/tg_bot"
@@ -39,9 +59,21 @@ func main() {
 
 	log = logger.SetSessionName(log)
 
+	if args := flag.Args(); len(args) > 0 && args[0] == migrateCmd {
+		if err := runMigrate(context.TODO(), cfg, log, args[1:]); err != nil {
+			panic(err)
+		}
+
+		return
+	}
+
 	appCache := app_cache.New()
 
-	storage, err := psql.New(context.TODO(), cfg.Storage, cfg.MPath, log)
+	bus := events.New(log)
+
+	relay := outbox.New(bus, cfg.Outbox, log)
+
+	storage, err := newStorage(context.TODO(), cfg, bus, relay, log)
 	if err != nil {
 		panic(err)
 	}
@@ -51,7 +83,7 @@ func main() {
 		panic(err)
 	}
 
-	files, err := minio.New(context.TODO(), cfg.Files, log)
+	files, err := newFiles(context.TODO(), cfg, log)
 	if err != nil {
 		panic(err)
 	}
@@ -66,17 +98,35 @@ func main() {
 		panic(err)
 	}
 
-	vkHandler := vk.New(vkApi, storage, log)
+	filters := filter.New(storage, log)
+
+	if err := filters.Load(context.TODO()); err != nil {
+		panic(err)
+	}
+
+	registry := sources.New(log)
+
+	vkHandler, err := vk.New(vkApi, storage, filters, cfg.VkApi, log)
+	if err != nil {
+		panic(err)
+	}
+
+	registry.Register(vkHandler)
+	registry.Register(rss.New(storage, filters, cfg.Rss.PollInterval, log))
 
-	if err := server.Prepare(context.TODO(), storage, appCache, vkHandler); err != nil {
+	if err := server.Prepare(context.TODO(), storage, appCache, registry); err != nil {
 		panic(err)
 	}
 
+	moderator := moderation.New(tgBot, storage, appCache, log)
+
+	mediaStore := media.New(storage, files, cfg.Files.Private, log)
+
 	// Telegram server
 	processor := telegram.NewProcessor(
-		chat.NewHandler(tgBot, vkHandler, storage, cache, appCache, log),
-		group.NewHandler(tgBot, storage, cache, appCache, files, log),
-		channel.NewHandler(tgBot, storage, cache, appCache, files, log),
+		chat.NewHandler(tgBot, registry, filters, moderator, storage, cache, appCache, log),
+		group.NewHandler(tgBot, storage, cache, appCache, mediaStore, filters, moderator, log),
+		channel.NewHandler(tgBot, storage, cache, appCache, mediaStore, filters, moderator, log),
 		sup.NewHandler(storage, cache, appCache),
 	)
 
@@ -90,12 +140,27 @@ func main() {
 	go tgSrv.Listener(updatesCh)
 
 	// Web UI server
-	handlers := web.NewHandler(storage, log)
+	mediaLinks, err := webmedia.New(files, cfg.Files, cfg.WebServer.PublicURL, log)
+	if err != nil {
+		panic(err)
+	}
+
+	handlers := web.NewHandler(cfg.WebServer, storage, bus, mediaLinks, vkHandler.CallbackHandler, log)
 
 	webSrv := web.NewServer(cfg.WebServer, log)
 
 	go webSrv.Listener(handlers)
 
+	// Outbox relay, started after the feed subscribers
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+
+	go relay.Run(relayCtx, storage)
+
+	// Retention janitor
+	janitorCtx, stopJanitor := context.WithCancel(context.Background())
+
+	go retention.New(storage, files, cfg.Retention, log).Run(janitorCtx)
+
 	// Server shutdown
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
@@ -106,6 +171,178 @@ func main() {
 	tgSrv.Shutdown(context.TODO())
 
 	webSrv.Shutdown(context.TODO())
+
+	registry.ShutdownAll()
+
+	stopJanitor()
+
+	stopRelay()
+}
+
+// appStorage is implemented by every storage.driver
+type appStorage interface {
+	filter.Storage
+	vk.Storage
+	rss.Storage
+	server.Storage
+	moderation.Storage
+	media.Storage
+	chat.Storage
+	group.Storage
+	channel.Storage
+	sup.Storage
+	news_gatherer.Storage
+	retention.Storage
+	outbox.Storage
+}
+
+var errUnknownStorage = errors.New("unknown storage.driver")
+
+func newStorage(ctx context.Context, cfg *config.Config, bus *events.Bus, relay *outbox.Relay, log *slog.Logger) (appStorage, error) {
+	const fn = "main.newStorage"
+
+	switch cfg.Storage.Driver {
+	case "", storage.DriverPostgres:
+		db, err := psql.New(ctx, cfg.Storage, cfg.MPath, bus, relay, log)
+		if err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		return db, nil
+	case storage.DriverSQLite:
+		db, err := sqlite.New(ctx, cfg.Storage, sqliteMigrations(cfg.MPath), relay, log)
+		if err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		return db, nil
+	default:
+		return nil, e.Wrap(fn, fmt.Errorf("%w: %q", errUnknownStorage, cfg.Storage.Driver))
+	}
+}
+
+// sqliteMigrations The SQLite schema lives next to the Postgres migrations
+func sqliteMigrations(mPath string) string {
+	return strings.TrimSuffix(mPath, "/") + "/sqlite"
+}
+
+// fileStorage is implemented by every file_storage.driver
+type fileStorage interface {
+	media.Files
+	retention.Files
+	webmedia.Files
+}
+
+var errUnknownDriver = errors.New("unknown file_storage.driver")
+
+func newFiles(ctx context.Context, cfg *config.Config, log *slog.Logger) (fileStorage, error) {
+	const fn = "main.newFiles"
+
+	switch cfg.Files.Driver {
+	case "", files.DriverMinio:
+		fdb, err := minio.New(ctx, cfg.Files, log)
+		if err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		return fdb, nil
+	case files.DriverLocalFS:
+		fdb, err := localfs.New(cfg.Files, cfg.WebServer.PublicURL, log)
+		if err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		return fdb, nil
+	case files.DriverMemory:
+		return memory.New(cfg.WebServer.PublicURL, log), nil
+	default:
+		return nil, e.Wrap(fn, fmt.Errorf("%w: %q", errUnknownDriver, cfg.Files.Driver))
+	}
+}
+
+const migrateCmd = "migrate"
+
+var errMigrateUsage = errors.New("usage: migrate up [N] | down N | force VERSION | version")
+
+// runMigrate handles "migrate up [N]", "migrate down N", "migrate force VERSION" and "migrate version"
+func runMigrate(ctx context.Context, cfg *config.Config, log *slog.Logger, args []string) error {
+	const fn = "main.runMigrate"
+
+	if len(args) == 0 || len(args) > 2 {
+		return errMigrateUsage
+	}
+
+	var (
+		n   int
+		err error
+	)
+
+	switch {
+	case len(args) == 2 && args[0] == "version":
+		return errMigrateUsage
+
+	case len(args) == 2:
+		n, err = strconv.Atoi(args[1])
+		if err != nil {
+			return errMigrateUsage
+		}
+
+		// force -1 resets the version to "no migrations applied"
+		if n < 0 && (args[0] != "force" || n < -1) {
+			return errMigrateUsage
+		}
+
+	case args[0] == "down" || args[0] == "force":
+		return errMigrateUsage
+	}
+
+	var mg *storage.Migrator
+
+	switch cfg.Storage.Driver {
+	case "", storage.DriverPostgres:
+		mg, err = psql.NewMigrator(ctx, cfg.Storage, cfg.MPath, log)
+	case storage.DriverSQLite:
+		mg, err = sqlite.NewMigrator(ctx, cfg.Storage, sqliteMigrations(cfg.MPath), log)
+	default:
+		err = fmt.Errorf("%w: %q", errUnknownStorage, cfg.Storage.Driver)
+	}
+
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	defer mg.Close()
+
+	switch args[0] {
+	case "up":
+		if len(args) == 2 {
+			return mg.Steps(n)
+		}
+
+		return mg.Up()
+
+	case "down":
+		return mg.Steps(-n)
+
+	case "force":
+		return mg.Force(n)
+
+	case "version":
+		version, dirty, err := mg.Version()
+		if err != nil {
+			return err
+		}
+
+		log.Info("[OK] Migrations version",
+			slog.Uint64("version", uint64(version)),
+			slog.Bool("dirty", dirty),
+		)
+
+		return nil
+
+	default:
+		return errMigrateUsage
+	}
 }
 
 func mustGetFlags() string {
@@ -0,0 +1,185 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"project/internal/models"
+	"project/internal/pkg/logger/sl"
+	rss_parser "project/internal/pkg/rss"
+	"project/internal/sources"
+	"project/pkg/e"
+	"time"
+)
+
+const fetchTimeout = 30 * time.Second
+
+func (h *Handler) Kind() string {
+	return Kind
+}
+
+func (h *Handler) Validate(ctx context.Context, feedURL string) (sources.Info, error) {
+	const fn = "rss.Validate"
+
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	feed, err := rss_parser.ValidateFeedURL(ctx, feedURL)
+	if err != nil {
+		if errors.Is(err, rss_parser.ErrNotFeed) {
+			return sources.Info{}, e.Wrap(fn, sources.ErrSourceNotFound)
+		}
+
+		return sources.Info{}, e.Wrap(fn, err)
+	}
+
+	return toInfo(feed), nil
+}
+
+func (h *Handler) Describe(ctx context.Context) ([]sources.Info, error) {
+	const fn = "rss.Describe"
+
+	feeds, err := h.db.GetRssFeeds(ctx)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	infos := make([]sources.Info, 0, len(feeds))
+
+	for _, feed := range feeds {
+		infos = append(infos, toInfo(feed))
+	}
+
+	return infos, nil
+}
+
+func (h *Handler) Start(ctx context.Context, info sources.Info) (sources.Info, error) {
+	const fn = "rss.Start"
+
+	id, err := h.db.InsertRssFeed(ctx, toRssFeed(info))
+	if err != nil {
+		return sources.Info{}, e.Wrap(fn, err)
+	}
+
+	info.ID = id
+
+	return info, nil
+}
+
+func (h *Handler) Stop(ctx context.Context, info sources.Info) error {
+	const fn = "rss.Stop"
+
+	if err := h.db.DeleteRssFeed(ctx, info.Key); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+func (h *Handler) Listen(info sources.Info, stopCh <-chan struct{}) {
+	h.listen(toRssFeed(info), stopCh)
+}
+
+func (h *Handler) listen(feed models.RssFeed, stopCh <-chan struct{}) {
+	const fn = "rss.listen"
+
+	log := h.log.With(
+		slog.String("RSS url", feed.Url),
+		slog.String("RSS title", feed.Title),
+	)
+
+	log.Info("[RSS] Listener started")
+
+	timer := time.NewTimer(time.Nanosecond)
+	defer timer.Stop()
+
+	// GUIDs already sent to storage, keeps repeated polls from re-inserting the whole feed
+	seen := make(map[string]struct{})
+
+	// validators of the stored feed version, an unchanged feed is not downloaded again
+	var validators rss_parser.Validators
+
+	for {
+		select {
+		case <-stopCh:
+			log.Info("[RSS] Listener shutdown")
+			return
+		case <-timer.C:
+		}
+
+		timer.Reset(h.pollInterval + time.Duration(rand.Int63n(int64(h.pollInterval/10)+1)))
+
+		timeNow := time.Now()
+
+		ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+
+		items, next, err := rss_parser.Parsing(ctx, feed.Url, validators)
+
+		cancel()
+
+		if errors.Is(err, rss_parser.ErrNotModified) {
+			continue
+		}
+
+		if err != nil {
+			log.Error(fn, sl.Err(err))
+
+			continue
+		}
+
+		newItems := make([]models.RssItem, 0, len(items))
+		current := make(map[string]struct{}, len(items))
+
+		for _, item := range items {
+			current[item.GUID] = struct{}{}
+
+			if _, ok := seen[item.GUID]; ok {
+				continue
+			}
+
+			if !h.flt.Match(Kind, feed.Url, item.Title+"\n"+item.Text, len(item.Metadata) > 0) {
+				continue
+			}
+
+			item.FeedID = feed.ID
+
+			newItems = append(newItems, item)
+		}
+
+		if len(newItems) == 0 {
+			validators = next
+			continue
+		}
+
+		log.Info("[RSS] new items received",
+			slog.Int("quantity", len(newItems)),
+			slog.String("duration", time.Since(timeNow).String()),
+		)
+
+		if err := h.db.InsertRssItems(context.TODO(), newItems); err != nil {
+			log.Error(fn, sl.Err(err))
+
+			continue
+		}
+
+		seen = current
+		validators = next
+	}
+}
+
+func toInfo(feed models.RssFeed) sources.Info {
+	return sources.Info{
+		ID:   feed.ID,
+		Key:  feed.Url,
+		Name: feed.Title,
+	}
+}
+
+func toRssFeed(info sources.Info) models.RssFeed {
+	return models.RssFeed{
+		ID:    info.ID,
+		Url:   info.Key,
+		Title: info.Name,
+	}
+}
@@ -0,0 +1,192 @@
+package rss
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"project/internal/models"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type rssStorage struct {
+	mu    sync.Mutex
+	items []models.RssItem
+}
+
+func (s *rssStorage) InsertRssItems(_ context.Context, items []models.RssItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items = append(s.items, items...)
+
+	return nil
+}
+
+func (s *rssStorage) guids() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	guids := make([]string, 0, len(s.items))
+	for _, item := range s.items {
+		guids = append(guids, item.GUID)
+	}
+
+	return guids
+}
+
+func (s *rssStorage) GetRssFeeds(context.Context) ([]models.RssFeed, error)        { return nil, nil }
+func (s *rssStorage) InsertRssFeed(context.Context, models.RssFeed) (int64, error) { return 0, nil }
+func (s *rssStorage) DeleteRssFeed(context.Context, string) error                  { return nil }
+
+type passFilter struct{}
+
+func (passFilter) Match(string, string, string, bool) bool { return true }
+
+// feedServer serves the current version of a feed and counts the requests
+type feedServer struct {
+	mu          sync.Mutex
+	guids       []string
+	etag        bool
+	requests    int
+	notModified int
+}
+
+func (f *feedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests++
+
+	version := fmt.Sprintf(`"v%d"`, len(f.guids))
+
+	if f.etag {
+		if r.Header.Get("If-None-Match") == version {
+			f.notModified++
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		w.Header().Set("ETag", version)
+	}
+
+	var b strings.Builder
+
+	b.WriteString(`<?xml version="1.0"?><rss version="2.0"><channel><title>Feed</title>`)
+
+	for i, guid := range f.guids {
+		date := time.Date(2024, 1, 1, i, 0, 0, 0, time.UTC).Format(time.RFC1123Z)
+		fmt.Fprintf(&b, "<item><guid>%s</guid><title>%s</title><pubDate>%s</pubDate></item>", guid, guid, date)
+	}
+
+	b.WriteString(`</channel></rss>`)
+
+	_, _ = w.Write([]byte(b.String()))
+}
+
+func (f *feedServer) publish(guid string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.guids = append(f.guids, guid)
+}
+
+func (f *feedServer) stats() (int, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.requests, f.notModified
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestListenDedup(t *testing.T) {
+	tests := []struct {
+		name string
+		etag bool
+	}{
+		{name: "without validators"},
+		{name: "with etag", etag: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed := &feedServer{guids: []string{"a", "b"}, etag: tt.etag}
+
+			srv := httptest.NewServer(feed)
+			defer srv.Close()
+
+			db := &rssStorage{}
+
+			// A poll interval below 10ns has no jitter
+			h := New(db, passFilter{}, time.Nanosecond, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			stopCh := make(chan struct{})
+			done := make(chan struct{})
+
+			go func() {
+				defer close(done)
+				h.listen(models.RssFeed{ID: 1, Url: srv.URL}, stopCh)
+			}()
+
+			waitFor(t, func() bool {
+				requests, _ := feed.stats()
+				return requests >= 5
+			})
+
+			feed.publish("c")
+
+			waitFor(t, func() bool {
+				return len(db.guids()) >= 3
+			})
+
+			before, _ := feed.stats()
+
+			waitFor(t, func() bool {
+				requests, _ := feed.stats()
+				return requests >= before+5
+			})
+
+			close(stopCh)
+			<-done
+
+			if got := strings.Join(db.guids(), ","); got != "a,b,c" {
+				t.Errorf("inserted %s, want a,b,c", got)
+			}
+
+			for _, item := range db.items {
+				if item.FeedID != 1 {
+					t.Errorf("item %s FeedID = %d, want 1", item.GUID, item.FeedID)
+				}
+			}
+
+			requests, notModified := feed.stats()
+
+			if tt.etag && notModified < requests-2 {
+				t.Errorf("not modified = %d of %d requests, want all but 2", notModified, requests)
+			}
+
+			if !tt.etag && notModified != 0 {
+				t.Errorf("not modified = %d, want 0", notModified)
+			}
+		})
+	}
+}
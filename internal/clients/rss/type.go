@@ -0,0 +1,43 @@
+package rss
+
+import (
+	"context"
+	"log/slog"
+	"project/internal/models"
+	"time"
+)
+
+const Kind = "rss"
+
+type Handler struct {
+	db           Storage
+	flt          Filter
+	pollInterval time.Duration
+	log          *slog.Logger
+}
+
+type Filter interface {
+	Match(kind, key, text string, hasMedia bool) bool
+}
+
+type Storage interface {
+	GetRssFeeds(ctx context.Context) ([]models.RssFeed, error)
+	InsertRssFeed(ctx context.Context, feed models.RssFeed) (int64, error)
+	DeleteRssFeed(ctx context.Context, feedURL string) error
+	InsertRssItems(ctx context.Context, items []models.RssItem) error
+}
+
+const defaultPollInterval = 10 * time.Minute
+
+func New(db Storage, flt Filter, pollInterval time.Duration, log *slog.Logger) *Handler {
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+
+	return &Handler{
+		db:           db,
+		flt:          flt,
+		pollInterval: pollInterval,
+		log:          log,
+	}
+}
@@ -0,0 +1,102 @@
+package vk
+
+import (
+	"context"
+	vk_params "github.com/SevereCloud/vksdk/v3/api/params"
+	"project/internal/models"
+	"project/internal/sources"
+	"project/pkg/e"
+	"time"
+)
+
+const (
+	// backfillPageSize is the wall.get maximum
+	backfillPageSize = 100
+
+	// backfillPageDelay leaves most of the shared limiter to live listeners
+	backfillPageDelay = time.Second
+)
+
+// Backfill pages through the wall from the newest post, already stored posts are not changed
+func (h *Handler) Backfill(ctx context.Context, info sources.Info, opts sources.BackfillOptions, progress func(stored int)) (int, error) {
+	const fn = "vk.Backfill"
+
+	vkGroup := toVkGroup(info)
+
+	params := vk_params.NewWallGetBuilder()
+	params.Domain(vkGroup.Domain)
+	params.Count(backfillPageSize)
+
+	stored := 0
+	seen := 0
+
+	for offset := 0; ; offset += backfillPageSize {
+		params.Offset(offset)
+
+		posts, err := h.wallGet(ctx, params)
+		if err != nil {
+			return stored, e.Wrap(fn, err)
+		}
+
+		if len(posts.Items) == 0 {
+			return stored, nil
+		}
+
+		names := newAuthors(posts.ExtendedResponse)
+
+		msgs := make([]models.VkMessage, 0, len(posts.Items))
+		done := false
+
+		for _, post := range posts.Items {
+			createdAt := time.Unix(int64(post.Date), 0)
+
+			// The pinned post may be older than the rest of the page
+			if !opts.Since.IsZero() && createdAt.Before(opts.Since) {
+				if bool(post.IsPinned) {
+					continue
+				}
+
+				done = true
+				break
+			}
+
+			if opts.Limit > 0 && seen >= opts.Limit {
+				done = true
+				break
+			}
+
+			seen++
+
+			msg := toVkMessage(vkGroup.ID, post, names)
+
+			if !h.flt.Match(Kind, vkGroup.Domain, msg.Text, len(msg.Metadata) > 0) {
+				continue
+			}
+
+			msg.Backfilled = true
+
+			msgs = append(msgs, msg)
+		}
+
+		if len(msgs) != 0 {
+			n, err := h.db.InsertBackfillVkMessages(ctx, msgs)
+			if err != nil {
+				return stored, e.Wrap(fn, err)
+			}
+
+			stored += n
+		}
+
+		progress(stored)
+
+		if done || offset+len(posts.Items) >= posts.Count {
+			return stored, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return stored, e.Wrap(fn, ctx.Err())
+		case <-time.After(backfillPageDelay):
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package vk
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/SevereCloud/vksdk/v3/object"
+	"io"
+	"log/slog"
+	"net/http"
+	"project/internal/models"
+	"project/internal/pkg/logger/sl"
+	"project/internal/sources"
+	"project/internal/storage"
+	"project/pkg/e"
+	"time"
+)
+
+const (
+	callbackConfirmation = "confirmation"
+	callbackWallPostNew  = "wall_post_new"
+	callbackWallPostEdit = "wall_post_edit"
+	callbackWallRepost   = "wall_repost"
+
+	// callbackOK must be returned for every handled event, otherwise VK retries it
+	callbackOK = "ok"
+
+	callbackMaxBody = 1 << 20
+)
+
+// Callback is the VK Callback API settings of a group from the config
+type Callback struct {
+	// Confirmation is the string VK expects in response to the confirmation event
+	Confirmation string
+	// Secret is the secret key from the group Callback API settings, required
+	Secret string
+}
+
+type callbackEvent struct {
+	Type    string          `json:"type"`
+	GroupID int             `json:"group_id"`
+	EventID string          `json:"event_id"`
+	Secret  string          `json:"secret"`
+	Object  json.RawMessage `json:"object"`
+}
+
+var (
+	ErrUnknownCallbackGroup = errors.New("group is not in callback mode")
+	ErrBadCallbackSecret    = errors.New("bad callback secret")
+	ErrNoCallbackSecret     = errors.New("callback group has no secret")
+)
+
+// Pushed groups with Callback API settings receive posts through CallbackHandler and are not polled
+func (h *Handler) Pushed(info sources.Info) bool {
+	_, ok := h.callbacks[info.Key]
+
+	return ok
+}
+
+// CallbackHandler receives VK Callback API events, POST /vk/callback
+func (h *Handler) CallbackHandler(w http.ResponseWriter, r *http.Request) {
+	const fn = "vk.CallbackHandler"
+
+	log := h.log.With(slog.String("fn", fn))
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, callbackMaxBody))
+	if err != nil {
+		log.Error(fn, sl.Err(err))
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+
+		return
+	}
+
+	var event callbackEvent
+
+	if err := json.Unmarshal(body, &event); err != nil {
+		log.Error(fn, sl.Err(err))
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+
+		return
+	}
+
+	resp, err := h.handleCallback(r.Context(), event)
+	if err != nil {
+		log.Error(fn,
+			sl.Err(err),
+			slog.String("type", event.Type),
+			slog.Int("group id", event.GroupID),
+			slog.String("event id", event.EventID),
+		)
+
+		switch {
+		case errors.Is(err, ErrUnknownCallbackGroup), errors.Is(err, ErrBadCallbackSecret):
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+
+		default:
+			// VK retries the event until it gets "ok"
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	if _, err := io.WriteString(w, resp); err != nil {
+		log.Error(fn, sl.Err(err))
+	}
+}
+
+func (h *Handler) handleCallback(ctx context.Context, event callbackEvent) (string, error) {
+	const fn = "vk.handleCallback"
+
+	// Stored group ids are negative as owner ids of community walls
+	vkGroup, err := h.db.GetVkGroupByID(ctx, -event.GroupID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoRecordsFound) {
+			return "", e.Wrap(fn, ErrUnknownCallbackGroup)
+		}
+
+		return "", e.Wrap(fn, err)
+	}
+
+	cb, ok := h.callbacks[vkGroup.Domain]
+	if !ok {
+		return "", e.Wrap(fn, ErrUnknownCallbackGroup)
+	}
+
+	if event.Type == callbackConfirmation {
+		return cb.Confirmation, nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(cb.Secret), []byte(event.Secret)) != 1 {
+		return "", e.Wrap(fn, ErrBadCallbackSecret)
+	}
+
+	switch event.Type {
+	case callbackWallPostNew, callbackWallPostEdit, callbackWallRepost:
+		var post object.WallWallpost
+
+		if err := json.Unmarshal(event.Object, &post); err != nil {
+			return "", e.Wrap(fn, err)
+		}
+
+		if err := h.storeCallbackPost(ctx, vkGroup, event.Type, post); err != nil {
+			return "", e.Wrap(fn, err)
+		}
+	}
+
+	return callbackOK, nil
+}
+
+// storeCallbackPost stores posts of the group wall the same way as the listener,
+// reposts of the group posts to other walls are not news of the group
+func (h *Handler) storeCallbackPost(ctx context.Context, vkGroup models.VkGroup, eventType string, post object.WallWallpost) error {
+	if post.OwnerID != vkGroup.ID {
+		return nil
+	}
+
+	if post.PostType == object.WallPostTypePostpone || post.PostType == object.WallPostTypeSuggest {
+		return nil
+	}
+
+	msg := toVkMessage(vkGroup.ID, post, make(authors))
+
+	// Edit events may come without the edited date, the stored post must still be replaced
+	if eventType == callbackWallPostEdit && msg.EditedAt.IsZero() {
+		msg.EditedAt = time.Now()
+	}
+
+	if !h.flt.Match(Kind, vkGroup.Domain, msg.Text, len(msg.Metadata) > 0) {
+		return nil
+	}
+
+	if err := h.db.InsertVkMessages(ctx, []models.VkMessage{msg}); err != nil {
+		return err
+	}
+
+	// Keeps the cursor valid if the group is switched back to polling
+	return h.db.SetVkCursor(ctx, vkGroup.ID, post.ID)
+}
@@ -0,0 +1,199 @@
+package vk
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"project/internal/config"
+	"project/internal/models"
+	"project/internal/storage"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testGroupID = 42
+	testDomain  = "testgroup"
+	testSecret  = "s3cret"
+)
+
+type callbackStorage struct {
+	msgs   []models.VkMessage
+	cursor int
+}
+
+func (s *callbackStorage) GetVkGroupByID(_ context.Context, groupID int) (models.VkGroup, error) {
+	if groupID != -testGroupID {
+		return models.VkGroup{}, storage.ErrNoRecordsFound
+	}
+
+	return models.VkGroup{ID: -testGroupID, Domain: testDomain, Name: "Test"}, nil
+}
+
+func (s *callbackStorage) InsertVkMessages(_ context.Context, msgs []models.VkMessage) error {
+	s.msgs = append(s.msgs, msgs...)
+	return nil
+}
+
+func (s *callbackStorage) SetVkCursor(_ context.Context, _ int, postID int) error {
+	s.cursor = postID
+	return nil
+}
+
+func (s *callbackStorage) GetVkGroups(context.Context) ([]models.VkGroup, error) { return nil, nil }
+func (s *callbackStorage) InsertVkGroup(context.Context, models.VkGroup) error   { return nil }
+func (s *callbackStorage) DeleteVkGroup(context.Context, string) error           { return nil }
+func (s *callbackStorage) InsertBackfillVkMessages(context.Context, []models.VkMessage) (int, error) {
+	return 0, nil
+}
+func (s *callbackStorage) GetVkCursor(context.Context, int) (int, error) { return 0, nil }
+func (s *callbackStorage) GetVkInterval(context.Context, int) (time.Duration, time.Duration, error) {
+	return 0, 0, nil
+}
+func (s *callbackStorage) SetVkInterval(context.Context, int, time.Duration, time.Duration) error {
+	return nil
+}
+
+type passFilter struct{}
+
+func (passFilter) Match(string, string, string, bool) bool { return true }
+
+func newCallbackHandler(t *testing.T) (*Handler, *callbackStorage) {
+	t.Helper()
+
+	db := &callbackStorage{}
+	cfg := &config.VkApi{
+		Callback: map[string]config.VkCallback{
+			testDomain: {Confirmation: "confirm-code", Secret: testSecret},
+		},
+	}
+
+	h, err := New(nil, db, passFilter{}, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return h, db
+}
+
+func postCallback(h *Handler, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.CallbackHandler(w, httptest.NewRequest(http.MethodPost, "/vk/callback", strings.NewReader(body)))
+
+	return w
+}
+
+func TestNewRequiresCallbackSecret(t *testing.T) {
+	cfg := &config.VkApi{
+		Callback: map[string]config.VkCallback{testDomain: {Confirmation: "confirm-code"}},
+	}
+
+	if _, err := New(nil, &callbackStorage{}, passFilter{}, cfg, slog.New(slog.NewTextHandler(io.Discard, nil))); err == nil {
+		t.Fatal("expected an error for a callback group without secret")
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantBody   string
+		// wantMsgs are the stored post ids, edited marks a post stored with edited_at
+		wantMsgs   []int
+		wantEdited bool
+	}{
+		{
+			name:       "confirmation",
+			body:       `{"type":"confirmation","group_id":42}`,
+			wantStatus: http.StatusOK,
+			wantBody:   "confirm-code",
+		},
+		{
+			name:       "unknown group",
+			body:       `{"type":"confirmation","group_id":7}`,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "bad secret",
+			body: `{"type":"wall_post_new","group_id":42,"event_id":"e1","secret":"wrong",
+				"object":{"id":10,"owner_id":-42,"from_id":-42,"date":1700000000,"text":"fake","post_type":"post"}}`,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "no secret",
+			body: `{"type":"wall_post_new","group_id":42,"event_id":"e1",
+				"object":{"id":10,"owner_id":-42,"from_id":-42,"date":1700000000,"text":"fake","post_type":"post"}}`,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "new post",
+			body: `{"type":"wall_post_new","group_id":42,"event_id":"e2","secret":"s3cret",
+				"object":{"id":11,"owner_id":-42,"from_id":-42,"date":1700000000,"text":"Новость","post_type":"post"}}`,
+			wantStatus: http.StatusOK,
+			wantBody:   callbackOK,
+			wantMsgs:   []int{11},
+		},
+		{
+			name: "edited post",
+			body: `{"type":"wall_post_edit","group_id":42,"event_id":"e3","secret":"s3cret",
+				"object":{"id":11,"owner_id":-42,"from_id":-42,"date":1700000000,"text":"Новость, правка","post_type":"post"}}`,
+			wantStatus: http.StatusOK,
+			wantBody:   callbackOK,
+			wantMsgs:   []int{11},
+			wantEdited: true,
+		},
+		{
+			name: "foreign owner repost",
+			body: `{"type":"wall_repost","group_id":42,"event_id":"e4","secret":"s3cret",
+				"object":{"id":99,"owner_id":1234,"from_id":1234,"date":1700000000,"text":"","post_type":"post",
+				"copy_history":[{"id":11,"owner_id":-42,"from_id":-42,"date":1700000000,"text":"Новость","post_type":"post"}]}}`,
+			wantStatus: http.StatusOK,
+			wantBody:   callbackOK,
+		},
+		{
+			name:       "malformed body",
+			body:       `{"type":`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := newCallbackHandler(t)
+
+			w := postCallback(h, tt.body)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+
+			if len(db.msgs) != len(tt.wantMsgs) {
+				t.Fatalf("stored %d messages, want %d", len(db.msgs), len(tt.wantMsgs))
+			}
+
+			for i, id := range tt.wantMsgs {
+				msg := db.msgs[i]
+
+				if msg.MessageID != id || msg.GroupID != -testGroupID {
+					t.Errorf("stored post %d of group %d, want %d of %d", msg.MessageID, msg.GroupID, id, -testGroupID)
+				}
+
+				if msg.EditedAt.IsZero() == tt.wantEdited {
+					t.Errorf("edited_at = %v, want edited %v", msg.EditedAt, tt.wantEdited)
+				}
+
+				if db.cursor != id {
+					t.Errorf("cursor = %d, want %d", db.cursor, id)
+				}
+			}
+		})
+	}
+}
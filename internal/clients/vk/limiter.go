@@ -0,0 +1,123 @@
+package vk
+
+import (
+	"context"
+	"errors"
+	"github.com/SevereCloud/vksdk/v3/api"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultRPS is the VK limit for server tokens
+	defaultRPS = 3
+
+	maxBackoff = 6 * time.Hour
+)
+
+// limiter is a token bucket shared by all VK requests of the application,
+// VK rate limit errors pause it for everyone
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+
+	pausedUntil time.Time
+	// backoff is the last pause, it doubles while VK keeps returning rate limit errors
+	backoff time.Duration
+}
+
+func newLimiter(rps float64) *limiter {
+	if rps <= 0 {
+		rps = defaultRPS
+	}
+
+	return &limiter{
+		rate:   rps,
+		tokens: rps,
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a request may be sent
+func (l *limiter) Wait(ctx context.Context) error {
+	for {
+		d := l.reserve(time.Now())
+		if d == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+		}
+	}
+}
+
+// reserve takes a token and returns 0, or returns how long to wait for one
+func (l *limiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+
+	// The bucket holds at most one second of requests
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// Done reports the result of a request, rate limit errors pause all requests:
+// 6 too many requests per second, 9 flood control, 29 the method daily limit
+func (l *limiter) Done(err error) {
+	var base time.Duration
+
+	switch {
+	case err == nil:
+		l.mu.Lock()
+		l.backoff = 0
+		l.mu.Unlock()
+
+		return
+
+	case errors.Is(err, api.ErrTooMany):
+		base = time.Second
+
+	case errors.Is(err, api.ErrFlood):
+		base = time.Minute
+
+	case errors.Is(err, api.ErrRateLimit):
+		base = 30 * time.Minute
+
+	default:
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.backoff *= 2
+	if l.backoff < base {
+		l.backoff = base
+	}
+
+	if l.backoff > maxBackoff {
+		l.backoff = maxBackoff
+	}
+
+	l.pausedUntil = time.Now().Add(l.backoff)
+}
@@ -0,0 +1,174 @@
+package vk
+
+import (
+	"fmt"
+	"github.com/SevereCloud/vksdk/v3/object"
+	"project/internal/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	msgRepost = "Репост: %s"
+	msgPoll   = "Опрос: %s"
+	msgAlbum  = "Альбом, фото: %d"
+)
+
+// authors resolves owner ids of reposted posts to names from the extended wall.get response
+type authors map[int]string
+
+func newAuthors(ext object.ExtendedResponse) authors {
+	names := make(authors, len(ext.Groups)+len(ext.Profiles))
+
+	for _, group := range ext.Groups {
+		names[-group.ID] = group.Name
+	}
+
+	for _, profile := range ext.Profiles {
+		names[profile.ID] = strings.TrimSpace(profile.FirstName + " " + profile.LastName)
+	}
+
+	return names
+}
+
+func (a authors) name(ownerID int) string {
+	if name, ok := a[ownerID]; ok {
+		return name
+	}
+
+	if ownerID < 0 {
+		return fmt.Sprintf("https://vk.com/club%d", -ownerID)
+	}
+
+	return fmt.Sprintf("https://vk.com/id%d", ownerID)
+}
+
+// toVkMessage inlines reposted posts after the post text with attribution
+func toVkMessage(groupID int, post object.WallWallpost, names authors) models.VkMessage {
+	text, metadata := getContentFromVkPost(post)
+
+	for _, repost := range post.CopyHistory {
+		repostText, repostMetadata := getContentFromVkPost(repost)
+
+		header := fmt.Sprintf(msgRepost, names.name(repost.OwnerID))
+
+		text = joinText(text, header+"\n"+repostText)
+		metadata = append(metadata, repostMetadata...)
+	}
+
+	msg := models.VkMessage{
+		MessageID: post.ID,
+		GroupID:   groupID,
+		Text:      text,
+		Metadata:  metadata,
+		CreatedAt: time.Unix(int64(post.Date), 0),
+	}
+
+	if post.Edited > 0 {
+		msg.EditedAt = time.Unix(int64(post.Edited), 0)
+	}
+
+	return msg
+}
+
+// getContentFromVkPost Polls have no media form and are added to the text
+func getContentFromVkPost(post object.WallWallpost) (string, []models.MetaPair) {
+	text := post.Text
+
+	for _, attachment := range post.Attachments {
+		if attachment.Type != object.AttachmentTypePoll {
+			continue
+		}
+
+		text = joinText(text, pollText(attachment.Poll))
+	}
+
+	return text, getMetadataFromVkMsg(post.Attachments)
+}
+
+func pollText(poll object.PollsPoll) string {
+	var b strings.Builder
+
+	b.WriteString(fmt.Sprintf(msgPoll, poll.Question))
+
+	for _, answer := range poll.Answers {
+		b.WriteString("\n— " + answer.Text)
+	}
+
+	return b.String()
+}
+
+func joinText(text, part string) string {
+	if text == "" {
+		return part
+	}
+
+	return text + "\n\n" + part
+}
+
+// photoSizes returns the largest size and all sizes sorted by width, ok is false when the photo has no sizes
+func photoSizes(photo object.PhotosPhoto) (string, []models.MediaSize, bool) {
+	if len(photo.Sizes) == 0 {
+		return "", nil, false
+	}
+
+	sizes := make([]models.MediaSize, 0, len(photo.Sizes))
+	seen := make(map[int]bool, len(photo.Sizes))
+
+	for _, size := range photo.Sizes {
+		width := int(size.Width)
+
+		if size.URL == "" || seen[width] {
+			continue
+		}
+
+		seen[width] = true
+
+		sizes = append(sizes, models.MediaSize{
+			Url:    size.URL,
+			Width:  width,
+			Height: int(size.Height),
+		})
+	}
+
+	if len(sizes) == 0 {
+		return "", nil, false
+	}
+
+	sort.Slice(sizes, func(i, j int) bool {
+		return sizes[i].Width < sizes[j].Width
+	})
+
+	return sizes[len(sizes)-1].Url, sizes, true
+}
+
+func linkCard(link object.BaseLink) models.MetaPair {
+	card := models.MetaPair{
+		Url:         link.URL,
+		Type:        models.MsgLink,
+		Title:       link.Title,
+		Description: link.Description,
+	}
+
+	if preview, _, ok := photoSizes(link.Photo); ok {
+		card.Preview = preview
+	}
+
+	return card
+}
+
+func albumCard(album object.PhotosPhotoAlbum) models.MetaPair {
+	card := models.MetaPair{
+		Url:         fmt.Sprintf("https://vk.com/album%d_%d", album.OwnerID, album.ID),
+		Type:        models.MsgLink,
+		Title:       album.Title,
+		Description: fmt.Sprintf(msgAlbum, album.Size),
+	}
+
+	if preview, _, ok := photoSizes(album.Thumb); ok {
+		card.Preview = preview
+	}
+
+	return card
+}
@@ -0,0 +1,97 @@
+package vk
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
+
+const (
+	DefaultMinInterval = 1 * time.Minute
+	DefaultMaxInterval = 6 * time.Hour
+
+	// MinAllowedInterval protects the shared rate limit from a too frequent poll
+	MinAllowedInterval = 10 * time.Second
+
+	// cadenceWeight is the weight of a new gap between posts in the average
+	cadenceWeight = 0.3
+)
+
+// Interval bounds the poll interval of a group, zero values fall back to the defaults
+type Interval struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// cadence learns how often a group posts from the publication dates of its posts
+type cadence struct {
+	// avg is the moving average gap between posts, zero until two posts are seen
+	avg      time.Duration
+	lastPost time.Time
+}
+
+// observe adds publication dates of received posts
+func (c *cadence) observe(dates []time.Time) {
+	sort.Slice(dates, func(i, j int) bool {
+		return dates[i].Before(dates[j])
+	})
+
+	for _, date := range dates {
+		if !date.After(c.lastPost) {
+			continue
+		}
+
+		if !c.lastPost.IsZero() {
+			gap := date.Sub(c.lastPost)
+
+			if c.avg == 0 {
+				c.avg = gap
+			} else {
+				c.avg = time.Duration(cadenceWeight*float64(gap) + (1-cadenceWeight)*float64(c.avg))
+			}
+		}
+
+		c.lastPost = date
+	}
+}
+
+// next polls about twice per average gap, a group that is quiet for longer than usual is polled less often
+func (c *cadence) next(interval Interval, now time.Time) time.Duration {
+	d := interval.Max
+
+	if c.avg > 0 {
+		d = c.avg / 2
+
+		if quiet := now.Sub(c.lastPost); quiet > c.avg {
+			d = quiet / 4
+		}
+	}
+
+	if d > interval.Max {
+		d = interval.Max
+	}
+
+	if d < interval.Min {
+		d = interval.Min
+	}
+
+	// Groups added at the same time should not be polled at the same moment
+	return d + time.Duration(rand.Int63n(int64(d/10)+1))
+}
+
+// withDefaults fills zero bounds from def
+func (i Interval) withDefaults(def Interval) Interval {
+	if i.Min == 0 {
+		i.Min = def.Min
+	}
+
+	if i.Max == 0 {
+		i.Max = def.Max
+	}
+
+	if i.Max < i.Min {
+		i.Max = i.Min
+	}
+
+	return i
+}
@@ -2,27 +2,42 @@ package vk
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"github.com/SevereCloud/vksdk/v3/api"
 	"log/slog"
+	"project/internal/config"
 	"project/internal/models"
+	"project/pkg/e"
 	"sync"
+	"time"
 )
 
+const Kind = "vk"
+
 type Handler struct {
 	vk  *api.VK
 	db  Storage
-	ls  *listeners
-	log *slog.Logger
-}
+	flt Filter
+	lim *limiter
+
+	// defInterval and cfgIntervals (by domain) come from the config
+	defInterval  Interval
+	cfgIntervals map[string]Interval
 
-type listeners struct {
-	m  map[string]*Listener
-	mu sync.RWMutex
+	mu sync.Mutex
+	// intervals are set by /vk interval and override the config, by domain
+	intervals map[string]Interval
+	// wake makes a listener recompute its timer after the interval is changed, by domain
+	wake map[string]chan struct{}
+
+	// callbacks are the groups in Callback API mode, by domain
+	callbacks map[string]Callback
+
+	log *slog.Logger
 }
 
-type Listener struct {
-	stop chan struct{}
+type Filter interface {
+	Match(kind, key, text string, hasMedia bool) bool
 }
 
 type Storage interface {
@@ -30,21 +45,51 @@ type Storage interface {
 	InsertVkGroup(ctx context.Context, vkGroup models.VkGroup) error
 	DeleteVkGroup(ctx context.Context, vkDomain string) error
 	InsertVkMessages(ctx context.Context, msgs []models.VkMessage) error
+	InsertBackfillVkMessages(ctx context.Context, msgs []models.VkMessage) (int, error)
+	GetVkCursor(ctx context.Context, groupID int) (int, error)
+	SetVkCursor(ctx context.Context, groupID int, postID int) error
+	GetVkInterval(ctx context.Context, groupID int) (time.Duration, time.Duration, error)
+	SetVkInterval(ctx context.Context, groupID int, min, max time.Duration) error
+	GetVkGroupByID(ctx context.Context, groupID int) (models.VkGroup, error)
 }
 
-var (
-	ErrVkGroupIsPrivate = errors.New("vk group is private")
-	ErrVkGroupNotFound  = errors.New("vk group not found")
-	ErrVkGroupIsExists  = errors.New("vk group is exists")
-)
+// New every vk_api.callback group must have a secret, otherwise anyone could post into its feed
+func New(api *api.VK, db Storage, flt Filter, cfg *config.VkApi, log *slog.Logger) (*Handler, error) {
+	const fn = "vk.New"
+
+	h := &Handler{
+		vk:  api,
+		db:  db,
+		flt: flt,
+		lim: newLimiter(cfg.RPS),
+		defInterval: Interval{
+			Min: cfg.MinInterval,
+			Max: cfg.MaxInterval,
+		}.withDefaults(Interval{Min: DefaultMinInterval, Max: DefaultMaxInterval}),
+		cfgIntervals: make(map[string]Interval, len(cfg.Groups)),
+		intervals:    make(map[string]Interval),
+		wake:         make(map[string]chan struct{}),
+		callbacks:    make(map[string]Callback, len(cfg.Callback)),
+		log:          log,
+	}
 
-func New(api *api.VK, db Storage, log *slog.Logger) *Handler {
-	return &Handler{
-		vk: api,
-		db: db,
-		ls: &listeners{
-			m: make(map[string]*Listener),
-		},
-		log: log,
+	for domain, interval := range cfg.Groups {
+		h.cfgIntervals[domain] = Interval{
+			Min: interval.MinInterval,
+			Max: interval.MaxInterval,
+		}
 	}
+
+	for domain, cb := range cfg.Callback {
+		if cb.Secret == "" {
+			return nil, e.Wrap(fn, fmt.Errorf("%w: %s", ErrNoCallbackSecret, domain))
+		}
+
+		h.callbacks[domain] = Callback{
+			Confirmation: cb.Confirmation,
+			Secret:       cb.Secret,
+		}
+	}
+
+	return h, nil
 }
@@ -8,123 +8,87 @@ import (
 	vk_params "github.com/SevereCloud/vksdk/v3/api/params"
 	"github.com/SevereCloud/vksdk/v3/object"
 	"log/slog"
-	"math/rand"
 	"project/internal/models"
 	"project/internal/pkg/logger/sl"
-	"project/internal/storage"
+	"project/internal/sources"
 	"project/pkg/e"
+	"sort"
 	"time"
 )
 
-func (h *Handler) PrepareNewsGatherer(ctx context.Context) error {
-	const fn = "vk.PrepareNewsGatherer"
+func (h *Handler) Kind() string {
+	return Kind
+}
 
-	vkGroups, err := h.db.GetVkGroups(ctx)
-	if err != nil {
-		if errors.Is(err, storage.ErrNoRecordsFound) {
-			return nil
-		}
+func (h *Handler) Validate(ctx context.Context, domain string) (sources.Info, error) {
+	const fn = "vk.Validate"
 
-		return e.Wrap(fn, err)
+	vkGroup, err := h.validate(ctx, domain)
+	if err != nil {
+		return sources.Info{}, e.Wrap(fn, err)
 	}
 
-	for _, vkGroup := range vkGroups {
-		vkGroup, err := h.validate(vkGroup.Domain)
-		if err != nil {
-			if errors.Is(err, ErrVkGroupNotFound) || errors.Is(err, ErrVkGroupIsPrivate) {
-				h.log.Error(fn,
-					sl.Err(err),
-					slog.String("domain", vkGroup.Domain),
-				)
-
-				continue
-			}
-
-			h.log.Error(fn, sl.Err(err))
-
-			continue
-		}
+	return toInfo(vkGroup), nil
+}
 
-		stopCh := make(chan struct{})
+func (h *Handler) Describe(ctx context.Context) ([]sources.Info, error) {
+	const fn = "vk.Describe"
 
-		listener := &Listener{
-			stopCh,
-		}
+	vkGroups, err := h.db.GetVkGroups(ctx)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
 
-		h.ls.m[vkGroup.Domain] = listener
+	infos := make([]sources.Info, 0, len(vkGroups))
 
-		go h.listen(vkGroup, stopCh)
+	for _, vkGroup := range vkGroups {
+		infos = append(infos, toInfo(vkGroup))
 	}
 
-	return nil
+	return infos, nil
 }
 
-func (h *Handler) Shutdown(ctx context.Context, domain string) error {
-	const fn = "vk.Shutdown"
+func (h *Handler) Start(ctx context.Context, info sources.Info) (sources.Info, error) {
+	const fn = "vk.Start"
 
-	h.ls.mu.Lock()
-	defer h.ls.mu.Unlock()
-	listener, ok := h.ls.m[domain]
-	if !ok {
-		return e.Wrap(fn, ErrVkGroupNotFound)
-	}
-
-	select {
-	case listener.stop <- struct{}{}:
-		if err := h.db.DeleteVkGroup(ctx, domain); err != nil {
-			return e.Wrap(fn, err)
-		}
-		h.log.Info("[VK GROUP] Listener shutting down", slog.String("domain", domain))
-		delete(h.ls.m, domain)
-		close(listener.stop)
-	default:
+	if err := h.db.InsertVkGroup(ctx, toVkGroup(info)); err != nil {
+		return sources.Info{}, e.Wrap(fn, err)
 	}
 
-	return nil
+	return info, nil
 }
 
-func (h *Handler) ListenStart(ctx context.Context, vkDomain string) error {
-	const fn = "vk.ListenStart"
-
-	h.ls.mu.RLock()
-	_, ok := h.ls.m[vkDomain]
-	if ok {
-		h.ls.mu.RUnlock()
-		return e.Wrap(fn, ErrVkGroupIsExists)
-	}
-	h.ls.mu.RUnlock()
+func (h *Handler) Stop(ctx context.Context, info sources.Info) error {
+	const fn = "vk.Stop"
 
-	vkGroup, err := h.validate(vkDomain)
-	if err != nil {
+	if err := h.db.DeleteVkGroup(ctx, info.Key); err != nil {
 		return e.Wrap(fn, err)
 	}
 
-	h.ls.mu.Lock()
-	defer h.ls.mu.Unlock()
+	return nil
+}
 
-	_, ok = h.ls.m[vkDomain]
-	if ok {
-		return e.Wrap(fn, ErrVkGroupIsExists)
-	}
+func (h *Handler) Listen(info sources.Info, stopCh <-chan struct{}) {
+	h.listen(toVkGroup(info), stopCh)
+}
 
-	if err = h.db.InsertVkGroup(ctx, vkGroup); err != nil {
-		return e.Wrap(fn, err)
+func toInfo(vkGroup models.VkGroup) sources.Info {
+	return sources.Info{
+		ID:   int64(vkGroup.ID),
+		Key:  vkGroup.Domain,
+		Name: vkGroup.Name,
 	}
+}
 
-	stopCh := make(chan struct{}, 1)
-
-	listener := &Listener{
-		stopCh,
+func toVkGroup(info sources.Info) models.VkGroup {
+	return models.VkGroup{
+		ID:     int(info.ID),
+		Name:   info.Name,
+		Domain: info.Key,
 	}
-
-	h.ls.m[vkDomain] = listener
-
-	go h.listen(vkGroup, stopCh)
-
-	return nil
 }
 
-func (h *Handler) listen(vkGroup models.VkGroup, stopCh chan struct{}) {
+func (h *Handler) listen(vkGroup models.VkGroup, stopCh <-chan struct{}) {
 	const fn = "vk.listen"
 
 	log := h.log.With(
@@ -134,102 +98,396 @@ func (h *Handler) listen(vkGroup models.VkGroup, stopCh chan struct{}) {
 
 	log.Info("[VK GROUP] Listener started")
 
-	params := vk_params.NewWallGetBuilder()
-	params.Domain(vkGroup.Domain)
-	params.Count(5)
-
-	const (
-		minInterval = 1 * time.Minute
-		maxInterval = 6 * time.Hour
-	)
-
-	timer := time.NewTimer(time.Nanosecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	pollIntervalBase := minInterval
+	wake := h.register(ctx, vkGroup)
+	defer h.unregister(vkGroup.Domain, wake)
 
-	nextPollInterval := func(postsReceived bool) time.Duration {
-		if postsReceived {
-			pollIntervalBase = maxInterval
+	lastPostID, err := h.db.GetVkCursor(ctx, vkGroup.ID)
+	if err != nil {
+		log.Error(fn, sl.Err(err))
+	}
 
-			return pollIntervalBase
+	// storedPostID is the cursor in the storage, it lags behind lastPostID while a gap is fetched
+	storedPostID := lastPostID
 
-		} else {
-			pollIntervalBase -= pollIntervalBase / 3
+	// edited post id -> edited timestamp of the posts on the last page
+	edited := make(map[int]int)
 
-			if pollIntervalBase < minInterval {
-				pollIntervalBase = minInterval
-			}
+	// gap is set while more than catchUpMaxPosts missed posts are fetched
+	var gap *catchUp
 
-			return pollIntervalBase + time.Duration(rand.Intn(int(pollIntervalBase/10)))
-		}
-	}
+	var sched cadence
 
-	lastPostID := 0
+	timer := time.NewTimer(time.Nanosecond)
+	defer timer.Stop()
 
 	for {
 		select {
 		case <-stopCh:
 			h.log.Info("[VK GROUP] Listener shutdown")
 			return
+
+		case <-wake:
+			timer.Reset(sched.next(h.interval(vkGroup.Domain), time.Now()))
+			continue
+
 		case <-timer.C:
 		}
 
-		timer.Reset(nextPollInterval(false))
-
 		timeNow := time.Now()
 
-		posts, err := h.vk.WallGet(params.Params)
+		run, err := h.fetchSince(ctx, vkGroup.Domain, lastPostID)
 		if err != nil {
 			log.Error(fn, sl.Err(err))
+		}
 
-			continue
+		posts, names := run.posts, run.names
+
+		// Posts of the gap are below the cursor but were never stored
+		var (
+			gapPosts map[int]bool
+			nextGap  = gap
+		)
+
+		if gap != nil && err == nil {
+			gapRun, err := h.fetchGap(ctx, vkGroup.Domain, *gap, run.count)
+			if err != nil {
+				log.Error(fn, sl.Err(err))
+			} else {
+				gapPosts = make(map[int]bool, len(gapRun.posts))
+
+				for _, post := range gapRun.posts {
+					if post.ID < lastPostID {
+						gapPosts[post.ID] = true
+						posts = append(posts, post)
+					}
+				}
+
+				for id, name := range gapRun.names {
+					names[id] = name
+				}
+
+				nextGap = nil
+
+				if !gapRun.reached {
+					nextGap = &catchUp{cursor: gap.cursor, offset: gapRun.next, count: gapRun.count}
+				}
+
+				sortNewestFirst(posts)
+			}
 		}
 
-		if posts.Count == 0 {
-			continue
+		// More than catchUpMaxPosts posts were missed, the rest is fetched on the next polls
+		if err == nil && !run.reached {
+			from := lastPostID
+			if gap != nil {
+				from = gap.cursor
+			}
+
+			nextGap = &catchUp{cursor: from, offset: run.next, count: run.count}
+
+			log.Warn("[VK GROUP] too many missed posts, catching up on the next polls",
+				slog.Int("after post", from),
+				slog.Int("before post", run.posts[len(run.posts)-1].ID),
+			)
 		}
 
-		msgs := make([]models.VkMessage, 0, posts.Count)
+		msgs := make([]models.VkMessage, 0, len(posts))
+		dates := make([]time.Time, 0, len(posts))
+		newPosts := 0
+		cursor := lastPostID
+
+		for i := len(posts) - 1; i >= 0; i-- {
+			post := posts[i]
+
+			if post.ID > cursor {
+				cursor = post.ID
+			}
 
-		for i := len(posts.Items) - 1; i >= 0; i-- {
-			post := posts.Items[i]
+			// The cadence is learned from all live posts, including filtered out ones
+			if !gapPosts[post.ID] {
+				dates = append(dates, time.Unix(int64(post.Date), 0))
+			}
 
-			if post.ID <= lastPostID {
+			// Already seen posts are stored again only when edited, the storage skips unchanged ones
+			isNew := post.ID > lastPostID || gapPosts[post.ID]
+			if !isNew && edited[post.ID] == post.Edited {
 				continue
 			}
 
-			metadata := getMetadataFromVkMsg(post.Attachments)
+			edited[post.ID] = post.Edited
 
-			msg := models.VkMessage{
-				MessageID: post.ID,
-				GroupID:   vkGroup.ID,
-				Text:      post.Text,
-				Metadata:  metadata,
-				CreatedAt: time.Unix(int64(post.Date), 0),
+			msg := toVkMessage(vkGroup.ID, post, names)
+
+			if !h.flt.Match(Kind, vkGroup.Domain, msg.Text, len(msg.Metadata) > 0) {
+				continue
+			}
+
+			if isNew {
+				newPosts++
 			}
 
 			msgs = append(msgs, msg)
 		}
 
-		lastPostID = posts.Items[0].ID
+		sched.observe(dates)
+
+		timer.Reset(sched.next(h.interval(vkGroup.Domain), time.Now()))
+
+		if len(posts) == 0 {
+			gap = nextGap
+			continue
+		}
+
+		// Edits are tracked for the live posts only
+		for id := range edited {
+			if len(run.posts) == 0 || id < run.posts[len(run.posts)-1].ID {
+				delete(edited, id)
+			}
+		}
+
+		if len(msgs) != 0 {
+			// The cursor moves only after the posts are stored, so a failed insert is retried
+			if err := h.db.InsertVkMessages(ctx, msgs); err != nil {
+				log.Error(fn, sl.Err(err))
+
+				continue
+			}
+		}
+
+		lastPostID = max(lastPostID, cursor)
+
+		// The stored cursor stays before the gap, so after a restart the gap is fetched again
+		stored := lastPostID
+		if nextGap != nil {
+			stored = nextGap.cursor
+		}
+
+		if stored > storedPostID {
+			if err := h.db.SetVkCursor(ctx, vkGroup.ID, stored); err != nil {
+				log.Error(fn, sl.Err(err))
+			} else {
+				storedPostID = stored
+			}
+		}
+
+		if gap != nil && nextGap == nil {
+			log.Info("[VK GROUP] missed posts caught up", slog.Int("after post", gap.cursor))
+		}
+
+		gap = nextGap
 
 		if len(msgs) == 0 {
 			continue
 		}
 
-		log.Info("[VK GROUP] new messages received",
-			slog.Int("quantity", len(msgs)),
+		log.Info("[VK GROUP] messages received",
+			slog.Int("new", newPosts),
+			slog.Int("edited", len(msgs)-newPosts),
 			slog.String("duration", time.Since(timeNow).String()),
 		)
+	}
+}
 
-		timer.Reset(nextPollInterval(true))
+// register loads the interval set by /vk interval and returns the wake channel of the listener
+func (h *Handler) register(ctx context.Context, vkGroup models.VkGroup) chan struct{} {
+	const fn = "vk.register"
 
-		if err := h.db.InsertVkMessages(context.TODO(), msgs); err != nil {
-			if err != nil {
-				log.Error(fn, sl.Err(err))
+	wake := make(chan struct{}, 1)
+
+	min, max, err := h.db.GetVkInterval(ctx, vkGroup.ID)
+	if err != nil {
+		h.log.Error(fn, sl.Err(err), slog.String("domain", vkGroup.Domain))
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.wake[vkGroup.Domain] = wake
+
+	if min != 0 || max != 0 {
+		h.intervals[vkGroup.Domain] = Interval{Min: min, Max: max}
+	}
+
+	return wake
+}
+
+func (h *Handler) unregister(domain string, wake chan struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// The group may be deleted and added again while this listener stops
+	if h.wake[domain] == wake {
+		delete(h.wake, domain)
+		delete(h.intervals, domain)
+	}
+}
+
+// interval the bounds set by /vk interval win over the config ones
+func (h *Handler) interval(domain string) Interval {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if interval, ok := h.intervals[domain]; ok {
+		return interval.withDefaults(h.defInterval)
+	}
+
+	return h.cfgIntervals[domain].withDefaults(h.defInterval)
+}
+
+func (h *Handler) SetInterval(ctx context.Context, info sources.Info, min, max time.Duration) error {
+	const fn = "vk.SetInterval"
+
+	if min < MinAllowedInterval || max < min {
+		return e.Wrap(fn, sources.ErrBadInterval)
+	}
+
+	vkGroup := toVkGroup(info)
+
+	if err := h.db.SetVkInterval(ctx, vkGroup.ID, min, max); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.intervals[vkGroup.Domain] = Interval{Min: min, Max: max}
+
+	if wake, ok := h.wake[vkGroup.Domain]; ok {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// wallGet sends wall.get through the shared limiter
+func (h *Handler) wallGet(ctx context.Context, params *vk_params.WallGetBuilder) (api.WallGetExtendedResponse, error) {
+	if err := h.lim.Wait(ctx); err != nil {
+		return api.WallGetExtendedResponse{}, err
+	}
+
+	res, err := h.vk.WallGetExtended(params.Params)
+
+	h.lim.Done(err)
+
+	return res, err
+}
+
+const (
+	// livePageSize posts are requested on every poll, they are also checked for edits
+	livePageSize = 10
+
+	// catchUpPageSize and catchUpMaxPosts limit paging back to the cursor after a long pause
+	catchUpPageSize = 100
+	catchUpMaxPosts = 1000
+)
+
+// wallRun is a run of wall posts fetched newest first from an offset
+type wallRun struct {
+	posts []object.WallWallpost
+	names authors
+	// next is the offset after the last fetched page, count the wall size at that page
+	next  int
+	count int
+	// reached the post with the cursor id or the end of the wall
+	reached bool
+}
+
+// catchUp is the part of the wall not fetched yet after catchUpMaxPosts:
+// posts after the cursor are read from the offset on the next polls
+type catchUp struct {
+	cursor int
+	offset int
+	// count is the wall size the offset belongs to, new posts shift the offset
+	count int
+}
+
+// fetchSince returns posts from the newest one, paging back until the post with the cursor id is reached.
+// A zero cursor is a new group and only the live page is returned
+func (h *Handler) fetchSince(ctx context.Context, domain string, cursor int) (wallRun, error) {
+	return h.fetchRun(ctx, domain, 0, cursor, livePageSize)
+}
+
+// fetchGap continues the catch up, the offset is moved by the posts published since the last run
+func (h *Handler) fetchGap(ctx context.Context, domain string, gap catchUp, count int) (wallRun, error) {
+	return h.fetchRun(ctx, domain, max(gap.offset+count-gap.count, 0), gap.cursor, catchUpPageSize)
+}
+
+// fetchRun pages back from the offset until the post with the cursor id, the end of the wall
+// or catchUpMaxPosts posts
+func (h *Handler) fetchRun(ctx context.Context, domain string, offset, cursor, pageSize int) (wallRun, error) {
+	const fn = "vk.fetchRun"
+
+	params := vk_params.NewWallGetBuilder()
+	params.Domain(domain)
+	params.Count(pageSize)
+
+	run := wallRun{
+		names: make(authors),
+		next:  offset,
+	}
+
+	seen := make(map[int]bool)
+
+	for fetched := 0; fetched < catchUpMaxPosts; {
+		params.Offset(run.next)
+
+		page, err := h.wallGet(ctx, params)
+		if err != nil {
+			return wallRun{}, e.Wrap(fn, err)
+		}
+
+		for id, name := range newAuthors(page.ExtendedResponse) {
+			run.names[id] = name
+		}
+
+		run.count = page.Count
+		run.reached = cursor == 0
+
+		// Posts published during paging shift the offsets, so pages may overlap
+		for _, post := range page.Items {
+			if seen[post.ID] {
+				continue
 			}
+
+			seen[post.ID] = true
+
+			run.posts = append(run.posts, post)
+
+			if post.ID <= cursor && !bool(post.IsPinned) {
+				run.reached = true
+			}
+		}
+
+		run.next += len(page.Items)
+		fetched += len(page.Items)
+
+		if run.next >= page.Count || len(page.Items) == 0 {
+			run.reached = true
+		}
+
+		if run.reached {
+			break
 		}
+
+		params.Count(catchUpPageSize)
 	}
+
+	// Newest first as wall.get returns them, the pinned post may be out of order
+	sortNewestFirst(run.posts)
+
+	return run, nil
+}
+
+func sortNewestFirst(posts []object.WallWallpost) {
+	sort.Slice(posts, func(i, j int) bool {
+		return posts[i].ID > posts[j].ID
+	})
 }
 
 func getMetadataFromVkMsg(attachments []object.WallWallpostAttachment) []models.MetaPair {
@@ -238,9 +496,15 @@ func getMetadataFromVkMsg(attachments []object.WallWallpostAttachment) []models.
 	for _, attachment := range attachments {
 		switch attachment.Type {
 		case object.AttachmentTypePhoto:
+			url, sizes, ok := photoSizes(attachment.Photo)
+			if !ok {
+				continue
+			}
+
 			pairs = append(pairs, models.MetaPair{
-				Url:  attachment.Photo.Sizes[len(attachment.Photo.Sizes)-1].URL,
-				Type: models.MsgPhoto,
+				Url:   url,
+				Type:  models.MsgPhoto,
+				Sizes: sizes,
 			})
 
 		case object.AttachmentTypeVideo:
@@ -265,6 +529,12 @@ func getMetadataFromVkMsg(attachments []object.WallWallpostAttachment) []models.
 				Type: models.MsgDocument,
 			})
 
+		case object.AttachmentTypeLink:
+			pairs = append(pairs, linkCard(attachment.Link))
+
+		case object.AttachmentTypeAlbum:
+			pairs = append(pairs, albumCard(attachment.Album))
+
 		default:
 		}
 	}
@@ -272,23 +542,30 @@ func getMetadataFromVkMsg(attachments []object.WallWallpostAttachment) []models.
 	return pairs
 }
 
-func (h *Handler) validate(domain string) (models.VkGroup, error) {
+func (h *Handler) validate(ctx context.Context, domain string) (models.VkGroup, error) {
 	const fn = "vk.validate"
 
 	params := vk_params.NewGroupsGetByIDBuilder()
 	params.GroupID(domain)
 
+	if err := h.lim.Wait(ctx); err != nil {
+		return models.VkGroup{}, e.Wrap(fn, err)
+	}
+
 	res, err := h.vk.GroupsGetByID(params.Params)
+
+	h.lim.Done(err)
+
 	if err != nil {
 		if errors.Is(err, api.ErrPermission) {
-			return models.VkGroup{}, ErrVkGroupIsPrivate
+			return models.VkGroup{}, sources.ErrSourceIsPrivate
 		}
 
 		return models.VkGroup{}, e.Wrap(fn, err)
 	}
 
 	if len(res.Groups) == 0 {
-		return models.VkGroup{}, ErrVkGroupNotFound
+		return models.VkGroup{}, sources.ErrSourceNotFound
 	}
 
 	groupID := res.Groups[0].ID
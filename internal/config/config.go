@@ -13,11 +13,14 @@ type Config struct {
 	Telegram  *Telegram  `yaml:"telegram"`
 	WebServer *WebServer `yaml:"web_server"`
 	VkApi     *VkApi     `yaml:"vk_api"`
+	Rss       *Rss       `yaml:"rss"`
 	Slog      *Slog      `yaml:"slog"`
 	MPath     string     `yaml:"migrations_path"`
 	Storage   *DB        `yaml:"storage"`
 	Files     *Files     `yaml:"file_storage"`
 	Redis     *Redis     `yaml:"redis"`
+	Retention *Retention `yaml:"retention"`
+	Outbox    *Outbox    `yaml:"outbox"`
 }
 
 type Telegram struct {
@@ -31,10 +34,34 @@ type WebServer struct {
 	Addr         string        `yaml:"addr"`
 	ReadTimeout  time.Duration `yaml:"read_timeout"`
 	WriteTimeout time.Duration `yaml:"write_timeout"`
+	PublicURL    string        `yaml:"public_url"`
+	FeedTitle    string        `yaml:"feed_title"`
 }
 
 type VkApi struct {
 	Token string `yaml:"token"`
+	// RPS is shared by all VK requests, VK allows 3 for a server token
+	RPS         float64       `yaml:"requests_per_second"`
+	MinInterval time.Duration `yaml:"min_interval"`
+	MaxInterval time.Duration `yaml:"max_interval"`
+	// Groups overrides the poll intervals by group domain
+	Groups map[string]VkInterval `yaml:"groups"`
+	// Callback lists groups that push posts through the Callback API instead of polling, by domain
+	Callback map[string]VkCallback `yaml:"callback"`
+}
+
+type VkCallback struct {
+	Confirmation string `yaml:"confirmation"`
+	Secret       string `yaml:"secret"`
+}
+
+type VkInterval struct {
+	MinInterval time.Duration `yaml:"min_interval"`
+	MaxInterval time.Duration `yaml:"max_interval"`
+}
+
+type Rss struct {
+	PollInterval time.Duration `yaml:"poll_interval"`
 }
 
 type Slog struct {
@@ -43,17 +70,61 @@ type Slog struct {
 }
 
 type DB struct {
-	DBHost     string `yaml:"DB_HOST"`
-	DBPort     int    `yaml:"DB_PORT"`
-	DBName     string `yaml:"DB_NAME"`
-	DBUser     string `yaml:"DB_USER"`
-	DBPassword string `yaml:"DB_PASSWORD"`
+	// Driver is "postgres" (default) or "sqlite"
+	Driver string `yaml:"driver"`
+	// Path is the database file of the sqlite driver
+	Path string `yaml:"path"`
+	// NotifyEvents sends the feed events to the other instances through Postgres NOTIFY
+	NotifyEvents bool   `yaml:"notify_events"`
+	DBHost       string `yaml:"DB_HOST"`
+	DBPort       int    `yaml:"DB_PORT"`
+	DBName       string `yaml:"DB_NAME"`
+	DBUser       string `yaml:"DB_USER"`
+	DBPassword   string `yaml:"DB_PASSWORD"`
 }
 
 type Files struct {
+	// Driver is "minio" (default), "localfs" or "memory"
+	Driver string `yaml:"driver"`
+	// Dir is the root of the localfs driver, a directory per bucket
+	Dir    string `yaml:"dir"`
 	Addr   string `yaml:"addr"`
 	KeyID  string `yaml:"key_id"`
 	Secret string `yaml:"secret_key"`
+	// Private media bucket: metadata stores object keys, the web server makes links to the files
+	Private bool `yaml:"private"`
+	// MediaLinks is "presign" or "proxy" through /media/{key}
+	MediaLinks string        `yaml:"media_links"`
+	PresignTTL time.Duration `yaml:"presign_ttl"`
+	// ProxySecret signs /media/{key} links, a random one is made on start when empty
+	ProxySecret string `yaml:"proxy_secret"`
+	// FeedLinkTTL is the lifetime of the /media/{key} links in the feeds
+	FeedLinkTTL time.Duration `yaml:"feed_link_ttl"`
+}
+
+type Retention struct {
+	Interval  time.Duration `yaml:"interval"`
+	BatchSize int           `yaml:"batch_size"`
+	// Archive exports purged messages to ArchiveBucket as gzipped JSONL before deleting them
+	Archive       bool   `yaml:"archive"`
+	ArchiveBucket string `yaml:"archive_bucket"`
+	// Policies by scope: "all", a source kind or "<kind>:<key>", the most specific one applies
+	Policies map[string]RetentionPolicy `yaml:"policies"`
+}
+
+// RetentionPolicy zero values keep messages forever
+type RetentionPolicy struct {
+	Days  int `yaml:"days"`
+	Items int `yaml:"items"`
+}
+
+// Outbox zero values use the defaults
+type Outbox struct {
+	// Interval reads the outbox when no commit woke the relay
+	Interval  time.Duration `yaml:"interval"`
+	BatchSize int           `yaml:"batch_size"`
+	// Lease is how long one relay holds the claimed entries, undeleted ones are delivered again after it
+	Lease time.Duration `yaml:"lease"`
 }
 
 type Redis struct {
@@ -0,0 +1,71 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// Bus delivers the events in-process, the outbox relay publishes them after the commit
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[chan Event]chan struct{}
+	log  *slog.Logger
+}
+
+func New(log *slog.Logger) *Bus {
+	return &Bus{
+		subs: make(map[chan Event]chan struct{}),
+		log:  log,
+	}
+}
+
+// Subscribe returns the events published after the call, cancel stops the delivery and closes the channel
+func (b *Bus) Subscribe(buf int) (<-chan Event, func()) {
+	ch := make(chan Event, buf)
+	done := make(chan struct{})
+
+	b.mu.Lock()
+	b.subs[ch] = done
+	b.mu.Unlock()
+
+	var once sync.Once
+
+	cancel := func() {
+		once.Do(func() {
+			// Releases a Publish waiting for this subscriber before taking the lock
+			close(done)
+
+			b.mu.Lock()
+			delete(b.subs, ch)
+			close(ch)
+			b.mu.Unlock()
+		})
+	}
+
+	return ch, cancel
+}
+
+// Publish waits until every subscriber received the events or ctx is done.
+// Returns the number of events from the start of evs received by all subscribers,
+// a cancelled subscriber counts as received
+func (b *Bus) Publish(ctx context.Context, evs ...Event) int {
+	const fn = "events.Bus.Publish"
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for i, ev := range evs {
+		for ch, done := range b.subs {
+			select {
+			case ch <- ev:
+			case <-done:
+			case <-ctx.Done():
+				b.log.Warn("events not delivered, subscriber is full", slog.Int("count", len(evs)-i), slog.String("fn", fn))
+				return i
+			}
+		}
+	}
+
+	return len(evs)
+}
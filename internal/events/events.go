@@ -0,0 +1,33 @@
+package events
+
+import (
+	"project/internal/models"
+)
+
+// Event is one of NewsPublished, NewsUpdated or NewsDeleted.
+// Events are delivered at least once, a redelivered event has the same Key
+type Event interface {
+	IdempotencyKey() string
+}
+
+// NewsPublished the news was added to the feed, backfilled news are published too
+type NewsPublished struct {
+	Key  string
+	News models.WebMessage
+}
+
+// NewsUpdated the source message was edited and the news replaced
+type NewsUpdated struct {
+	Key  string
+	News models.WebMessage
+}
+
+// NewsDeleted the news was removed from the feed
+type NewsDeleted struct {
+	Key string
+	ID  int64
+}
+
+func (ev NewsPublished) IdempotencyKey() string { return ev.Key }
+func (ev NewsUpdated) IdempotencyKey() string   { return ev.Key }
+func (ev NewsDeleted) IdempotencyKey() string   { return ev.Key }
@@ -0,0 +1,161 @@
+package files_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"project/internal/config"
+	"project/internal/files"
+	"project/internal/files/localfs"
+	"project/internal/files/memory"
+	"testing"
+	"time"
+)
+
+// driver is the part of the file storage the web server and the media store use
+type driver interface {
+	NewBucket(ctx context.Context, bucketName string, options files.MakeBucketOptions) error
+	SaveFile(ctx context.Context, bucketName, fileName string, reader io.Reader, options files.PutObjectOptions) (string, error)
+	OpenFile(ctx context.Context, bucketName, fileName string) (io.ReadSeekCloser, files.ObjectInfo, error)
+	GetFile(ctx context.Context, bucketName, fileName string, options files.GetObjectOptions) ([]byte, error)
+	DeleteFile(ctx context.Context, bucketName, fileName string, options files.RemoveObjectOptions) error
+}
+
+func drivers(t *testing.T) map[string]driver {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	fs, err := localfs.New(&config.Files{Dir: t.TempDir()}, "", log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]driver{
+		files.DriverLocalFS: fs,
+		files.DriverMemory:  memory.New("", log),
+	}
+}
+
+func TestDriversSameSemantics(t *testing.T) {
+	const bucket = "media"
+
+	ctx := context.Background()
+	content := []byte("not really a webp")
+	sum := md5.Sum(content)
+	etag := hex.EncodeToString(sum[:])
+
+	for name, d := range drivers(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := d.NewBucket(ctx, bucket, files.MakeBucketOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := d.NewBucket(ctx, bucket, files.MakeBucketOptions{}); !errors.Is(err, files.ErrBucketIsExists) {
+				t.Errorf("NewBucket() again error = %v, want ErrBucketIsExists", err)
+			}
+
+			before := time.Now().Add(-time.Second)
+
+			// The content type of the options wins over the extension
+			if _, err := d.SaveFile(ctx, bucket, "ab/file.bin", bytes.NewReader(content), files.PutObjectOptions{ContentType: "image/webp"}); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := d.SaveFile(ctx, bucket, "ab/file.png", bytes.NewReader(content), files.PutObjectOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			for file, contentType := range map[string]string{"ab/file.bin": "image/webp", "ab/file.png": "image/png"} {
+				r, info, err := d.OpenFile(ctx, bucket, file)
+				if err != nil {
+					t.Fatalf("OpenFile(%s) error = %v", file, err)
+				}
+
+				data, err := io.ReadAll(r)
+				r.Close()
+
+				if err != nil || !bytes.Equal(data, content) {
+					t.Errorf("OpenFile(%s) content = %q, %v", file, data, err)
+				}
+
+				if info.ContentType != contentType || info.ETag != etag || info.Size != int64(len(content)) || info.ModTime.Before(before) {
+					t.Errorf("OpenFile(%s) info = %+v, want %s, %s, %d", file, info, contentType, etag, len(content))
+				}
+			}
+
+			if data, err := d.GetFile(ctx, bucket, "ab/file.bin", files.GetObjectOptions{}); err != nil || !bytes.Equal(data, content) {
+				t.Errorf("GetFile() = %q, %v", data, err)
+			}
+
+			if _, _, err := d.OpenFile(ctx, bucket, "ab/missing"); !errors.Is(err, files.ErrFileNotFound) {
+				t.Errorf("OpenFile(missing) error = %v, want ErrFileNotFound", err)
+			}
+
+			if _, _, err := d.OpenFile(ctx, "other", "ab/file.bin"); !errors.Is(err, files.ErrBucketNotFound) {
+				t.Errorf("OpenFile(other bucket) error = %v, want ErrBucketNotFound", err)
+			}
+
+			if _, err := d.SaveFile(ctx, bucket, "../escape", bytes.NewReader(content), files.PutObjectOptions{}); !errors.Is(err, files.ErrBadFileName) {
+				t.Errorf("SaveFile(../escape) error = %v, want ErrBadFileName", err)
+			}
+
+			if err := d.DeleteFile(ctx, bucket, "ab/file.bin", files.RemoveObjectOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, _, err := d.OpenFile(ctx, bucket, "ab/file.bin"); !errors.Is(err, files.ErrFileNotFound) {
+				t.Errorf("OpenFile(deleted) error = %v, want ErrFileNotFound", err)
+			}
+
+			if err := d.DeleteFile(ctx, bucket, "ab/file.bin", files.RemoveObjectOptions{}); err != nil {
+				t.Errorf("DeleteFile(missing) error = %v, want nil", err)
+			}
+		})
+	}
+}
+
+// Files saved before localfs kept the metadata get it on the first read
+func TestLocalFSWithoutMeta(t *testing.T) {
+	dir := t.TempDir()
+
+	fs, err := localfs.New(&config.Files{Dir: dir}, "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "media", "ab"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	content := []byte("old file")
+	if err := os.WriteFile(filepath.Join(dir, "media", "ab", "old.jpg"), content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := md5.Sum(content)
+
+	for range 2 {
+		r, info, err := fs.OpenFile(context.Background(), "media", "ab/old.jpg")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, _ := io.ReadAll(r)
+		r.Close()
+
+		if !bytes.Equal(data, content) || info.ContentType != "image/jpeg" || info.ETag != hex.EncodeToString(sum[:]) {
+			t.Errorf("OpenFile() = %q, %+v", data, info)
+		}
+	}
+
+	if err := fs.NewBucket(context.Background(), ".meta", files.MakeBucketOptions{}); !errors.Is(err, files.ErrBadFileName) {
+		t.Errorf("NewBucket(.meta) error = %v, want ErrBadFileName", err)
+	}
+}
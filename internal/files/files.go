@@ -2,10 +2,22 @@ package files
 
 import (
 	"errors"
+	"time"
+)
+
+// Drivers of file_storage.driver
+const (
+	DriverMinio   = "minio"
+	DriverLocalFS = "localfs"
+	DriverMemory  = "memory"
 )
 
 var (
-	ErrBucketIsExists = errors.New("bucket is exists")
+	ErrBucketIsExists      = errors.New("bucket is exists")
+	ErrBucketNotFound      = errors.New("bucket not found")
+	ErrFileNotFound        = errors.New("file not found")
+	ErrBadFileName         = errors.New("bad file name")
+	ErrPresignNotSupported = errors.New("presigned urls are not supported")
 )
 
 type MakeBucketOptions struct{}
@@ -17,3 +29,11 @@ type PutObjectOptions struct {
 type GetObjectOptions struct{}
 
 type RemoveObjectOptions struct{}
+
+// ObjectInfo describes an opened file
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+	ETag        string
+	ModTime     time.Time
+}
@@ -0,0 +1,49 @@
+package localfs
+
+import (
+	"log/slog"
+	"os"
+	"project/internal/config"
+	"project/pkg/e"
+)
+
+const (
+	defaultDir = "data/files"
+
+	// metaDir keeps the content type and the ETag of every file, a tree per bucket
+	metaDir = ".meta"
+)
+
+type fileMeta struct {
+	ContentType string `json:"content_type"`
+	ETag        string `json:"etag"`
+}
+
+// Files keeps every bucket as a directory under dir, the web server serves the files
+type Files struct {
+	dir string
+	// baseURL is the web server address, links are relative when it is empty
+	baseURL string
+	log     *slog.Logger
+}
+
+func New(cfg *config.Files, baseURL string, log *slog.Logger) (*Files, error) {
+	const fn = "localfs.New"
+
+	dir := cfg.Dir
+	if dir == "" {
+		dir = defaultDir
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	log.Info(fn, slog.String("[OK]", "Local file storage successfully started"), slog.String("dir", dir))
+
+	return &Files{
+		dir:     dir,
+		baseURL: baseURL,
+		log:     log,
+	}, nil
+}
@@ -0,0 +1,296 @@
+package localfs
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"project/internal/files"
+	"project/internal/pkg/logger/sl"
+	"project/pkg/e"
+	"strings"
+	"time"
+)
+
+func (f *Files) NewBucket(ctx context.Context, bucketName string, options files.MakeBucketOptions) error {
+	const fn = "localfs.NewBucket"
+
+	dir, err := f.bucketDir(bucketName)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return e.Wrap(fn, files.ErrBucketIsExists)
+		}
+
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+// SaveFile writes the file to a temporary one first, readers never see a partly written file.
+// The content type and the ETag, the MD5 of the content like the S3 one, are kept in the metadata directory
+func (f *Files) SaveFile(ctx context.Context, bucketName, fileName string, reader io.Reader, options files.PutObjectOptions) (string, error) {
+	const fn = "localfs.SaveFile"
+
+	name, err := f.filePath(bucketName, fileName)
+	if err != nil {
+		return "", e.Wrap(fn, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return "", e.Wrap(fn, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return "", e.Wrap(fn, err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	hash := md5.New()
+
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), reader); err != nil {
+		tmp.Close()
+		return "", e.Wrap(fn, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return "", e.Wrap(fn, err)
+	}
+
+	meta := fileMeta{
+		ContentType: options.ContentType,
+		ETag:        hex.EncodeToString(hash.Sum(nil)),
+	}
+
+	if meta.ContentType == "" {
+		meta.ContentType = mime.TypeByExtension(path.Ext(fileName))
+	}
+
+	if err := f.writeMeta(bucketName, fileName, meta); err != nil {
+		return "", e.Wrap(fn, err)
+	}
+
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return "", e.Wrap(fn, err)
+	}
+
+	return f.GetFileUrl(bucketName, fileName)
+}
+
+func (f *Files) GetFileUrl(bucketName, fileName string) (string, error) {
+	const fn = "localfs.GetFileUrl"
+
+	res, err := files.ServedFileUrl(f.baseURL, bucketName, fileName)
+	if err != nil {
+		return "", e.Wrap(fn, err)
+	}
+
+	return res, nil
+}
+
+// ObjectName Returns the file name of a url made by SaveFile or GetFileUrl, false for urls of other hosts and buckets
+func (f *Files) ObjectName(bucketName, fileUrl string) (string, bool) {
+	return files.ServedObjectName(f.baseURL, bucketName, fileUrl)
+}
+
+// PresignedFileUrl is not supported, private files are proxied by the web server
+func (f *Files) PresignedFileUrl(ctx context.Context, bucketName, fileName string, ttl time.Duration) (string, error) {
+	return "", e.Wrap("localfs.PresignedFileUrl", files.ErrPresignNotSupported)
+}
+
+// OpenFile Returns a seekable reader of the file, the caller closes it
+func (f *Files) OpenFile(ctx context.Context, bucketName, fileName string) (io.ReadSeekCloser, files.ObjectInfo, error) {
+	const fn = "localfs.OpenFile"
+
+	name, err := f.filePath(bucketName, fileName)
+	if err != nil {
+		return nil, files.ObjectInfo{}, e.Wrap(fn, err)
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, files.ObjectInfo{}, e.Wrap(fn, notFound(err))
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, files.ObjectInfo{}, e.Wrap(fn, err)
+	}
+
+	if stat.IsDir() {
+		file.Close()
+		return nil, files.ObjectInfo{}, e.Wrap(fn, files.ErrFileNotFound)
+	}
+
+	meta, err := f.readMeta(bucketName, fileName, file)
+	if err != nil {
+		file.Close()
+		return nil, files.ObjectInfo{}, e.Wrap(fn, err)
+	}
+
+	return file, files.ObjectInfo{
+		Size:        stat.Size(),
+		ContentType: meta.ContentType,
+		ETag:        meta.ETag,
+		ModTime:     stat.ModTime(),
+	}, nil
+}
+
+func (f *Files) GetFile(ctx context.Context, bucketName, fileName string, options files.GetObjectOptions) ([]byte, error) {
+	const fn = "localfs.GetFile"
+
+	name, err := f.filePath(bucketName, fileName)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, e.Wrap(fn, notFound(err))
+	}
+
+	return data, nil
+}
+
+// DeleteFile does nothing for missing files like S3 does
+func (f *Files) DeleteFile(ctx context.Context, bucketName, fileName string, options files.RemoveObjectOptions) error {
+	const fn = "localfs.DeleteFile"
+
+	name, err := f.filePath(bucketName, fileName)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return e.Wrap(fn, err)
+	}
+
+	if err := os.Remove(f.metaPath(bucketName, fileName)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+func (f *Files) bucketDir(bucketName string) (string, error) {
+	// Dot names are left for the metadata directory, S3 does not allow them either
+	if !files.ValidName(bucketName) || strings.Contains(bucketName, "/") || strings.HasPrefix(bucketName, ".") {
+		return "", files.ErrBadFileName
+	}
+
+	return filepath.Join(f.dir, bucketName), nil
+}
+
+// filePath returns the path of the file in an existing bucket, names can not leave the bucket
+func (f *Files) filePath(bucketName, fileName string) (string, error) {
+	dir, err := f.bucketDir(bucketName)
+	if err != nil {
+		return "", err
+	}
+
+	if !files.ValidName(fileName) {
+		return "", files.ErrBadFileName
+	}
+
+	if stat, err := os.Stat(dir); err != nil || !stat.IsDir() {
+		return "", files.ErrBucketNotFound
+	}
+
+	return filepath.Join(dir, filepath.FromSlash(fileName)), nil
+}
+
+// metaPath returns the metadata file of a file, the names are checked by filePath
+func (f *Files) metaPath(bucketName, fileName string) string {
+	return filepath.Join(f.dir, metaDir, bucketName, filepath.FromSlash(fileName)+".json")
+}
+
+// writeMeta replaces the metadata of the file like SaveFile replaces the file
+func (f *Files) writeMeta(bucketName, fileName string, meta fileMeta) error {
+	name := f.metaPath(bucketName, fileName)
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
+
+// readMeta returns the metadata of the opened file. Files saved before the metadata was kept
+// get it from the extension and the content, it is written for the next reads
+func (f *Files) readMeta(bucketName, fileName string, file *os.File) (fileMeta, error) {
+	var meta fileMeta
+
+	data, err := os.ReadFile(f.metaPath(bucketName, fileName))
+	if err == nil {
+		return meta, json.Unmarshal(data, &meta)
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		return fileMeta{}, err
+	}
+
+	hash := md5.New()
+
+	if _, err := io.Copy(hash, file); err != nil {
+		return fileMeta{}, err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fileMeta{}, err
+	}
+
+	meta = fileMeta{
+		ContentType: mime.TypeByExtension(path.Ext(fileName)),
+		ETag:        hex.EncodeToString(hash.Sum(nil)),
+	}
+
+	if err := f.writeMeta(bucketName, fileName, meta); err != nil {
+		f.log.Warn("localfs.readMeta", sl.Err(err), slog.String("file", fileName))
+	}
+
+	return meta, nil
+}
+
+func notFound(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return files.ErrFileNotFound
+	}
+
+	return err
+}
@@ -0,0 +1,35 @@
+package memory
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Files keeps the buckets in memory, for tests and development, the files are lost on restart
+type Files struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string]object
+	// baseURL is the web server address, links are relative when it is empty
+	baseURL string
+	log     *slog.Logger
+}
+
+type object struct {
+	data        []byte
+	contentType string
+	etag        string
+	modTime     time.Time
+}
+
+func New(baseURL string, log *slog.Logger) *Files {
+	const fn = "memory.New"
+
+	log.Info(fn, slog.String("[OK]", "In-memory file storage successfully started"))
+
+	return &Files{
+		buckets: make(map[string]map[string]object),
+		baseURL: baseURL,
+		log:     log,
+	}
+}
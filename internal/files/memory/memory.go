@@ -0,0 +1,176 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"mime"
+	"path"
+	"project/internal/files"
+	"project/pkg/e"
+	"strings"
+	"time"
+)
+
+func (f *Files) NewBucket(ctx context.Context, bucketName string, options files.MakeBucketOptions) error {
+	const fn = "memory.NewBucket"
+
+	if !validBucket(bucketName) {
+		return e.Wrap(fn, files.ErrBadFileName)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.buckets[bucketName]; ok {
+		return e.Wrap(fn, files.ErrBucketIsExists)
+	}
+
+	f.buckets[bucketName] = make(map[string]object)
+
+	return nil
+}
+
+// SaveFile stores a copy of the content, the ETag is its MD5 like the S3 one
+func (f *Files) SaveFile(ctx context.Context, bucketName, fileName string, reader io.Reader, options files.PutObjectOptions) (string, error) {
+	const fn = "memory.SaveFile"
+
+	if !validBucket(bucketName) || !files.ValidName(fileName) {
+		return "", e.Wrap(fn, files.ErrBadFileName)
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", e.Wrap(fn, err)
+	}
+
+	sum := md5.Sum(data)
+
+	obj := object{
+		data:        data,
+		contentType: options.ContentType,
+		etag:        hex.EncodeToString(sum[:]),
+		modTime:     time.Now(),
+	}
+
+	if obj.contentType == "" {
+		obj.contentType = mime.TypeByExtension(path.Ext(fileName))
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, ok := f.buckets[bucketName]
+	if !ok {
+		return "", e.Wrap(fn, files.ErrBucketNotFound)
+	}
+
+	bucket[fileName] = obj
+
+	return f.GetFileUrl(bucketName, fileName)
+}
+
+func (f *Files) GetFileUrl(bucketName, fileName string) (string, error) {
+	const fn = "memory.GetFileUrl"
+
+	res, err := files.ServedFileUrl(f.baseURL, bucketName, fileName)
+	if err != nil {
+		return "", e.Wrap(fn, err)
+	}
+
+	return res, nil
+}
+
+// ObjectName Returns the file name of a url made by SaveFile or GetFileUrl, false for urls of other hosts and buckets
+func (f *Files) ObjectName(bucketName, fileUrl string) (string, bool) {
+	return files.ServedObjectName(f.baseURL, bucketName, fileUrl)
+}
+
+// PresignedFileUrl is not supported, private files are proxied by the web server
+func (f *Files) PresignedFileUrl(ctx context.Context, bucketName, fileName string, ttl time.Duration) (string, error) {
+	return "", e.Wrap("memory.PresignedFileUrl", files.ErrPresignNotSupported)
+}
+
+// OpenFile Returns a seekable reader of the file, the caller closes it
+func (f *Files) OpenFile(ctx context.Context, bucketName, fileName string) (io.ReadSeekCloser, files.ObjectInfo, error) {
+	const fn = "memory.OpenFile"
+
+	obj, err := f.get(bucketName, fileName)
+	if err != nil {
+		return nil, files.ObjectInfo{}, e.Wrap(fn, err)
+	}
+
+	return nopCloser{bytes.NewReader(obj.data)}, files.ObjectInfo{
+		Size:        int64(len(obj.data)),
+		ContentType: obj.contentType,
+		ETag:        obj.etag,
+		ModTime:     obj.modTime,
+	}, nil
+}
+
+// GetFile Returns a copy of the content
+func (f *Files) GetFile(ctx context.Context, bucketName, fileName string, options files.GetObjectOptions) ([]byte, error) {
+	const fn = "memory.GetFile"
+
+	obj, err := f.get(bucketName, fileName)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	return bytes.Clone(obj.data), nil
+}
+
+// DeleteFile does nothing for missing files like S3 does
+func (f *Files) DeleteFile(ctx context.Context, bucketName, fileName string, options files.RemoveObjectOptions) error {
+	const fn = "memory.DeleteFile"
+
+	if !validBucket(bucketName) || !files.ValidName(fileName) {
+		return e.Wrap(fn, files.ErrBadFileName)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, ok := f.buckets[bucketName]
+	if !ok {
+		return e.Wrap(fn, files.ErrBucketNotFound)
+	}
+
+	delete(bucket, fileName)
+
+	return nil
+}
+
+// get returns the stored object, its data is never changed after SaveFile
+func (f *Files) get(bucketName, fileName string) (object, error) {
+	if !validBucket(bucketName) || !files.ValidName(fileName) {
+		return object{}, files.ErrBadFileName
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	bucket, ok := f.buckets[bucketName]
+	if !ok {
+		return object{}, files.ErrBucketNotFound
+	}
+
+	obj, ok := bucket[fileName]
+	if !ok {
+		return object{}, files.ErrFileNotFound
+	}
+
+	return obj, nil
+}
+
+func validBucket(bucketName string) bool {
+	return files.ValidName(bucketName) && !strings.Contains(bucketName, "/")
+}
+
+type nopCloser struct {
+	*bytes.Reader
+}
+
+func (nopCloser) Close() error { return nil }
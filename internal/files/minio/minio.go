@@ -3,11 +3,15 @@ package minio
 import (
 	"bytes"
 	"context"
+	"errors"
 	"github.com/minio/minio-go/v7"
 	"io"
+	"net/http"
 	"net/url"
 	"project/internal/files"
 	"project/pkg/e"
+	"strings"
+	"time"
 )
 
 func (f *Files) NewBucket(ctx context.Context, bucketName string, options files.MakeBucketOptions) error {
@@ -36,7 +40,7 @@ func (f *Files) SaveFile(ctx context.Context, bucketName, fileName string, reade
 
 	info, err := f.db.PutObject(ctx, bucketName, fileName, reader, -1, opts)
 	if err != nil {
-		return "", e.Wrap(fn, err)
+		return "", e.Wrap(fn, filesErr(err))
 	}
 
 	return info.Location, nil
@@ -53,6 +57,57 @@ func (f *Files) GetFileUrl(bucketName, fileName string) (string, error) {
 	return res, nil
 }
 
+// ObjectName Returns the file name of a url made by SaveFile or GetFileUrl, false for urls of other hosts and buckets
+func (f *Files) ObjectName(bucketName, fileUrl string) (string, bool) {
+	u, err := url.Parse(fileUrl)
+	if err != nil || u.Host != f.db.EndpointURL().Host {
+		return "", false
+	}
+
+	name, ok := strings.CutPrefix(u.Path, "/"+bucketName+"/")
+	if !ok || name == "" {
+		return "", false
+	}
+
+	return name, true
+}
+
+// PresignedFileUrl Returns a link to a file of a private bucket valid for ttl
+func (f *Files) PresignedFileUrl(ctx context.Context, bucketName, fileName string, ttl time.Duration) (string, error) {
+	const fn = "minio.PresignedFileUrl"
+
+	u, err := f.db.PresignedGetObject(ctx, bucketName, fileName, ttl, nil)
+	if err != nil {
+		return "", e.Wrap(fn, err)
+	}
+
+	return u.String(), nil
+}
+
+// OpenFile Returns a seekable reader of the file, the caller closes it
+func (f *Files) OpenFile(ctx context.Context, bucketName, fileName string) (io.ReadSeekCloser, files.ObjectInfo, error) {
+	const fn = "minio.OpenFile"
+
+	object, err := f.db.GetObject(ctx, bucketName, fileName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, files.ObjectInfo{}, e.Wrap(fn, err)
+	}
+
+	stat, err := object.Stat()
+	if err != nil {
+		object.Close()
+
+		return nil, files.ObjectInfo{}, e.Wrap(fn, filesErr(err))
+	}
+
+	return object, files.ObjectInfo{
+		Size:        stat.Size,
+		ContentType: stat.ContentType,
+		ETag:        stat.ETag,
+		ModTime:     stat.LastModified,
+	}, nil
+}
+
 func (f *Files) GetFile(ctx context.Context, bucketName, fileName string, options files.GetObjectOptions) ([]byte, error) {
 	const fn = "minio.GetFile"
 
@@ -61,10 +116,12 @@ func (f *Files) GetFile(ctx context.Context, bucketName, fileName string, option
 		return nil, e.Wrap(fn, err)
 	}
 
+	defer object.Close()
+
 	buf := new(bytes.Buffer)
 	_, err = buf.ReadFrom(object)
 	if err != nil {
-		return nil, e.Wrap(fn, err)
+		return nil, e.Wrap(fn, filesErr(err))
 	}
 
 	return buf.Bytes(), nil
@@ -75,8 +132,25 @@ func (f *Files) DeleteFile(ctx context.Context, bucketName, fileName string, opt
 
 	err := f.db.RemoveObject(ctx, bucketName, fileName, minio.RemoveObjectOptions{})
 	if err != nil {
-		return e.Wrap(fn, err)
+		return e.Wrap(fn, filesErr(err))
 	}
 
 	return nil
 }
+
+// filesErr maps the S3 errors the other drivers return too
+func filesErr(err error) error {
+	var resp minio.ErrorResponse
+	if !errors.As(err, &resp) {
+		return err
+	}
+
+	switch {
+	case resp.Code == "NoSuchBucket":
+		return files.ErrBucketNotFound
+	case resp.Code == "NoSuchKey" || resp.StatusCode == http.StatusNotFound:
+		return files.ErrFileNotFound
+	default:
+		return err
+	}
+}
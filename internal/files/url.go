@@ -0,0 +1,45 @@
+package files
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ServePath is the route of the web server serving files of the localfs and memory drivers:
+// GET /files/{bucket}/{name...}
+const ServePath = "/files/"
+
+// ServedFileUrl Returns the link to a file served by the web server, relative when baseURL is empty
+func ServedFileUrl(baseURL, bucketName, fileName string) (string, error) {
+	return url.JoinPath(strings.TrimSuffix(baseURL, "/")+ServePath, bucketName, fileName)
+}
+
+// ServedObjectName is the reverse of ServedFileUrl, false for urls of other hosts and buckets
+func ServedObjectName(baseURL, bucketName, fileUrl string) (string, bool) {
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return "", false
+	}
+
+	u, err := url.Parse(fileUrl)
+	if err != nil || u.Host != base.Host {
+		return "", false
+	}
+
+	name, ok := strings.CutPrefix(u.Path, base.Path+ServePath+bucketName+"/")
+	if !ok || name == "" {
+		return "", false
+	}
+
+	return name, true
+}
+
+// ValidName reports whether a bucket or file name is a clean relative slash-separated path
+func ValidName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, "\\") {
+		return false
+	}
+
+	return path.Clean(name) == name && name != "." && !strings.HasPrefix(name, "../") && name != ".."
+}
@@ -0,0 +1,227 @@
+package filter
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"project/internal/models"
+	"project/internal/pkg/logger/sl"
+	"project/internal/storage"
+	"project/pkg/e"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var hashtagRe = regexp.MustCompile(`#([\p{L}\p{N}_]+)`)
+
+// Load replaces cached matchers with the rules stored in the DB
+func (en *Engine) Load(ctx context.Context) error {
+	const fn = "filter.Load"
+
+	rules, err := en.db.GetFilterRules(ctx)
+	if err != nil && !errors.Is(err, storage.ErrNoRecordsFound) {
+		return e.Wrap(fn, err)
+	}
+
+	matchers := make(map[string]*matcher)
+
+	for _, rule := range rules {
+		m, ok := matchers[rule.Scope]
+		if !ok {
+			m = &matcher{hashtags: make(map[string]struct{})}
+			matchers[rule.Scope] = m
+		}
+
+		// Rules are validated on Add, a broken row edited by hand must not stop the app
+		if err := m.add(rule); err != nil {
+			en.log.Error(fn, sl.Err(err), slog.Int64("rule id", rule.ID))
+		}
+	}
+
+	en.mu.Lock()
+	defer en.mu.Unlock()
+
+	en.matchers = matchers
+	en.rules = rules
+
+	return nil
+}
+
+// Match reports whether a message from the source passes the global,
+// kind and source rules. Scopes without rules let everything through.
+func (en *Engine) Match(kind, key, text string, hasMedia bool) bool {
+	en.mu.RLock()
+	defer en.mu.RUnlock()
+
+	for _, scope := range []string{ScopeAll, kind, kind + ":" + key} {
+		m, ok := en.matchers[scope]
+		if !ok {
+			continue
+		}
+
+		if !m.match(text, hasMedia) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Add validates and stores the rule, then reloads the matchers
+func (en *Engine) Add(ctx context.Context, rule models.FilterRule) (int64, error) {
+	const fn = "filter.Add"
+
+	if rule.Scope == "" || strings.ContainsAny(rule.Scope, " \t\n") {
+		return 0, e.Wrap(fn, ErrBadScope)
+	}
+
+	if err := (&matcher{hashtags: make(map[string]struct{})}).add(rule); err != nil {
+		return 0, e.Wrap(fn, err)
+	}
+
+	id, err := en.db.InsertFilterRule(ctx, rule)
+	if err != nil {
+		return 0, e.Wrap(fn, err)
+	}
+
+	if err := en.Load(ctx); err != nil {
+		return 0, e.Wrap(fn, err)
+	}
+
+	return id, nil
+}
+
+func (en *Engine) Delete(ctx context.Context, id int64) error {
+	const fn = "filter.Delete"
+
+	if err := en.db.DeleteFilterRule(ctx, id); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := en.Load(ctx); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+// Rules returns cached rules, all of them when scope is empty
+func (en *Engine) Rules(scope string) []models.FilterRule {
+	en.mu.RLock()
+	defer en.mu.RUnlock()
+
+	var rules []models.FilterRule
+
+	for _, rule := range en.rules {
+		if scope == "" || rule.Scope == scope {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules
+}
+
+func (m *matcher) add(rule models.FilterRule) error {
+	value := strings.TrimSpace(rule.Value)
+
+	switch rule.Rule {
+	case RuleInclude, RuleExclude:
+		re, err := regexp.Compile(`(?i)` + value)
+		if err != nil || value == "" {
+			return ErrBadValue
+		}
+
+		if rule.Rule == RuleInclude {
+			m.include = append(m.include, re)
+		} else {
+			m.exclude = append(m.exclude, re)
+		}
+
+	case RuleKeyword, RuleStopword:
+		if value == "" {
+			return ErrBadValue
+		}
+
+		re := regexp.MustCompile(`(?i)` + regexp.QuoteMeta(value))
+
+		if rule.Rule == RuleKeyword {
+			m.include = append(m.include, re)
+		} else {
+			m.exclude = append(m.exclude, re)
+		}
+
+	case RuleMinLen, RuleMaxLen:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return ErrBadValue
+		}
+
+		if rule.Rule == RuleMinLen {
+			m.minLen = n
+		} else {
+			m.maxLen = n
+		}
+
+	case RuleMedia:
+		m.media = true
+
+	case RuleHashtag:
+		tag := strings.ToLower(strings.TrimPrefix(value, "#"))
+		if tag == "" {
+			return ErrBadValue
+		}
+
+		m.hashtags[tag] = struct{}{}
+
+	default:
+		return ErrUnknownRule
+	}
+
+	return nil
+}
+
+func (m *matcher) match(text string, hasMedia bool) bool {
+	if m.media && !hasMedia {
+		return false
+	}
+
+	length := utf8.RuneCountInString(text)
+
+	if length < m.minLen || (m.maxLen > 0 && length > m.maxLen) {
+		return false
+	}
+
+	for _, re := range m.exclude {
+		if re.MatchString(text) {
+			return false
+		}
+	}
+
+	if len(m.hashtags) > 0 && !m.hasHashtag(text) {
+		return false
+	}
+
+	if len(m.include) == 0 {
+		return true
+	}
+
+	for _, re := range m.include {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *matcher) hasHashtag(text string) bool {
+	for _, tag := range hashtagRe.FindAllStringSubmatch(text, -1) {
+		if _, ok := m.hashtags[strings.ToLower(tag[1])]; ok {
+			return true
+		}
+	}
+
+	return false
+}
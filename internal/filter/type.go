@@ -0,0 +1,67 @@
+package filter
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"project/internal/models"
+	"regexp"
+	"sync"
+)
+
+const (
+	// ScopeAll rules apply to every source
+	ScopeAll = "all"
+)
+
+// Rule kinds
+const (
+	RuleInclude  = "include"  // regex, the message must match one of include or keyword rules
+	RuleExclude  = "exclude"  // regex, the message must not match
+	RuleKeyword  = "keyword"  // plain word, same as include
+	RuleStopword = "stopword" // plain word, same as exclude
+	RuleMinLen   = "min_len"
+	RuleMaxLen   = "max_len"
+	RuleMedia    = "media"   // the message must have media
+	RuleHashtag  = "hashtag" // the message must have one of the hashtags
+)
+
+type Engine struct {
+	db Storage
+
+	// matchers precompiled rules by scope
+	matchers map[string]*matcher
+	rules    []models.FilterRule
+	mu       sync.RWMutex
+
+	log *slog.Logger
+}
+
+type Storage interface {
+	GetFilterRules(ctx context.Context) ([]models.FilterRule, error)
+	InsertFilterRule(ctx context.Context, rule models.FilterRule) (int64, error)
+	DeleteFilterRule(ctx context.Context, id int64) error
+}
+
+type matcher struct {
+	include  []*regexp.Regexp
+	exclude  []*regexp.Regexp
+	hashtags map[string]struct{}
+	minLen   int
+	maxLen   int
+	media    bool
+}
+
+var (
+	ErrUnknownRule = errors.New("unknown filter rule")
+	ErrBadValue    = errors.New("bad filter rule value")
+	ErrBadScope    = errors.New("bad filter scope")
+)
+
+func New(db Storage, log *slog.Logger) *Engine {
+	return &Engine{
+		db:       db,
+		matchers: make(map[string]*matcher),
+		log:      log,
+	}
+}
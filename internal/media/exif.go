@@ -0,0 +1,385 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+)
+
+var (
+	ErrBadJPEG = errors.New("bad jpeg")
+	ErrBadPNG  = errors.New("bad png")
+	ErrBadWebP = errors.New("bad webp")
+	ErrBadGIF  = errors.New("bad gif")
+)
+
+const (
+	jpegAPP1  = 0xe1 // Exif, XMP
+	jpegAPP13 = 0xed // Photoshop IRB, IPTC
+	jpegCOM   = 0xfe
+	jpegSOS   = 0xda
+
+	exifOrientationTag = 0x0112
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngMetaChunks may carry EXIF, GPS and editing history
+var pngMetaChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"iTXt": true,
+	"zTXt": true,
+	"tIME": true,
+}
+
+// stripJPEG removes Exif, XMP, IPTC and comment segments without re-encoding the image,
+// ICC profiles and Adobe color transforms are kept
+func stripJPEG(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return nil, ErrBadJPEG
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+
+	for i := 2; ; {
+		if i+4 > len(data) || data[i] != 0xff {
+			return nil, ErrBadJPEG
+		}
+
+		marker := data[i+1]
+
+		// Fill bytes before a marker
+		if marker == 0xff {
+			i++
+			continue
+		}
+
+		// The entropy-coded data follows, nothing to strip after it
+		if marker == jpegSOS {
+			return append(out, data[i:]...), nil
+		}
+
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) {
+			return nil, ErrBadJPEG
+		}
+
+		if marker != jpegAPP1 && marker != jpegAPP13 && marker != jpegCOM {
+			out = append(out, data[i:end]...)
+		}
+
+		i = end
+	}
+}
+
+// stripPNG removes EXIF and text chunks
+func stripPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, ErrBadPNG
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+
+	for i := len(pngSignature); i < len(data); {
+		// length, type, data, crc
+		if i+12 > len(data) {
+			return nil, ErrBadPNG
+		}
+
+		end := i + 12 + int(binary.BigEndian.Uint32(data[i:]))
+		if end > len(data) || end < i {
+			return nil, ErrBadPNG
+		}
+
+		if !pngMetaChunks[string(data[i+4:i+8])] {
+			out = append(out, data[i:end]...)
+		}
+
+		i = end
+	}
+
+	return out, nil
+}
+
+// webpMetaChunks carry EXIF and XMP, VP8X flags them in its first byte
+var webpMetaChunks = map[string]byte{
+	"EXIF": 0x08,
+	"XMP ": 0x04,
+}
+
+// stripWebP removes EXIF and XMP chunks and their VP8X flags, ICC profiles and animation are kept
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrBadWebP
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:12]...)
+
+	vp8x := -1
+
+	for i := 12; i < len(data); {
+		// fourcc, size, data padded to an even size
+		if i+8 > len(data) {
+			return nil, ErrBadWebP
+		}
+
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+
+		end := i + 8 + size + size&1
+		if end > len(data) || end < i {
+			return nil, ErrBadWebP
+		}
+
+		fourcc := string(data[i : i+4])
+
+		if fourcc == "VP8X" && size > 0 {
+			vp8x = len(out) + 8
+		}
+
+		if _, ok := webpMetaChunks[fourcc]; !ok {
+			out = append(out, data[i:end]...)
+		}
+
+		i = end
+	}
+
+	if vp8x >= 0 {
+		for _, flag := range webpMetaChunks {
+			out[vp8x] &^= flag
+		}
+	}
+
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+
+	return out, nil
+}
+
+const (
+	gifExtension    = 0x21
+	gifImage        = 0x2c
+	gifTrailer      = 0x3b
+	gifComment      = 0xfe
+	gifAppExtension = 0xff
+)
+
+// gifLoopApps are the application extensions that set the animation loop count
+var gifLoopApps = map[string]bool{
+	"NETSCAPE2.0": true,
+	"ANIMEXTS1.0": true,
+}
+
+// stripGIF removes comments and application extensions like XMP, except the loop count
+func stripGIF(data []byte) ([]byte, error) {
+	if len(data) < 13 || (string(data[:6]) != "GIF87a" && string(data[:6]) != "GIF89a") {
+		return nil, ErrBadGIF
+	}
+
+	// header, logical screen descriptor and the global color table
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+
+	if i > len(data) {
+		return nil, ErrBadGIF
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:i]...)
+
+	for i < len(data) {
+		start := i
+
+		switch data[i] {
+		case gifTrailer:
+			return append(out, gifTrailer), nil
+
+		case gifImage:
+			// descriptor, local color table, LZW code size, data sub-blocks
+			if i+10 > len(data) {
+				return nil, ErrBadGIF
+			}
+
+			i += 10
+			if data[i-1]&0x80 != 0 {
+				i += 3 << (data[i-1]&0x07 + 1)
+			}
+
+			end, ok := gifSubBlocks(data, i+1)
+			if !ok {
+				return nil, ErrBadGIF
+			}
+
+			out = append(out, data[start:end]...)
+			i = end
+
+		case gifExtension:
+			if i+2 > len(data) {
+				return nil, ErrBadGIF
+			}
+
+			label := data[i+1]
+
+			end, ok := gifSubBlocks(data, i+2)
+			if !ok {
+				return nil, ErrBadGIF
+			}
+
+			keep := label != gifComment
+			if label == gifAppExtension {
+				keep = i+14 <= end && data[i+2] == 11 && gifLoopApps[string(data[i+3:i+14])]
+			}
+
+			if keep {
+				out = append(out, data[start:end]...)
+			}
+
+			i = end
+
+		default:
+			return nil, ErrBadGIF
+		}
+	}
+
+	// Decoders accept a missing trailer, the stripped copy gets one
+	return append(out, gifTrailer), nil
+}
+
+// gifSubBlocks returns the end of the sub-blocks starting at i, after the zero terminator
+func gifSubBlocks(data []byte, i int) (int, bool) {
+	for i < len(data) {
+		size := int(data[i])
+		i++
+
+		if size == 0 {
+			return i, true
+		}
+
+		i += size
+	}
+
+	return 0, false
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG, 1 when it is missing
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data) && data[i] == 0xff; {
+		marker := data[i+1]
+		if marker == jpegSOS {
+			return 1
+		}
+
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) {
+			return 1
+		}
+
+		if marker == jpegAPP1 && bytes.HasPrefix(data[i+4:end], []byte("Exif\x00\x00")) {
+			return tiffOrientation(data[i+10 : end])
+		}
+
+		i = end
+	}
+
+	return 1
+}
+
+// tiffOrientation reads the orientation tag of the first IFD
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+
+	entries := int(order.Uint16(tiff[ifd:]))
+
+	for n := 0; n < entries; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+
+		if order.Uint16(tiff[entry:]) != exifOrientationTag {
+			continue
+		}
+
+		// SHORT value is stored in the first bytes of the value field
+		o := int(order.Uint16(tiff[entry+8:]))
+		if o < 1 || o > 8 {
+			return 1
+		}
+
+		return o
+	}
+
+	return 1
+}
+
+// orient applies the EXIF orientation, the camera stores the pixels as they were shot
+func orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	// 5-8 swap the sides
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // rotated 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // mirrored and rotated 270
+				dx, dy = y, x
+			case 6: // rotated 90
+				dx, dy = h-1-y, x
+			case 7: // mirrored and rotated 90
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 270
+				dx, dy = y, w-1-x
+			}
+
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+
+	return dst
+}
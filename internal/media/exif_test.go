@@ -0,0 +1,272 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+)
+
+const (
+	testEXIF = "Exif\x00\x00GPS 55.75,37.61"
+	testXMP  = "<x:xmpmeta>secret</x:xmpmeta>"
+)
+
+func riffChunk(fourcc string, data []byte) []byte {
+	chunk := binary.LittleEndian.AppendUint32([]byte(fourcc), uint32(len(data)))
+	chunk = append(chunk, data...)
+
+	if len(data)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+
+	return chunk
+}
+
+// webpWithMeta wraps a simple lossless WebP in VP8X with EXIF and XMP chunks
+func webpWithMeta(t *testing.T) []byte {
+	t.Helper()
+
+	simple, err := os.ReadFile("testdata/gopher.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(simple))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vp8x := make([]byte, 10)
+	vp8x[0] = webpMetaChunks["EXIF"] | webpMetaChunks["XMP "]
+	vp8x[4], vp8x[5], vp8x[6] = byte(cfg.Width-1), byte((cfg.Width-1)>>8), byte((cfg.Width-1)>>16)
+	vp8x[7], vp8x[8], vp8x[9] = byte(cfg.Height-1), byte((cfg.Height-1)>>8), byte((cfg.Height-1)>>16)
+
+	body := []byte("WEBP")
+	body = append(body, riffChunk("VP8X", vp8x)...)
+	body = append(body, simple[12:]...)
+	body = append(body, riffChunk("EXIF", []byte(testEXIF))...)
+	body = append(body, riffChunk("XMP ", []byte(testXMP))...)
+
+	return riffChunk("RIFF", body)
+}
+
+func TestStripWebP(t *testing.T) {
+	data := webpWithMeta(t)
+
+	want, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode source: %v", err)
+	}
+
+	got, err := stripWebP(data)
+	if err != nil {
+		t.Fatalf("stripWebP() error = %v", err)
+	}
+
+	if bytes.Contains(got, []byte("GPS")) || bytes.Contains(got, []byte("secret")) {
+		t.Error("stripWebP() kept the metadata")
+	}
+
+	if flags := got[20]; flags&(webpMetaChunks["EXIF"]|webpMetaChunks["XMP "]) != 0 {
+		t.Errorf("VP8X flags = %#x, want EXIF and XMP cleared", flags)
+	}
+
+	if size := binary.LittleEndian.Uint32(got[4:]); int(size) != len(got)-8 {
+		t.Errorf("RIFF size = %d, want %d", size, len(got)-8)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(got))
+	if err != nil || format != "webp" {
+		t.Fatalf("decode stripped: %v, %s", err, format)
+	}
+
+	assertSameImage(t, img, want)
+
+	if _, err := stripWebP(data[:len(data)-3]); err == nil {
+		t.Error("stripWebP() of a truncated file error = nil")
+	}
+}
+
+// gifWithMeta is an animated GIF with a comment and an XMP application extension
+func gifWithMeta(t *testing.T) []byte {
+	t.Helper()
+
+	palette := color.Palette{color.Black, color.White}
+
+	anim := &gif.GIF{LoopCount: 2}
+	for i := range 2 {
+		frame := image.NewPaletted(image.Rect(0, 0, 4, 3), palette)
+		frame.SetColorIndex(i, 1, 1)
+
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 10)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+
+	// After the header, the screen descriptor and the global color table
+	head := 13
+	if data[10]&0x80 != 0 {
+		head += 3 << (data[10]&0x07 + 1)
+	}
+
+	var meta []byte
+
+	meta = append(meta, gifExtension, gifComment, byte(len(testEXIF)))
+	meta = append(meta, testEXIF...)
+	meta = append(meta, 0)
+
+	meta = append(meta, gifExtension, gifAppExtension, 11)
+	meta = append(meta, "XMP DataXMP"...)
+	meta = append(meta, byte(len(testXMP)))
+	meta = append(meta, testXMP...)
+	meta = append(meta, 0)
+
+	return append(append(append([]byte{}, data[:head]...), meta...), data[head:]...)
+}
+
+func TestStripGIF(t *testing.T) {
+	data := gifWithMeta(t)
+
+	want, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode source: %v", err)
+	}
+
+	got, err := stripGIF(data)
+	if err != nil {
+		t.Fatalf("stripGIF() error = %v", err)
+	}
+
+	if bytes.Contains(got, []byte("GPS")) || bytes.Contains(got, []byte("secret")) {
+		t.Error("stripGIF() kept the metadata")
+	}
+
+	anim, err := gif.DecodeAll(bytes.NewReader(got))
+	if err != nil {
+		t.Fatalf("decode stripped: %v", err)
+	}
+
+	if anim.LoopCount != want.LoopCount || len(anim.Image) != len(want.Image) {
+		t.Fatalf("stripped loop count, frames = %d, %d, want %d, %d",
+			anim.LoopCount, len(anim.Image), want.LoopCount, len(want.Image))
+	}
+
+	for i := range anim.Image {
+		assertSameImage(t, anim.Image[i], want.Image[i])
+	}
+
+	if _, err := stripGIF(data[:len(data)-4]); err == nil {
+		t.Error("stripGIF() of a truncated file error = nil")
+	}
+}
+
+// jpegWithMeta is a JPEG with an Exif segment, broken cuts the scan data so it can not be decoded
+func jpegWithMeta(t *testing.T, broken bool) []byte {
+	t.Helper()
+
+	data, err := encodeJPEG(image.NewGray(image.Rect(0, 0, 16, 16)), 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app1 := []byte{0xff, jpegAPP1, 0, byte(2 + len(testEXIF))}
+	app1 = append(app1, testEXIF...)
+
+	res := append(append(append([]byte{}, data[:2]...), app1...), data[2:]...)
+	if broken {
+		res = res[:len(res)-10]
+	}
+
+	return res
+}
+
+func TestProcessStripsMeta(t *testing.T) {
+	s := &Store{log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	tests := []struct {
+		name  string
+		data  []byte
+		width int
+		err   error
+		isNil bool
+	}{
+		{name: "webp", data: webpWithMeta(t), width: 1},
+		{name: "gif", data: gifWithMeta(t), width: 4},
+		{name: "jpeg", data: jpegWithMeta(t, false), width: 16},
+		{name: "undecodable jpeg", data: jpegWithMeta(t, true)},
+		{name: "broken png", data: append(append([]byte{}, pngSignature...), "\x00\x00\x01\x00IHDR"...), err: ErrMetaNotStripped},
+		{name: "not an image", data: []byte("GPS secret"), isNil: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmp, err := os.CreateTemp(t.TempDir(), "media-*")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tmp.Close()
+
+			if _, err := tmp.Write(tt.data); err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := s.process(tmp)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("process() error = %v, want %v", err, tt.err)
+			}
+
+			if tt.err != nil || tt.isNil {
+				if res != nil {
+					t.Errorf("process() = %+v, want nil", res)
+				}
+
+				return
+			}
+
+			if res.data == nil || bytes.Contains(res.data, []byte("GPS")) || bytes.Contains(res.data, []byte("secret")) {
+				t.Error("process() kept the metadata")
+			}
+
+			if tt.width != 0 && res.width == 0 {
+				t.Errorf("process() width = 0, want the image measured")
+			}
+
+			if tt.width == 0 && (res.width != 0 || len(res.variants) != 0) {
+				t.Errorf("process() measured an undecodable image")
+			}
+		})
+	}
+}
+
+func assertSameImage(t *testing.T, got, want image.Image) {
+	t.Helper()
+
+	if got.Bounds() != want.Bounds() {
+		t.Fatalf("bounds = %v, want %v", got.Bounds(), want.Bounds())
+	}
+
+	b := want.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r1, g1, b1, a1 := got.At(x, y).RGBA()
+			r2, g2, b2, a2 := want.At(x, y).RGBA()
+
+			if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+				t.Fatalf("pixel (%d, %d) differs", x, y)
+			}
+		}
+	}
+}
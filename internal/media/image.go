@@ -0,0 +1,171 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/buckket/go-blurhash"
+	"golang.org/x/image/draw"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// maxImagePixels protects the memory from decompression bombs, larger images are stored as is
+	maxImagePixels = 50_000_000
+
+	thumbQuality    = 80
+	originalQuality = 90
+
+	blurhashWidth = 32
+	blurhashX     = 4
+	blurhashY     = 3
+)
+
+// thumbWidths are the widths of the resized copies, only the ones smaller than the image are made
+var thumbWidths = []int{320, 640, 1280}
+
+var (
+	ErrImageTooLarge   = errors.New("image is too large")
+	ErrMetaNotStripped = errors.New("image metadata not stripped")
+)
+
+type variant struct {
+	width  int
+	height int
+	data   []byte
+}
+
+type processed struct {
+	// data is the image without metadata
+	data     []byte
+	width    int
+	height   int
+	blurhash string
+	// variants are JPEG copies sorted by width
+	variants []variant
+}
+
+// isImage reports whether the content is an image the stage can decode
+func isImage(head []byte) bool {
+	switch http.DetectContentType(head) {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	default:
+		return false
+	}
+}
+
+// stripMeta removes the metadata without decoding the image, so it works for any size
+func stripMeta(data []byte) ([]byte, error) {
+	var (
+		res []byte
+		err error
+	)
+
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		res, err = stripJPEG(data)
+
+	case "image/png":
+		res, err = stripPNG(data)
+
+	case "image/webp":
+		res, err = stripWebP(data)
+
+	case "image/gif":
+		res, err = stripGIF(data)
+
+	default:
+		err = errors.New("unknown image format")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMetaNotStripped, err)
+	}
+
+	return res, nil
+}
+
+// processImage measures the image and makes the thumbnails and the blurhash,
+// stripped is the data without metadata from stripMeta
+func processImage(data, stripped []byte) (*processed, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	res := &processed{data: stripped}
+
+	// Browsers would show the pixels rotated once the orientation tag is stripped
+	if o := jpegOrientation(data); format == "jpeg" && o != 1 {
+		img = orient(img, o)
+
+		res.data, err = encodeJPEG(img, originalQuality)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	b := img.Bounds()
+	res.width, res.height = b.Dx(), b.Dy()
+
+	for _, w := range thumbWidths {
+		if w >= res.width {
+			break
+		}
+
+		h := max(1, res.height*w/res.width)
+
+		thumb, err := encodeJPEG(resize(img, w, h, draw.CatmullRom), thumbQuality)
+		if err != nil {
+			return nil, err
+		}
+
+		res.variants = append(res.variants, variant{width: w, height: h, data: thumb})
+	}
+
+	small := resize(img, blurhashWidth, max(1, res.height*blurhashWidth/max(1, res.width)), draw.ApproxBiLinear)
+
+	res.blurhash, err = blurhash.Encode(blurhashX, blurhashY, small)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// resize draws the image on white, JPEG has no transparency
+func resize(img image.Image, w, h int, scaler draw.Scaler) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	scaler.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Over, nil)
+
+	return dst
+}
+
+func encodeJPEG(img image.Image, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
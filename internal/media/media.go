@@ -0,0 +1,212 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"os"
+	"project/internal/files"
+	"project/internal/models"
+	"project/internal/pkg/logger/sl"
+	"project/internal/storage"
+	"project/pkg/e"
+	"strings"
+)
+
+// ReuseTg Returns the already stored Telegram file and takes a reference to it,
+// false when the file has to be downloaded
+func (s *Store) ReuseTg(ctx context.Context, fileUniqueID string) (models.MediaObject, bool, error) {
+	const fn = "media.ReuseTg"
+
+	if fileUniqueID == "" {
+		return models.MediaObject{}, false, nil
+	}
+
+	obj, err := s.db.AcquireTgMediaFile(ctx, fileUniqueID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoRecordsFound) {
+			return models.MediaObject{}, false, nil
+		}
+
+		return models.MediaObject{}, false, e.Wrap(fn, err)
+	}
+
+	s.log.Debug(fn, slog.String("file unique id", fileUniqueID), slog.String("object", obj.Name))
+
+	return obj, true, nil
+}
+
+// Save Stores the content under its SHA-256 and takes a reference to it, the same content is uploaded once.
+// Images are stored without EXIF with JPEG copies of smaller widths, an image that can not be stripped is refused.
+// fileUniqueID may be empty for files not from Telegram
+func (s *Store) Save(ctx context.Context, reader io.Reader, ext, fileUniqueID string) (models.MediaObject, error) {
+	const fn = "media.Save"
+
+	// The object name depends on the hash, the content is kept on disk until it is known
+	tmp, err := os.CreateTemp("", "media-*")
+	if err != nil {
+		return models.MediaObject{}, e.Wrap(fn, err)
+	}
+
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	hash := sha256.New()
+
+	size, err := io.Copy(io.MultiWriter(tmp, hash), reader)
+	if err != nil {
+		return models.MediaObject{}, e.Wrap(fn, err)
+	}
+
+	// The hash is of the received content, a known file is not processed again
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	obj, err := s.db.AcquireMediaObject(ctx, sum, fileUniqueID)
+	if err == nil {
+		s.log.Debug(fn, slog.String("object", obj.Name), slog.String("[OK]", "reused"))
+
+		return obj, nil
+	}
+
+	if !errors.Is(err, storage.ErrNoRecordsFound) {
+		return models.MediaObject{}, e.Wrap(fn, err)
+	}
+
+	ext = strings.ToLower(ext)
+
+	obj = models.MediaObject{
+		SHA256:      sum,
+		Name:        sum[:2] + "/" + sum + ext,
+		ContentType: mime.TypeByExtension(ext),
+		Size:        size,
+	}
+
+	var body io.Reader = tmp
+
+	// An image is never stored with its metadata
+	img, err := s.process(tmp)
+	if err != nil {
+		return models.MediaObject{}, e.Wrap(fn, err)
+	}
+
+	if img != nil {
+		if img.data != nil {
+			body = bytes.NewReader(img.data)
+			obj.Size = int64(len(img.data))
+		}
+
+		obj.Width, obj.Height, obj.Blurhash = img.width, img.height, img.blurhash
+
+		for _, v := range img.variants {
+			name := fmt.Sprintf("%s/%s_w%d.jpg", sum[:2], sum, v.width)
+
+			u, err := s.fdb.SaveFile(ctx, models.MediaBucket, name, bytes.NewReader(v.data), files.PutObjectOptions{ContentType: "image/jpeg"})
+			if err != nil {
+				return models.MediaObject{}, e.Wrap(fn, err)
+			}
+
+			obj.Variants = append(obj.Variants, models.MediaVariant{
+				Name:   name,
+				Url:    s.url(u),
+				Width:  v.width,
+				Height: v.height,
+			})
+		}
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return models.MediaObject{}, e.Wrap(fn, err)
+	}
+
+	u, err := s.fdb.SaveFile(ctx, models.MediaBucket, obj.Name, body, files.PutObjectOptions{ContentType: obj.ContentType})
+	if err != nil {
+		return models.MediaObject{}, e.Wrap(fn, err)
+	}
+
+	obj.Url = s.url(u)
+
+	obj, err = s.db.InsertMediaObject(ctx, obj, fileUniqueID)
+	if err != nil {
+		return models.MediaObject{}, e.Wrap(fn, err)
+	}
+
+	return obj, nil
+}
+
+// url drops the links of the private bucket, nobody can open them
+func (s *Store) url(u string) string {
+	if s.private {
+		return ""
+	}
+
+	return u
+}
+
+// process returns nil for files that are not images, the file is read from the start.
+// An image that can not be decoded is still stripped and stored without the copies
+func (s *Store) process(tmp *os.File) (*processed, error) {
+	const fn = "media.process"
+
+	head := make([]byte, 512)
+
+	n, err := tmp.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	if !isImage(head[:n]) {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	stripped, err := stripMeta(data)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := processImage(data, stripped)
+	if err != nil {
+		s.log.Warn(fn, sl.Err(err), slog.String("[OK]", "stored without copies"))
+
+		return &processed{data: stripped}, nil
+	}
+
+	return img, nil
+}
+
+// MetaPair describes the stored file for the news, image copies and the original are the sizes for srcset.
+// The keys let the web server make links when the bucket is private
+func MetaPair(obj models.MediaObject, msgType string) models.MetaPair {
+	meta := models.MetaPair{
+		Url:      obj.Url,
+		Type:     msgType,
+		Key:      obj.Name,
+		Width:    obj.Width,
+		Height:   obj.Height,
+		Blurhash: obj.Blurhash,
+	}
+
+	if len(obj.Variants) == 0 {
+		return meta
+	}
+
+	for _, v := range obj.Variants {
+		meta.Sizes = append(meta.Sizes, models.MediaSize{Url: v.Url, Key: v.Name, Width: v.Width, Height: v.Height})
+	}
+
+	meta.Sizes = append(meta.Sizes, models.MediaSize{Url: obj.Url, Key: obj.Name, Width: obj.Width, Height: obj.Height})
+
+	return meta
+}
@@ -0,0 +1,37 @@
+package media
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"project/internal/files"
+	"project/internal/models"
+)
+
+// Store keeps media content-addressed: one object per content, shared by all messages with it
+type Store struct {
+	db  Storage
+	fdb Files
+	// private bucket urls are not stored, the web server makes links from the object names
+	private bool
+	log     *slog.Logger
+}
+
+type Storage interface {
+	AcquireTgMediaFile(ctx context.Context, fileUniqueID string) (models.MediaObject, error)
+	AcquireMediaObject(ctx context.Context, sha256, fileUniqueID string) (models.MediaObject, error)
+	InsertMediaObject(ctx context.Context, obj models.MediaObject, fileUniqueID string) (models.MediaObject, error)
+}
+
+type Files interface {
+	SaveFile(ctx context.Context, bucketName, fileName string, reader io.Reader, options files.PutObjectOptions) (string, error)
+}
+
+func New(db Storage, fdb Files, private bool, log *slog.Logger) *Store {
+	return &Store{
+		db:      db,
+		fdb:     fdb,
+		private: private,
+		log:     log,
+	}
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"errors"
 	"time"
 )
@@ -9,6 +10,7 @@ const (
 	RoleIDsMapName                 = "RoleIDs"
 	MediaGroupMapName              = "mediaGroupIDs"
 	GetPermissionSecretCodeMapName = "GetPermissionSecretCodeMapName"
+	ModerationEditMapName          = "moderationEdits"
 
 	SubUserRole = "sub user"
 	AdminRole   = "admin"
@@ -18,8 +20,16 @@ const (
 	MsgAudio    = "Audio"
 	MsgDocument = "Document"
 	MsgIframe   = "Iframe"
+	MsgLink     = "Link"
 
 	MediaBucket = "media"
+
+	KindTgGroup   = "tg_group"
+	KindTgChannel = "tg_channel"
+
+	MsgStatusPending  = "pending"
+	MsgStatusApproved = "approved"
+	MsgStatusRejected = "rejected"
 )
 
 var (
@@ -44,6 +54,35 @@ type Role struct {
 type MetaPair struct {
 	Url  string `json:"url"`
 	Type string `json:"type"`
+	// Key is the object of the media bucket, the web server makes the link in Url from it
+	Key string `json:"key,omitempty"`
+	// Sizes are the same photo in other resolutions, sorted by width
+	Sizes []MediaSize `json:"sizes,omitempty"`
+	// Title, Description and Preview describe a Link card
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Preview     string `json:"preview,omitempty"`
+	// Width, Height and Blurhash let the page lay out the image before it is loaded
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+	Blurhash string `json:"blurhash,omitempty"`
+}
+
+type MediaSize struct {
+	Url    string `json:"url"`
+	Key    string `json:"key,omitempty"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Entity is a Telegram message entity, Offset and Length are in UTF-16 code units
+type Entity struct {
+	Type     string `json:"type"`
+	Offset   int    `json:"offset"`
+	Length   int    `json:"length"`
+	URL      string `json:"url,omitempty"`
+	Language string `json:"language,omitempty"`
+	UserID   int64  `json:"user_id,omitempty"`
 }
 
 type TgGroup struct {
@@ -53,8 +92,32 @@ type TgGroup struct {
 }
 
 type TgMetaPair struct {
-	ID   string
-	Type string
+	ID string
+	// UniqueID is the Telegram FileUniqueID, the same for every bot and forward of the file
+	UniqueID string
+	Type     string
+}
+
+// MediaObject is a stored file, Name is "<first 2 chars of SHA256>/<SHA256><ext>"
+type MediaObject struct {
+	SHA256      string
+	Name        string
+	Url         string
+	ContentType string
+	Size        int64
+	// Width, Height and Blurhash are set for images
+	Width    int
+	Height   int
+	Blurhash string
+	// Variants are the resized copies of an image, sorted by width
+	Variants []MediaVariant
+}
+
+type MediaVariant struct {
+	Name   string `json:"name"`
+	Url    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
 }
 
 type TgGroupMessage struct {
@@ -62,9 +125,12 @@ type TgGroupMessage struct {
 	GroupID    int64
 	Username   string
 	Text       string
+	Entities   []Entity
 	MetadataID []TgMetaPair
 	Metadata   []MetaPair
 	CreatedAt  time.Time
+	// Status is approved when empty
+	Status string
 }
 
 type TgChannel struct {
@@ -77,9 +143,19 @@ type TgChMessage struct {
 	MessageID  int
 	ChannelID  int64
 	Text       string
+	Entities   []Entity
 	MetadataID []TgMetaPair
 	Metadata   []MetaPair
 	CreatedAt  time.Time
+	// Status is approved when empty
+	Status string
+}
+
+// TgMessageRef points to a Telegram group or channel message, Kind is KindTgGroup or KindTgChannel
+type TgMessageRef struct {
+	Kind      string
+	ChatID    int64
+	MessageID int
 }
 
 type VkGroup struct {
@@ -94,13 +170,119 @@ type VkMessage struct {
 	Text      string
 	Metadata  []MetaPair
 	CreatedAt time.Time
+	// EditedAt is zero for posts that were never edited
+	EditedAt time.Time
+	// Backfilled messages are added to the feed without notifying live clients
+	Backfilled bool
+}
+
+type RssFeed struct {
+	ID    int64
+	Url   string
+	Title string
+}
+
+type RssItem struct {
+	GUID      string
+	FeedID    int64
+	Title     string
+	Link      string
+	Text      string
+	Author    string
+	Metadata  []MetaPair
+	CreatedAt time.Time
 }
 
 type WebMessage struct {
 	ID        int64
 	GroupName string
+	Title     string
+	Link      string
 	Text      string
+	// Entities are set for Telegram messages only
+	Entities  []Entity
 	Metadata  []MetaPair
 	CreatedAt time.Time
 	Type      string
+	// SourceRef "<kind>:<source id>:<message id>" of the source message
+	SourceRef string
+	// Backfilled messages are history loaded by /backfill, they are not sent to live clients
+	Backfilled bool
+	// UpdatedAt is the time the news was added to the feed or last edited
+	UpdatedAt time.Time
+}
+
+const (
+	OutboxPublished = "published"
+	OutboxUpdated   = "updated"
+	OutboxDeleted   = "deleted"
+)
+
+// OutboxEntry a feed change written with the source message, News is nil for OutboxDeleted
+type OutboxEntry struct {
+	ID     int64
+	Event  string
+	NewsID int64
+	News   *WebMessage
+}
+
+// FilterRule Scope is "all", a source kind or "<kind>:<key>"
+type FilterRule struct {
+	ID    int64
+	Scope string
+	Rule  string
+	Value string
+}
+
+// SearchQuery Query is in web search syntax: words, "phrases", -excluded, or
+type SearchQuery struct {
+	Query  string
+	Limit  int
+	Offset int
+}
+
+// SearchHit Snippet marks the matched words with SnippetStart and SnippetStop
+type SearchHit struct {
+	Message WebMessage
+	Rank    float64
+	Snippet string
+}
+
+const (
+	SnippetStart = "\x02"
+	SnippetStop  = "\x03"
+)
+
+// RetentionSource Key is the source key of the kind: chat id, VK domain or feed url
+type RetentionSource struct {
+	Kind string
+	ID   int64
+	Key  string
+}
+
+// ExpiredMessage is a source message past its retention with the news made from it,
+// Message and News are the stored rows
+type ExpiredMessage struct {
+	Kind     string          `json:"kind"`
+	SourceID int64           `json:"source_id"`
+	MsgID    string          `json:"msg_id"`
+	Message  json.RawMessage `json:"message"`
+	News     json.RawMessage `json:"news,omitempty"`
+}
+
+// WebMessageFilter Zero values are ignored
+type WebMessageFilter struct {
+	Limit int
+
+	// BeforeID returns messages older than the message with this id, newest first.
+	// When the message is deleted, the messages with smaller ids are returned
+	BeforeID int64
+
+	// AfterID returns messages stored after the message with this id, in insertion order
+	AfterID int64
+
+	Types     []string
+	GroupName string
+	From      time.Time
+	To        time.Time
 }
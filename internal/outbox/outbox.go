@@ -0,0 +1,132 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"project/internal/events"
+	"project/internal/models"
+	"project/internal/pkg/logger/sl"
+	"project/pkg/e"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrUnknownEvent = errors.New("unknown outbox event")
+	// ErrNotDelivered a subscriber did not take the events within the lease
+	ErrNotDelivered = errors.New("events not delivered, retried after the lease")
+)
+
+// Wake makes the relay read the outbox now, the storage calls it after the commit
+func (r *Relay) Wake() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers the outbox on every wake and interval until ctx is done
+func (r *Relay) Run(ctx context.Context, db Storage) {
+	const fn = "outbox.Run"
+
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		// A full batch means more entries are waiting
+		for {
+			n, err := r.Deliver(ctx, db)
+			if err != nil {
+				r.log.Error(fn, sl.Err(err))
+				break
+			}
+
+			if n < r.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
+
+// Deliver publishes one batch of the outbox, returns the number of delivered entries
+func (r *Relay) Deliver(ctx context.Context, db Storage) (int, error) {
+	const fn = "outbox.Deliver"
+
+	entries, err := db.ClaimOutbox(ctx, r.cfg.BatchSize, r.cfg.Lease)
+	if err != nil {
+		return 0, e.Wrap(fn, err)
+	}
+
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	// ids are the entries to delete, evIDs the entries of evs
+	ids := make([]int64, 0, len(entries))
+	evIDs := make([]int64, 0, len(entries))
+	evs := make([]events.Event, 0, len(entries))
+
+	for _, entry := range entries {
+		ev, err := newEvent(entry)
+		if err != nil {
+			// Would fail on every delivery, the entry is dropped
+			r.log.Error(fn, sl.Err(err), slog.Int64("id", entry.ID))
+			ids = append(ids, entry.ID)
+			continue
+		}
+
+		evIDs = append(evIDs, entry.ID)
+		evs = append(evs, ev)
+	}
+
+	// Waiting longer than the lease is useless, the entries are claimed again after it
+	pubCtx, cancel := context.WithTimeout(ctx, r.cfg.Lease)
+	n := r.bus.Publish(pubCtx, evs...)
+	cancel()
+
+	ids = append(ids, evIDs[:n]...)
+
+	// Not deleted entries are delivered again after the lease with the same keys
+	if err := db.DeleteOutbox(ctx, ids); err != nil {
+		return 0, e.Wrap(fn, err)
+	}
+
+	if n < len(evs) {
+		return 0, e.Wrap(fn, fmt.Errorf("%w: %d of %d", ErrNotDelivered, len(evs)-n, len(evs)))
+	}
+
+	return len(entries), nil
+}
+
+// newEvent the entry id is the idempotency key of the event
+func newEvent(entry models.OutboxEntry) (events.Event, error) {
+	key := strconv.FormatInt(entry.ID, 10)
+
+	switch entry.Event {
+	case models.OutboxPublished, models.OutboxUpdated:
+		if entry.News == nil {
+			return nil, fmt.Errorf("%s event without news %d", entry.Event, entry.NewsID)
+		}
+
+		if entry.Event == models.OutboxPublished {
+			return events.NewsPublished{Key: key, News: *entry.News}, nil
+		}
+
+		return events.NewsUpdated{Key: key, News: *entry.News}, nil
+
+	case models.OutboxDeleted:
+		return events.NewsDeleted{Key: key, ID: entry.NewsID}, nil
+
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownEvent, entry.Event)
+	}
+}
@@ -0,0 +1,63 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"project/internal/config"
+	"project/internal/events"
+	"project/internal/models"
+	"time"
+)
+
+const (
+	defaultInterval  = time.Second
+	defaultBatchSize = 100
+	defaultLease     = 30 * time.Second
+)
+
+// Relay delivers the outbox entries to the subscribers at least once:
+// an entry is deleted after every subscriber received its event,
+// undelivered entries and entries of a stopped relay are claimed again after the lease
+type Relay struct {
+	bus  Publisher
+	wake chan struct{}
+	cfg  config.Outbox
+	log  *slog.Logger
+}
+
+type Storage interface {
+	ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEntry, error)
+	DeleteOutbox(ctx context.Context, ids []int64) error
+}
+
+// Publisher returns the number of events from the start of evs received by all subscribers
+type Publisher interface {
+	Publish(ctx context.Context, evs ...events.Event) int
+}
+
+// New nil cfg uses the defaults
+func New(bus Publisher, cfg *config.Outbox, log *slog.Logger) *Relay {
+	r := &Relay{
+		bus:  bus,
+		wake: make(chan struct{}, 1),
+		log:  log,
+	}
+
+	if cfg != nil {
+		r.cfg = *cfg
+	}
+
+	if r.cfg.Interval <= 0 {
+		r.cfg.Interval = defaultInterval
+	}
+
+	if r.cfg.BatchSize <= 0 {
+		r.cfg.BatchSize = defaultBatchSize
+	}
+
+	if r.cfg.Lease <= 0 {
+		r.cfg.Lease = defaultLease
+	}
+
+	return r
+}
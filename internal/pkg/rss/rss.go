@@ -2,119 +2,237 @@ package rss
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
 	"github.com/mmcdole/gofeed"
-	"log/slog"
 	"net/http"
 	"net/url"
 	"project/internal/models"
-	"project/internal/pkg/logger/sl"
 	"project/pkg/e"
+	"sort"
 	"strings"
+	"time"
 )
 
-func ValidateFeedURL(ctx context.Context, feedURL string) bool {
+const (
+	maxItems  = 10
+	userAgent = "Gofeed/1.0"
+)
+
+var (
+	ErrNotFeed     = errors.New("url is not a rss/atom feed")
+	ErrNotModified = errors.New("feed not modified")
+)
+
+// Validators are the ETag and Last-Modified of the last fetched feed version,
+// they are sent back so an unchanged feed is answered with 304 Not Modified
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// ValidateFeedURL checks that feedURL serves a parsable RSS/Atom/JSON feed
+func ValidateFeedURL(ctx context.Context, feedURL string) (models.RssFeed, error) {
 	const op = "rss.ValidateFeedURL"
 
 	parsedURL, err := url.Parse(feedURL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		return models.RssFeed{}, e.Wrap(op, ErrNotFeed)
+	}
+
+	feed, err := gofeed.NewParser().ParseURLWithContext(feedURL, ctx)
 	if err != nil {
-		slog.Error(op, sl.Err(err))
-		return false
+		return models.RssFeed{}, e.Wrap(op, fmt.Errorf("%w: %w", ErrNotFeed, err))
 	}
 
+	title := strings.TrimSpace(feed.Title)
+	if title == "" {
+		title = parsedURL.Host
+	}
+
+	return models.RssFeed{
+		Url:   feedURL,
+		Title: title,
+	}, nil
+}
+
+// Parsing returns the latest feed items, oldest first, and the validators of the fetched version.
+// ErrNotModified is returned when the feed did not change since the version of the validators
+func Parsing(ctx context.Context, feedURL string, prev Validators) (items []models.RssItem, next Validators, err error) {
+	const op = "rss.Parsing"
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = e.Wrap(op, fmt.Errorf("recovered panic: %v", r))
+		}
+	}()
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
 	if err != nil {
-		slog.Error(op, sl.Err(err))
-		return false
+		return nil, prev, e.Wrap(op, err)
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+
+	if prev.ETag != "" {
+		req.Header.Set("If-None-Match", prev.ETag)
 	}
 
-	req.Close = true
+	if prev.LastModified != "" {
+		req.Header.Set("If-Modified-Since", prev.LastModified)
+	}
 
 	resp, err := http.DefaultClient.Do(req)
-	if resp != nil {
-		defer resp.Body.Close()
+	if err != nil {
+		return nil, prev, e.Wrap(op, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, prev, e.Wrap(op, ErrNotModified)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, prev, e.Wrap(op, fmt.Errorf("unexpected status: %s", resp.Status))
+	}
+
+	next = Validators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
 	}
 
+	feed, err := gofeed.NewParser().Parse(resp.Body)
 	if err != nil {
-		slog.Error(op, sl.Err(err))
-		return false
+		return nil, prev, e.Wrap(op, err)
 	}
 
-	if resp.StatusCode != 200 {
-		slog.Info(op, slog.Int("StatusCode", resp.StatusCode))
-		return false
+	for _, item := range feed.Items {
+		items = append(items, toRssItem(item))
 	}
 
-	if strings.HasSuffix(parsedURL.Path, ".rss") || strings.HasSuffix(parsedURL.Path, ".xml") {
-		return true
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].CreatedAt.After(items[j].CreatedAt)
+	})
+
+	if len(items) > maxItems {
+		items = items[:maxItems]
 	}
 
-	if strings.Contains(strings.ToLower(parsedURL.Path), "rss") || strings.Contains(strings.ToLower(parsedURL.Path), "feed") {
-		return true
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
 	}
 
-	return false
+	return items, next, nil
 }
 
-func Parsing(ctx context.Context, feedURL string) ([]models.TgGroupMessage, error) {
-	const op = "rss.Parsing"
+func toRssItem(item *gofeed.Item) models.RssItem {
+	text := item.Description
+	if text == "" {
+		text = item.Content
+	}
 
-	defer func() {
-		if err := recover(); err != nil {
-			slog.Error("[RECOVER] panic err: ", err)
-		}
-	}()
+	author := ""
+	if item.Author != nil {
+		author = item.Author.Name
+	}
 
-	fp := gofeed.NewParser()
+	return models.RssItem{
+		GUID:      itemGUID(item),
+		Title:     strings.TrimSpace(item.Title),
+		Link:      item.Link,
+		Text:      htmlToText(text),
+		Author:    author,
+		Metadata:  getMetadataFromItem(item),
+		CreatedAt: itemDate(item),
+	}
+}
 
-	feed, err := fp.ParseURLWithContext(feedURL, ctx)
-	if err != nil {
-		return nil, e.Wrap(op, err)
+// itemGUID falls back to link and then to a content hash for feeds without guid
+func itemGUID(item *gofeed.Item) string {
+	if item.GUID != "" {
+		return item.GUID
 	}
 
-	var (
-		msg   []models.TgGroupMessage
-		count int
-	)
+	if item.Link != "" {
+		return item.Link
+	}
 
-	for _, item := range feed.Items {
-		if count == 10 {
-			break
-		}
+	sum := sha256.Sum256([]byte(item.Title + item.Published + item.Description))
 
-		title := "Не указано"
-		if item.Title != "" {
-			title = item.Title
-		}
+	return hex.EncodeToString(sum[:])
+}
 
-		image := ""
-		if item.Image != nil && item.Image.URL != "" {
-			image = item.Image.URL
-		}
+func itemDate(item *gofeed.Item) time.Time {
+	switch {
+	case item.PublishedParsed != nil:
+		return item.PublishedParsed.UTC()
 
-		description := "Нет описания"
-		if item.Description != "" {
-			description = item.Description
-		}
+	case item.UpdatedParsed != nil:
+		return item.UpdatedParsed.UTC()
 
-		published := "Дата не указана"
-		if item.Published != "" {
-			published = item.Published
-		}
+	default:
+		return time.Now().UTC()
+	}
+}
+
+func getMetadataFromItem(item *gofeed.Item) []models.MetaPair {
+	var pairs []models.MetaPair
+
+	if item.Image != nil && item.Image.URL != "" {
+		pairs = append(pairs, models.MetaPair{
+			Url:  item.Image.URL,
+			Type: models.MsgPhoto,
+		})
+	}
 
-		author := "Автор не указан"
-		if item.Author != nil && item.Author.Name != "" {
-			author = item.Author.Name
+	for _, enclosure := range item.Enclosures {
+		if enclosure.URL == "" || (item.Image != nil && enclosure.URL == item.Image.URL) {
+			continue
 		}
 
-		link := item.Link
+		switch {
+		case strings.HasPrefix(enclosure.Type, "image/"):
+			pairs = append(pairs, models.MetaPair{
+				Url:  enclosure.URL,
+				Type: models.MsgPhoto,
+			})
+
+		case strings.HasPrefix(enclosure.Type, "video/"):
+			pairs = append(pairs, models.MetaPair{
+				Url:  enclosure.URL,
+				Type: models.MsgVideo,
+			})
+
+		case strings.HasPrefix(enclosure.Type, "audio/"):
+			pairs = append(pairs, models.MetaPair{
+				Url:  enclosure.URL,
+				Type: models.MsgAudio,
+			})
 
-		msg = append(msg,
-			models.TgGroupMessage{
-				Text: link + title + description + image + published + author,
+		default:
+			pairs = append(pairs, models.MetaPair{
+				Url:  enclosure.URL,
+				Type: models.MsgDocument,
 			})
+		}
+	}
 
-		count++
+	return pairs
+}
+
+func htmlToText(s string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(s))
+	if err != nil {
+		return s
 	}
 
-	return msg, nil
+	doc.Find("br").ReplaceWithHtml("\n")
+	doc.Find("p, div, li").Each(func(_ int, sel *goquery.Selection) {
+		sel.AppendHtml("\n")
+	})
+
+	return strings.TrimSpace(doc.Text())
 }
@@ -0,0 +1,228 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"project/internal/models"
+	"testing"
+	"time"
+)
+
+const rssFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+<channel>
+	<title>Новости</title>
+	<link>https://example.com</link>
+	<item>
+		<guid>item-2</guid>
+		<title> Второй </title>
+		<link>https://example.com/2</link>
+		<description><![CDATA[<p>Первый абзац</p><p>Второй<br>абзац</p>]]></description>
+		<author>editor@example.com (Редактор)</author>
+		<pubDate>Tue, 02 Jan 2024 10:00:00 +0300</pubDate>
+		<enclosure url="https://example.com/2.jpg" type="image/jpeg" length="1"/>
+		<enclosure url="https://example.com/2.mp3" type="audio/mpeg" length="1"/>
+	</item>
+	<item>
+		<guid>item-1</guid>
+		<title>Первый</title>
+		<link>https://example.com/1</link>
+		<description>text</description>
+		<pubDate>Mon, 01 Jan 2024 10:00:00 +0000</pubDate>
+	</item>
+</channel>
+</rss>`
+
+const atomFeed = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>Atom</title>
+	<id>urn:feed</id>
+	<updated>2024-01-03T00:00:00Z</updated>
+	<entry>
+		<title>Без guid</title>
+		<link href="https://example.com/atom/1"/>
+		<id></id>
+		<updated>2024-01-03T00:00:00Z</updated>
+		<author><name>Автор</name></author>
+		<content type="html">&lt;b&gt;content&lt;/b&gt;</content>
+	</entry>
+</feed>`
+
+func serveFeed(t *testing.T, body string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestParsingRSS(t *testing.T) {
+	srv := serveFeed(t, rssFeed)
+
+	items, _, err := Parsing(context.Background(), srv.URL, Validators{})
+	if err != nil {
+		t.Fatalf("Parsing() error = %v", err)
+	}
+
+	if len(items) != 2 {
+		t.Fatalf("Parsing() returned %d items, want 2", len(items))
+	}
+
+	// Oldest first
+	if items[0].GUID != "item-1" || items[1].GUID != "item-2" {
+		t.Fatalf("Parsing() order = %q, %q, want item-1, item-2", items[0].GUID, items[1].GUID)
+	}
+
+	got := items[1]
+
+	if got.Title != "Второй" {
+		t.Errorf("Title = %q, want %q", got.Title, "Второй")
+	}
+
+	if got.Link != "https://example.com/2" {
+		t.Errorf("Link = %q", got.Link)
+	}
+
+	if want := "Первый абзац\nВторой\nабзац"; got.Text != want {
+		t.Errorf("Text = %q, want %q", got.Text, want)
+	}
+
+	if got.Author != "Редактор" {
+		t.Errorf("Author = %q, want %q", got.Author, "Редактор")
+	}
+
+	if want := time.Date(2024, 1, 2, 7, 0, 0, 0, time.UTC); !got.CreatedAt.Equal(want) || got.CreatedAt.Location() != time.UTC {
+		t.Errorf("CreatedAt = %v, want %v", got.CreatedAt, want)
+	}
+
+	wantMeta := []models.MetaPair{
+		{Url: "https://example.com/2.jpg", Type: models.MsgPhoto},
+		{Url: "https://example.com/2.mp3", Type: models.MsgAudio},
+	}
+
+	if len(got.Metadata) != len(wantMeta) {
+		t.Fatalf("Metadata = %+v, want %+v", got.Metadata, wantMeta)
+	}
+
+	for i := range wantMeta {
+		if got.Metadata[i].Url != wantMeta[i].Url || got.Metadata[i].Type != wantMeta[i].Type {
+			t.Errorf("Metadata[%d] = %+v, want %+v", i, got.Metadata[i], wantMeta[i])
+		}
+	}
+}
+
+func TestParsingAtom(t *testing.T) {
+	srv := serveFeed(t, atomFeed)
+
+	items, _, err := Parsing(context.Background(), srv.URL, Validators{})
+	if err != nil {
+		t.Fatalf("Parsing() error = %v", err)
+	}
+
+	if len(items) != 1 {
+		t.Fatalf("Parsing() returned %d items, want 1", len(items))
+	}
+
+	got := items[0]
+
+	// Without id the link is the GUID
+	if got.GUID != "https://example.com/atom/1" {
+		t.Errorf("GUID = %q, want the link", got.GUID)
+	}
+
+	if got.Text != "content" || got.Author != "Автор" {
+		t.Errorf("Text, Author = %q, %q", got.Text, got.Author)
+	}
+
+	if want := time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC); !got.CreatedAt.Equal(want) {
+		t.Errorf("CreatedAt = %v, want %v", got.CreatedAt, want)
+	}
+}
+
+func TestParsingConditional(t *testing.T) {
+	const (
+		etag         = `"v1"`
+		lastModified = "Mon, 01 Jan 2024 10:00:00 GMT"
+	)
+
+	var requests, notModified int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if r.Header.Get("If-None-Match") == etag || r.Header.Get("If-Modified-Since") == lastModified {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		_, _ = w.Write([]byte(rssFeed))
+	}))
+	defer srv.Close()
+
+	items, next, err := Parsing(context.Background(), srv.URL, Validators{})
+	if err != nil {
+		t.Fatalf("Parsing() error = %v", err)
+	}
+
+	if len(items) != 2 {
+		t.Fatalf("Parsing() returned %d items, want 2", len(items))
+	}
+
+	if next != (Validators{ETag: etag, LastModified: lastModified}) {
+		t.Fatalf("Parsing() validators = %+v", next)
+	}
+
+	tests := []struct {
+		name string
+		prev Validators
+	}{
+		{name: "etag", prev: Validators{ETag: etag}},
+		{name: "last modified", prev: Validators{LastModified: lastModified}},
+		{name: "both", prev: next},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, got, err := Parsing(context.Background(), srv.URL, tt.prev)
+			if !errors.Is(err, ErrNotModified) {
+				t.Fatalf("Parsing() error = %v, want ErrNotModified", err)
+			}
+
+			if len(items) != 0 || got != tt.prev {
+				t.Errorf("Parsing() = %d items, %+v, want no items and the previous validators", len(items), got)
+			}
+		})
+	}
+
+	if requests != 4 || notModified != 3 {
+		t.Errorf("requests = %d, not modified = %d, want 4, 3", requests, notModified)
+	}
+}
+
+func TestParsingErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+
+		_, _ = w.Write([]byte("<html><body>not a feed</body></html>"))
+	}))
+	defer srv.Close()
+
+	for _, path := range []string{"/missing", "/page"} {
+		if _, _, err := Parsing(context.Background(), srv.URL+path, Validators{}); err == nil {
+			t.Errorf("Parsing(%s) error = nil, want an error", path)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package tgtext
+
+import (
+	"html"
+	"net/url"
+	"project/internal/models"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var languageRe = regexp.MustCompile(`^[A-Za-z0-9_+\-#.]{1,32}$`)
+
+type htmlFormatter struct {
+	b strings.Builder
+}
+
+// HTML renders the text with entities, everything that comes from the message is escaped
+// and links are limited to http, https, mailto and tg schemes
+func HTML(text string, entities []models.Entity) string {
+	var f htmlFormatter
+
+	render(text, entities, &f)
+
+	return f.b.String()
+}
+
+func (f *htmlFormatter) text(s string, in []span) {
+	s = html.EscapeString(s)
+
+	if !inCode(in) {
+		s = strings.ReplaceAll(s, "\n", "<br>")
+	}
+
+	f.b.WriteString(s)
+}
+
+func (f *htmlFormatter) open(sp span) {
+	switch sp.Type {
+	case "bold":
+		f.b.WriteString("<b>")
+
+	case "italic":
+		f.b.WriteString("<i>")
+
+	case "underline":
+		f.b.WriteString("<u>")
+
+	case "strikethrough":
+		f.b.WriteString("<s>")
+
+	case "spoiler":
+		f.b.WriteString(`<span class="tg-spoiler">`)
+
+	case "code":
+		f.b.WriteString("<code>")
+
+	case "pre":
+		if languageRe.MatchString(sp.Language) {
+			f.b.WriteString(`<pre><code class="language-` + sp.Language + `">`)
+		} else {
+			f.b.WriteString("<pre><code>")
+		}
+
+	case "blockquote", "expandable_blockquote":
+		f.b.WriteString("<blockquote>")
+
+	default:
+		if href, ok := linkHref(sp); ok {
+			f.b.WriteString(`<a href="` + html.EscapeString(href) + `" rel="nofollow noopener noreferrer" target="_blank">`)
+		}
+	}
+}
+
+func (f *htmlFormatter) close(sp span) {
+	switch sp.Type {
+	case "bold":
+		f.b.WriteString("</b>")
+
+	case "italic":
+		f.b.WriteString("</i>")
+
+	case "underline":
+		f.b.WriteString("</u>")
+
+	case "strikethrough":
+		f.b.WriteString("</s>")
+
+	case "spoiler":
+		f.b.WriteString("</span>")
+
+	case "code":
+		f.b.WriteString("</code>")
+
+	case "pre":
+		f.b.WriteString("</code></pre>")
+
+	case "blockquote", "expandable_blockquote":
+		f.b.WriteString("</blockquote>")
+
+	default:
+		if _, ok := linkHref(sp); ok {
+			f.b.WriteString("</a>")
+		}
+	}
+}
+
+// linkHref returns the sanitized target of link entities
+func linkHref(sp span) (string, bool) {
+	switch sp.Type {
+	case "text_link":
+		return safeURL(sp.URL)
+
+	case "text_mention":
+		if sp.UserID == 0 {
+			return "", false
+		}
+
+		return "tg://user?id=" + strconv.FormatInt(sp.UserID, 10), true
+
+	case "url", "email", "mention":
+		return safeURL(sp.URL)
+
+	default:
+		return "", false
+	}
+}
+
+func safeURL(raw string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", false
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto", "tg":
+		return u.String(), true
+
+	default:
+		return "", false
+	}
+}
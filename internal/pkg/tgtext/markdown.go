@@ -0,0 +1,95 @@
+package tgtext
+
+import (
+	"project/internal/models"
+	"strings"
+)
+
+// markdownEscaper escapes CommonMark punctuation in plain text
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `~`, `\~`,
+	`[`, `\[`, `]`, `\]`, `(`, `\(`, `)`, `\)`,
+	`#`, `\#`, `>`, `\>`, `|`, `\|`, `!`, `\!`, `<`, `\<`,
+)
+
+type markdownFormatter struct {
+	b strings.Builder
+}
+
+// Markdown renders the text with entities as CommonMark, underline and spoiler have no
+// Markdown form and are rendered as plain text
+func Markdown(text string, entities []models.Entity) string {
+	var f markdownFormatter
+
+	render(text, entities, &f)
+
+	return f.b.String()
+}
+
+func (f *markdownFormatter) text(s string, in []span) {
+	if inCode(in) {
+		f.b.WriteString(s)
+		return
+	}
+
+	f.b.WriteString(markdownEscaper.Replace(s))
+}
+
+func (f *markdownFormatter) open(sp span) {
+	switch sp.Type {
+	case "bold":
+		f.b.WriteString("**")
+
+	case "italic":
+		f.b.WriteString("_")
+
+	case "strikethrough":
+		f.b.WriteString("~~")
+
+	case "code":
+		f.b.WriteString("`")
+
+	case "pre":
+		lang := ""
+		if languageRe.MatchString(sp.Language) {
+			lang = sp.Language
+		}
+
+		f.b.WriteString("\n```" + lang + "\n")
+
+	case "blockquote", "expandable_blockquote":
+		f.b.WriteString("\n> ")
+
+	default:
+		if _, ok := linkHref(sp); ok {
+			f.b.WriteString("[")
+		}
+	}
+}
+
+func (f *markdownFormatter) close(sp span) {
+	switch sp.Type {
+	case "bold":
+		f.b.WriteString("**")
+
+	case "italic":
+		f.b.WriteString("_")
+
+	case "strikethrough":
+		f.b.WriteString("~~")
+
+	case "code":
+		f.b.WriteString("`")
+
+	case "pre":
+		f.b.WriteString("\n```\n")
+
+	case "blockquote", "expandable_blockquote":
+		f.b.WriteString("\n")
+
+	default:
+		if href, ok := linkHref(sp); ok {
+			f.b.WriteString("](" + strings.NewReplacer("(", "%28", ")", "%29", " ", "%20").Replace(href) + ")")
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package tgtext renders Telegram message entities as sanitized HTML and Markdown
+package tgtext
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"project/internal/models"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// span is an entity converted to UTF-16 positions clipped to the text
+type span struct {
+	models.Entity
+	start, end int
+	order      int
+}
+
+// formatter writes the text between entity boundaries and opening/closing marks
+type formatter interface {
+	text(s string, in []span)
+	open(sp span)
+	close(sp span)
+}
+
+// render walks the text segment by segment, closing and reopening entities
+// when they overlap partially so the output is always properly nested
+func render(text string, entities []models.Entity, f formatter) {
+	units := utf16.Encode([]rune(text))
+
+	spans := make([]span, 0, len(entities))
+
+	for i, ent := range entities {
+		start, end := ent.Offset, ent.Offset+ent.Length
+
+		if start < 0 || end > len(units) || start >= end {
+			continue
+		}
+
+		// Entities must not split a surrogate pair
+		if isLowSurrogate(units[start]) || (end < len(units) && isLowSurrogate(units[end])) {
+			continue
+		}
+
+		sp := span{Entity: ent, start: start, end: end, order: i}
+
+		// Links without URL point to their own text
+		entText := string(utf16.Decode(units[start:end]))
+
+		switch ent.Type {
+		case "url":
+			sp.URL = entText
+			if !strings.Contains(entText, "://") {
+				sp.URL = "https://" + entText
+			}
+
+		case "email":
+			sp.URL = "mailto:" + entText
+
+		case "mention":
+			sp.URL = "https://t.me/" + strings.TrimPrefix(entText, "@")
+		}
+
+		spans = append(spans, sp)
+	}
+
+	// Outer entities first
+	sort.SliceStable(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+
+		return spans[i].end > spans[j].end
+	})
+
+	bounds := []int{0, len(units)}
+	for _, sp := range spans {
+		bounds = append(bounds, sp.start, sp.end)
+	}
+
+	sort.Ints(bounds)
+
+	var stack []span
+
+	for i := 0; i+1 < len(bounds); i++ {
+		from, to := bounds[i], bounds[i+1]
+		if from == to {
+			continue
+		}
+
+		var active []span
+		for _, sp := range spans {
+			if sp.start <= from && to <= sp.end {
+				active = append(active, sp)
+			}
+		}
+
+		// Keeps the longest prefix of open entities that is still active
+		keep := 0
+		for keep < len(stack) && keep < len(active) && stack[keep].order == active[keep].order {
+			keep++
+		}
+
+		for j := len(stack) - 1; j >= keep; j-- {
+			f.close(stack[j])
+		}
+
+		stack = stack[:keep]
+
+		for _, sp := range active[keep:] {
+			f.open(sp)
+			stack = append(stack, sp)
+		}
+
+		f.text(string(utf16.Decode(units[from:to])), stack)
+	}
+
+	for j := len(stack) - 1; j >= 0; j-- {
+		f.close(stack[j])
+	}
+}
+
+func isLowSurrogate(u uint16) bool {
+	return u >= 0xDC00 && u <= 0xDFFF
+}
+
+// inCode reports whether the text is inside a code or pre entity
+func inCode(in []span) bool {
+	for _, sp := range in {
+		if sp.Type == "code" || sp.Type == "pre" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FromTelegram converts Bot API entities to the stored form
+func FromTelegram(entities []tgbotapi.MessageEntity) []models.Entity {
+	if len(entities) == 0 {
+		return nil
+	}
+
+	res := make([]models.Entity, 0, len(entities))
+
+	for _, ent := range entities {
+		entity := models.Entity{
+			Type:     ent.Type,
+			Offset:   ent.Offset,
+			Length:   ent.Length,
+			URL:      ent.URL,
+			Language: ent.Language,
+		}
+
+		if ent.User != nil {
+			entity.UserID = ent.User.ID
+		}
+
+		res = append(res, entity)
+	}
+
+	return res
+}
@@ -0,0 +1,134 @@
+package tgtext
+
+import (
+	"project/internal/models"
+	"testing"
+)
+
+const linkAttrs = ` rel="nofollow noopener noreferrer" target="_blank"`
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		entities []models.Entity
+		html     string
+		markdown string
+	}{
+		{
+			name:     "plain text is escaped",
+			text:     "<b>a & b</b> *c*",
+			html:     "&lt;b&gt;a &amp; b&lt;/b&gt; *c*",
+			markdown: `\<b\>a & b\</b\> \*c\*`,
+		},
+		{
+			name:     "emoji before entity",
+			text:     "😀 hi",
+			entities: []models.Entity{{Type: "bold", Offset: 3, Length: 2}},
+			html:     "😀 <b>hi</b>",
+			markdown: "😀 **hi**",
+		},
+		{
+			name:     "entity over emoji",
+			text:     "a 👍🏻 b",
+			entities: []models.Entity{{Type: "italic", Offset: 2, Length: 4}},
+			html:     "a <i>👍🏻</i> b",
+			markdown: "a _👍🏻_ b",
+		},
+		{
+			name:     "entity splitting surrogate pair is dropped",
+			text:     "😀 hi",
+			entities: []models.Entity{{Type: "bold", Offset: 1, Length: 3}},
+			html:     "😀 hi",
+			markdown: "😀 hi",
+		},
+		{
+			name:     "cyrillic",
+			text:     "Привет, мир",
+			entities: []models.Entity{{Type: "bold", Offset: 8, Length: 3}},
+			html:     "Привет, <b>мир</b>",
+			markdown: "Привет, **мир**",
+		},
+		{
+			name: "nested",
+			text: "жирный курсив",
+			entities: []models.Entity{
+				{Type: "italic", Offset: 7, Length: 6},
+				{Type: "bold", Offset: 0, Length: 13},
+			},
+			html:     "<b>жирный <i>курсив</i></b>",
+			markdown: "**жирный _курсив_**",
+		},
+		{
+			name: "overlapping",
+			text: "abcdef",
+			entities: []models.Entity{
+				{Type: "bold", Offset: 0, Length: 4},
+				{Type: "italic", Offset: 2, Length: 4},
+			},
+			html:     "<b>ab<i>cd</i></b><i>ef</i>",
+			markdown: "**ab_cd_**_ef_",
+		},
+		{
+			name:     "entity at the end",
+			text:     "see link",
+			entities: []models.Entity{{Type: "text_link", Offset: 4, Length: 4, URL: "https://example.com/a b"}},
+			html:     `see <a href="https://example.com/a%20b"` + linkAttrs + `>link</a>`,
+			markdown: "see [link](https://example.com/a%20b)",
+		},
+		{
+			name:     "entity past the end is dropped",
+			text:     "tail",
+			entities: []models.Entity{{Type: "bold", Offset: 2, Length: 5}},
+			html:     "tail",
+			markdown: "tail",
+		},
+		{
+			name:     "javascript link",
+			text:     "click",
+			entities: []models.Entity{{Type: "text_link", Offset: 0, Length: 5, URL: "javascript:alert(1)"}},
+			html:     "click",
+			markdown: "click",
+		},
+		{
+			name:     "data link",
+			text:     "click",
+			entities: []models.Entity{{Type: "text_link", Offset: 0, Length: 5, URL: " DATA:text/html,<script>"}},
+			html:     "click",
+			markdown: "click",
+		},
+		{
+			name:     "url without scheme",
+			text:     "go example.com",
+			entities: []models.Entity{{Type: "url", Offset: 3, Length: 11}},
+			html:     `go <a href="https://example.com"` + linkAttrs + `>example.com</a>`,
+			markdown: `go [example.com](https://example.com)`,
+		},
+		{
+			name:     "mention",
+			text:     "by @user",
+			entities: []models.Entity{{Type: "mention", Offset: 3, Length: 5}},
+			html:     `by <a href="https://t.me/user"` + linkAttrs + `>@user</a>`,
+			markdown: "by [@user](https://t.me/user)",
+		},
+		{
+			name:     "code keeps text as is",
+			text:     "x <a>\ny",
+			entities: []models.Entity{{Type: "pre", Offset: 0, Length: 7, Language: "go\"><script>"}},
+			html:     "<pre><code>x &lt;a&gt;\ny</code></pre>",
+			markdown: "\n```\nx <a>\ny\n```\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTML(tt.text, tt.entities); got != tt.html {
+				t.Errorf("HTML() = %q, want %q", got, tt.html)
+			}
+
+			if got := Markdown(tt.text, tt.entities); got != tt.markdown {
+				t.Errorf("Markdown() = %q, want %q", got, tt.markdown)
+			}
+		})
+	}
+}
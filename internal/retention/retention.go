@@ -0,0 +1,261 @@
+package retention
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"project/internal/config"
+	"project/internal/files"
+	"project/internal/models"
+	"project/internal/pkg/logger/sl"
+	"project/internal/storage"
+	"project/pkg/e"
+	"slices"
+	"time"
+)
+
+// Run purges expired messages every interval until ctx is done
+func (j *Janitor) Run(ctx context.Context) {
+	const fn = "retention.Run"
+
+	if j == nil {
+		return
+	}
+
+	if j.cfg.Archive {
+		err := j.fdb.NewBucket(ctx, j.cfg.ArchiveBucket, files.MakeBucketOptions{})
+		if err != nil && !errors.Is(err, files.ErrBucketIsExists) {
+			j.log.Error(fn, sl.Err(err))
+		}
+	}
+
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := j.Purge(ctx)
+		if err != nil {
+			j.log.Error(fn, sl.Err(err))
+		}
+
+		if n > 0 {
+			j.log.Info("[RETENTION] Expired messages purged", slog.Int("count", n))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge deletes expired messages of all sources, returns the number of deleted messages
+func (j *Janitor) Purge(ctx context.Context) (int, error) {
+	const fn = "retention.Purge"
+
+	sources, err := j.db.GetRetentionSources(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoRecordsFound) {
+			return 0, nil
+		}
+
+		return 0, e.Wrap(fn, err)
+	}
+
+	total := 0
+
+	for _, src := range sources {
+		policy := j.policy(src)
+		if policy.Days <= 0 && policy.Items <= 0 {
+			continue
+		}
+
+		n, err := j.purgeSource(ctx, src, policy)
+		total += n
+
+		if err != nil {
+			// One broken source must not stop the others
+			j.log.Error(fn,
+				sl.Err(err),
+				slog.String("kind", src.Kind),
+				slog.String("key", src.Key),
+			)
+		}
+
+		if ctx.Err() != nil {
+			return total, e.Wrap(fn, ctx.Err())
+		}
+	}
+
+	return total, nil
+}
+
+// policy returns the most specific policy of the source: "<kind>:<key>", then the kind, then "all"
+func (j *Janitor) policy(src models.RetentionSource) config.RetentionPolicy {
+	for _, scope := range []string{src.Kind + ":" + src.Key, src.Kind, ScopeAll} {
+		if policy, ok := j.cfg.Policies[scope]; ok {
+			return policy
+		}
+	}
+
+	return config.RetentionPolicy{}
+}
+
+func (j *Janitor) purgeSource(ctx context.Context, src models.RetentionSource, policy config.RetentionPolicy) (int, error) {
+	const fn = "retention.purgeSource"
+
+	var before time.Time
+
+	if policy.Days > 0 {
+		before = time.Now().AddDate(0, 0, -policy.Days)
+	}
+
+	total := 0
+
+	for {
+		msgs, err := j.db.GetExpiredMessages(ctx, src, before, policy.Items, j.cfg.BatchSize)
+		if err != nil {
+			if errors.Is(err, storage.ErrNoRecordsFound) {
+				return total, nil
+			}
+
+			return total, e.Wrap(fn, err)
+		}
+
+		// Messages are deleted only when they are saved to the archive
+		if j.cfg.Archive {
+			if err := j.archive(ctx, src, msgs); err != nil {
+				return total, e.Wrap(fn, err)
+			}
+		}
+
+		ids := make([]string, 0, len(msgs))
+
+		for _, msg := range msgs {
+			ids = append(ids, msg.MsgID)
+		}
+
+		if err := j.db.DeleteExpiredMessages(ctx, src, ids); err != nil {
+			return total, e.Wrap(fn, err)
+		}
+
+		total += len(msgs)
+
+		j.deleteMedia(ctx, msgs)
+
+		if len(msgs) < j.cfg.BatchSize || ctx.Err() != nil {
+			return total, nil
+		}
+	}
+}
+
+// archive saves the messages as one gzipped JSONL object: <kind>/<source id>/<time>.jsonl.gz
+func (j *Janitor) archive(ctx context.Context, src models.RetentionSource, msgs []models.ExpiredMessage) error {
+	const fn = "retention.archive"
+
+	var buf bytes.Buffer
+
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+
+	for _, msg := range msgs {
+		if err := enc.Encode(msg); err != nil {
+			return e.Wrap(fn, err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	name := fmt.Sprintf("%s/%d/%s.jsonl.gz", src.Kind, src.ID, time.Now().UTC().Format("20060102T150405.000000000Z"))
+
+	_, err := j.fdb.SaveFile(ctx, j.cfg.ArchiveBucket, name, &buf, files.PutObjectOptions{ContentType: "application/gzip"})
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+// deleteMedia releases the stored media of deleted messages and deletes files nobody references anymore,
+// urls of other hosts are skipped
+func (j *Janitor) deleteMedia(ctx context.Context, msgs []models.ExpiredMessage) {
+	const fn = "retention.deleteMedia"
+
+	var names []string
+
+	// Every source message holds a reference to each of its files, the news repeats the same urls
+	for _, msg := range msgs {
+		keys, urls := mediaRefs(msg.Message)
+
+		for _, u := range urls {
+			if name, ok := j.fdb.ObjectName(models.MediaBucket, u); ok && !slices.Contains(keys, name) {
+				keys = append(keys, name)
+			}
+		}
+
+		names = append(names, keys...)
+	}
+
+	if len(names) == 0 {
+		return
+	}
+
+	unused, err := j.db.ReleaseMediaObjects(ctx, names)
+	if err != nil {
+		j.log.Error(fn, sl.Err(err))
+		return
+	}
+
+	for _, name := range unused {
+		// An object left behind only takes space, the messages are already deleted
+		if err := j.fdb.DeleteFile(ctx, models.MediaBucket, name, files.RemoveObjectOptions{}); err != nil {
+			j.log.Error(fn, sl.Err(err), slog.String("file", name))
+		}
+	}
+}
+
+// mediaRefs returns object keys and the urls of files stored without them from the metadata of a stored row.
+// Every key is returned once: a release drops one reference per name
+func mediaRefs(row []byte) ([]string, []string) {
+	if len(row) == 0 {
+		return nil, nil
+	}
+
+	var stored struct {
+		Metadata []models.MetaPair `json:"metadata"`
+	}
+
+	if err := json.Unmarshal(row, &stored); err != nil {
+		return nil, nil
+	}
+
+	var keys, urls []string
+
+	for _, meta := range stored.Metadata {
+		urls = append(urls, meta.Preview)
+
+		// The sizes of a stored object are its variants and itself, the release returns the variants
+		if meta.Key != "" {
+			if !slices.Contains(keys, meta.Key) {
+				keys = append(keys, meta.Key)
+			}
+
+			continue
+		}
+
+		urls = append(urls, meta.Url)
+
+		for _, size := range meta.Sizes {
+			urls = append(urls, size.Url)
+		}
+	}
+
+	return keys, urls
+}
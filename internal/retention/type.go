@@ -0,0 +1,70 @@
+package retention
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"project/internal/config"
+	"project/internal/files"
+	"project/internal/models"
+	"time"
+)
+
+const (
+	// ScopeAll policy applies to every source
+	ScopeAll = "all"
+
+	defaultInterval      = time.Hour
+	defaultBatchSize     = 500
+	defaultArchiveBucket = "archive"
+)
+
+// Janitor deletes messages past the retention policy of their source with their media
+type Janitor struct {
+	db  Storage
+	fdb Files
+	cfg config.Retention
+	log *slog.Logger
+}
+
+type Storage interface {
+	GetRetentionSources(ctx context.Context) ([]models.RetentionSource, error)
+	GetExpiredMessages(ctx context.Context, src models.RetentionSource, before time.Time, keep, limit int) ([]models.ExpiredMessage, error)
+	DeleteExpiredMessages(ctx context.Context, src models.RetentionSource, msgIDs []string) error
+	ReleaseMediaObjects(ctx context.Context, names []string) ([]string, error)
+}
+
+type Files interface {
+	NewBucket(ctx context.Context, bucketName string, options files.MakeBucketOptions) error
+	SaveFile(ctx context.Context, bucketName, fileName string, reader io.Reader, options files.PutObjectOptions) (string, error)
+	DeleteFile(ctx context.Context, bucketName, fileName string, options files.RemoveObjectOptions) error
+	ObjectName(bucketName, fileUrl string) (string, bool)
+}
+
+// New returns nil when the config has no retention section, nil Janitor does nothing
+func New(db Storage, fdb Files, cfg *config.Retention, log *slog.Logger) *Janitor {
+	if cfg == nil {
+		return nil
+	}
+
+	j := &Janitor{
+		db:  db,
+		fdb: fdb,
+		cfg: *cfg,
+		log: log,
+	}
+
+	if j.cfg.Interval <= 0 {
+		j.cfg.Interval = defaultInterval
+	}
+
+	if j.cfg.BatchSize <= 0 {
+		j.cfg.BatchSize = defaultBatchSize
+	}
+
+	if j.cfg.ArchiveBucket == "" {
+		j.cfg.ArchiveBucket = defaultArchiveBucket
+	}
+
+	return j
+}
@@ -2,8 +2,8 @@ package server
 
 import (
 	"context"
-	"project/internal/clients/vk"
 	"project/internal/models"
+	"project/internal/sources"
 	"time"
 )
 
@@ -16,11 +16,13 @@ type AppCache interface {
 	SetToMap(name, key string, value any, TTL time.Duration) bool
 }
 
-func Prepare(ctx context.Context, db Storage, ac AppCache, vk *vk.Handler) error {
+func Prepare(ctx context.Context, db Storage, ac AppCache, src *sources.Registry) error {
 	ac.CreateMap(models.MediaGroupMapName)
 
 	ac.CreateMap(models.RoleIDsMapName)
 
+	ac.CreateMap(models.ModerationEditMapName)
+
 	roles, err := db.GetRoleIDs(ctx)
 	if err != nil {
 		return err
@@ -30,7 +32,7 @@ func Prepare(ctx context.Context, db Storage, ac AppCache, vk *vk.Handler) error
 		ac.SetToMap(models.RoleIDsMapName, role.RoleName, role.RoleID, 0)
 	}
 
-	if err := vk.PrepareNewsGatherer(ctx); err != nil {
+	if err := src.PrepareNewsGatherer(ctx); err != nil {
 		return err
 	}
 
@@ -5,8 +5,8 @@ import (
 	"io"
 	"log/slog"
 	"project/internal/clients/tg_bot"
-	"project/internal/files"
 	"project/internal/models"
+	"project/internal/server/telegram/moderation"
 	"time"
 )
 
@@ -15,7 +15,9 @@ type Handler struct {
 	db  Storage
 	cdb Cache
 	ac  AppCache
-	fdb Files
+	md  Media
+	flt Filter
+	mod Moderation
 	log *slog.Logger
 }
 
@@ -24,6 +26,8 @@ type Storage interface {
 	CreateTgChannel(ctx context.Context, group models.TgChannel) error
 	DeleteTgChannel(ctx context.Context, channelID int64) error
 	TgChannelIsExists(ctx context.Context, channelID int64) (bool, error)
+	UpdateTgMessageText(ctx context.Context, ref models.TgMessageRef, text string, entities []models.Entity) error
+	TgChannelIsModerated(ctx context.Context, id int64) (bool, error)
 	InsertTgChannelMessages(ctx context.Context, msgs []models.TgChMessage) error
 }
 
@@ -42,17 +46,28 @@ type AppCache interface {
 	Mutex(name string, fn func())
 }
 
-type Files interface {
-	SaveFile(ctx context.Context, bucketName, fileName string, reader io.Reader, options files.PutObjectOptions) (string, error)
+type Filter interface {
+	Match(kind, key, text string, hasMedia bool) bool
 }
 
-func NewHandler(tg *tg_bot.Client, db Storage, cdb Cache, ac AppCache, fdb Files, log *slog.Logger) *Handler {
+type Moderation interface {
+	Submit(ctx context.Context, p moderation.Preview) error
+}
+
+type Media interface {
+	ReuseTg(ctx context.Context, fileUniqueID string) (models.MediaObject, bool, error)
+	Save(ctx context.Context, reader io.Reader, ext, fileUniqueID string) (models.MediaObject, error)
+}
+
+func NewHandler(tg *tg_bot.Client, db Storage, cdb Cache, ac AppCache, md Media, flt Filter, mod Moderation, log *slog.Logger) *Handler {
 	return &Handler{
 		tg:  tg,
 		db:  db,
 		cdb: cdb,
 		ac:  ac,
-		fdb: fdb,
+		md:  md,
+		flt: flt,
+		mod: mod,
 		log: log,
 	}
 }
@@ -0,0 +1,156 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log/slog"
+	"project/internal/models"
+	"project/internal/pkg/logger/sl"
+	"project/internal/sources"
+	"project/pkg/e"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	backfillMaxPosts = 10000
+	backfillTimeout  = 2 * time.Hour
+
+	backfillDateLayout = "2006-01-02"
+)
+
+// backfill /backfill <Kind> <Key> <N|YYYY-MM-DD> loads the history of a started source in the background
+func (h *Handler) backfill(ctx context.Context, msg *tgbotapi.Message) error {
+	const fn = "chat.backfill"
+
+	h.log.Info("[CHAT]",
+		slog.String("fn", fn),
+		slog.Any("ID", ctx.Value("ID")),
+		slog.String("username", msg.From.UserName),
+		slog.String("cmd", msg.Text),
+	)
+
+	if !defineRole(ctx.Value("Role").(string), models.SubUserRole, models.AdminRole) {
+		return models.ErrSkipEvent
+	}
+
+	log := h.log.With(slog.Any("ID", ctx.Value("ID")))
+
+	args := strings.Fields(strings.TrimPrefix(msg.Text, backfillCmd))
+
+	// The Bot API gives no access to the message history
+	if len(args) > 0 && (args[0] == models.KindTgGroup || args[0] == models.KindTgChannel) {
+		if err := h.sendReplyTgMsg(msg, msgBackfillTgUnsupported); err != nil {
+			log.Error(fn, sl.Err(err))
+		}
+
+		return nil
+	}
+
+	kind, key, opts, err := h.parseBackfillArgs(args)
+	if err != nil {
+		if err := h.sendReplyTgMsg(msg, msgIncorrectArgs); err != nil {
+			log.Error(fn, sl.Err(err))
+		}
+
+		log.Error("Bad request", sl.Err(err))
+
+		return e.Wrap(fn, models.ErrBadRequest)
+	}
+
+	progressMsg := tgbotapi.NewMessage(msg.Chat.ID, fmt.Sprintf(msgBackfillProgress, 0))
+	progressMsg.ReplyToMessageID = msg.MessageID
+
+	sent, err := h.tg.Send(progressMsg)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	setProgress := func(text string) {
+		if _, err := h.tg.Send(tgbotapi.NewEditMessageText(sent.Chat.ID, sent.MessageID, text)); err != nil {
+			log.Error(fn, sl.Err(err))
+		}
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), backfillTimeout)
+		defer cancel()
+
+		stored, err := h.src.Backfill(ctx, kind, key, opts, func(stored int) {
+			setProgress(fmt.Sprintf(msgBackfillProgress, stored))
+		})
+		if err != nil {
+			log.Error(fn, sl.Err(err))
+
+			text, ok := backfillErrText(err)
+			if !ok {
+				text = fmt.Sprintf(msgBackfillFailed, stored)
+			}
+
+			setProgress(text)
+
+			return
+		}
+
+		log.Info("[CHAT] backfill finished",
+			slog.String("kind", kind),
+			slog.String("key", key),
+			slog.Int("stored", stored),
+		)
+
+		setProgress(fmt.Sprintf(msgBackfillDone, stored))
+	}()
+
+	return nil
+}
+
+// parseBackfillArgs the last argument is the number of posts or the date of the oldest post
+func (h *Handler) parseBackfillArgs(args []string) (string, string, sources.BackfillOptions, error) {
+	if len(args) != 3 {
+		return "", "", sources.BackfillOptions{}, ErrNotEnoughArgs
+	}
+
+	if _, ok := h.src.Get(args[0]); !ok {
+		return "", "", sources.BackfillOptions{}, ErrIncorrectArgs
+	}
+
+	opts := sources.BackfillOptions{Limit: backfillMaxPosts}
+
+	if n, err := strconv.Atoi(args[2]); err == nil {
+		if n <= 0 || n > backfillMaxPosts {
+			return "", "", sources.BackfillOptions{}, ErrIncorrectArgs
+		}
+
+		opts.Limit = n
+
+		return args[0], args[1], opts, nil
+	}
+
+	since, err := time.Parse(backfillDateLayout, args[2])
+	if err != nil {
+		return "", "", sources.BackfillOptions{}, ErrIncorrectArgs
+	}
+
+	opts.Since = since
+
+	return args[0], args[1], opts, nil
+}
+
+func backfillErrText(err error) (string, bool) {
+	switch {
+	case errors.Is(err, sources.ErrBackfillUnsupported):
+		return msgBackfillUnsupported, true
+
+	case errors.Is(err, sources.ErrBackfillIsRunning):
+		return msgBackfillIsRunning, true
+
+	case errors.Is(err, sources.ErrSourceNotFound):
+		return msgNewsSourceNotFound, true
+
+	default:
+		return "", false
+	}
+}
@@ -7,9 +7,9 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"log/slog"
 	"math/rand"
-	"project/internal/clients/vk"
 	"project/internal/models"
 	"project/internal/pkg/logger/sl"
+	"project/internal/sources"
 	"project/internal/storage"
 	"project/pkg/e"
 	"regexp"
@@ -27,14 +27,28 @@ const (
 	getTg            = "/get tg"
 	getTgChannel     = "/get tg ch"
 	getTgGroup       = "/get tg group"
-	getVkGroup       = "/get vk"
 
 	permissionCmd = "/perm "
 
-	addVkGroupCmd     = "/add vk "
-	deleteVkGroupCmd  = "/delete vk "
 	addUserChatCmd    = "/add user "
 	deleteUserChatCmd = "/delete user "
+	addSourceCmd      = "/add "
+	deleteSourceCmd   = "/delete "
+
+	filterAddCmd    = "/filter add "
+	filterDeleteCmd = "/filter delete "
+	filterListCmd   = "/filter list"
+
+	moderationOnCmd  = "/moderation on "
+	moderationOffCmd = "/moderation off "
+
+	retractCmd = "/retract "
+
+	backfillCmd = "/backfill "
+
+	vkIntervalCmd = "/vk interval "
+
+	searchCmd = "/search "
 )
 
 var (
@@ -45,6 +59,10 @@ var (
 
 func (h *Handler) ChatCmd(ctx context.Context, update *tgbotapi.Update) error {
 
+	if update.CallbackQuery != nil {
+		return h.callback(ctx, update.CallbackQuery)
+	}
+
 	if update.Message != nil {
 
 		if strings.HasPrefix(update.Message.Text, permissionCmd) {
@@ -84,18 +102,48 @@ func (h *Handler) ChatCmd(ctx context.Context, update *tgbotapi.Update) error {
 		case text == helpChatCmd:
 			return h.helpCmd(ctx, update.Message)
 
-		case strings.HasPrefix(text, addVkGroupCmd):
-			return h.addVkGroup(ctx, update.Message)
-
-		case strings.HasPrefix(text, deleteVkGroupCmd):
-			return h.deleteNewsVkGroup(ctx, update.Message)
-
 		case strings.HasPrefix(text, addUserChatCmd):
 			return h.addUser(ctx, update.Message)
 
 		case strings.HasPrefix(text, deleteUserChatCmd):
 			return h.deleteUser(ctx, update.Message)
 
+		case strings.HasPrefix(text, moderationOnCmd):
+			return h.setModeration(ctx, update.Message, true)
+
+		case strings.HasPrefix(text, moderationOffCmd):
+			return h.setModeration(ctx, update.Message, false)
+
+		case strings.HasPrefix(text, retractCmd):
+			return h.retract(ctx, update.Message)
+
+		case !strings.HasPrefix(text, "/") && h.mod.EditPending(update.Message.From.ID):
+			return h.mod.ApplyEdit(ctx, update.Message)
+
+		case strings.HasPrefix(text, filterAddCmd):
+			return h.addFilterRule(ctx, update.Message)
+
+		case strings.HasPrefix(text, filterDeleteCmd):
+			return h.deleteFilterRule(ctx, update.Message)
+
+		case strings.HasPrefix(text, filterListCmd):
+			return h.listFilterRules(ctx, update.Message)
+
+		case strings.HasPrefix(text, vkIntervalCmd):
+			return h.setVkInterval(ctx, update.Message)
+
+		case strings.HasPrefix(text, backfillCmd):
+			return h.backfill(ctx, update.Message)
+
+		case strings.HasPrefix(text, searchCmd):
+			return h.search(ctx, update.Message)
+
+		case strings.HasPrefix(text, addSourceCmd):
+			return h.addSource(ctx, update.Message)
+
+		case strings.HasPrefix(text, deleteSourceCmd):
+			return h.deleteSource(ctx, update.Message)
+
 		case strings.HasPrefix(text, getSourcesPrefix):
 			return h.getNewsSources(ctx, update.Message)
 
@@ -186,7 +234,7 @@ func (h *Handler) getPermission(ctx context.Context, msg *tgbotapi.Message) erro
 	correctCode, ok := h.ac.GetFromMap(secretCodeMap, msg.From.UserName)
 	if !ok {
 		if err := h.sendReplyTgMsg(msg, msgIncorrectArgs); err != nil {
-			log.Error(fn, err)
+			log.Error(fn, sl.Err(err))
 		}
 
 		log.Warn("Bad request", sl.Err(ErrUserNotFound))
@@ -196,7 +244,7 @@ func (h *Handler) getPermission(ctx context.Context, msg *tgbotapi.Message) erro
 
 	if correctCode != secretCode {
 		if err := h.sendReplyTgMsg(msg, msgIncorrectArgs); err != nil {
-			log.Error(fn, err)
+			log.Error(fn, sl.Err(err))
 		}
 
 		log.Warn("Bad request", sl.Err(ErrIncorrectArgs))
@@ -227,7 +275,7 @@ func (h *Handler) getPermission(ctx context.Context, msg *tgbotapi.Message) erro
 	h.ac.DeleteFromMap(secretCodeMap, msg.From.UserName)
 
 	if err := h.sendReplyTgMsg(msg, msgSuccessfullyGetPermission); err != nil {
-		log.Error(fn, err)
+		log.Error(fn, sl.Err(err))
 	}
 
 	if err := h.cdb.Set(ctx, strconv.FormatInt(from.ID, 10), models.SubUserRole); err != nil {
@@ -270,7 +318,7 @@ func (h *Handler) getNewsSources(ctx context.Context, msg *tgbotapi.Message) err
 
 			for _, tgGroup := range tgGroups {
 				groupsInfo = append(groupsInfo,
-					fmt.Sprintf("%s %s\n", tgGroup.Name, tgGroup.Description),
+					fmt.Sprintf("%s %s (id: %d)\n", tgGroup.Name, tgGroup.Description, tgGroup.GroupID),
 				)
 			}
 			text += strings.Join(groupsInfo, "")
@@ -292,7 +340,7 @@ func (h *Handler) getNewsSources(ctx context.Context, msg *tgbotapi.Message) err
 
 			for _, tgChannel := range tgChannels {
 				channelInfo = append(channelInfo,
-					fmt.Sprintf("%s %s\n", tgChannel.Name, tgChannel.Description),
+					fmt.Sprintf("%s %s (id: %d)\n", tgChannel.Name, tgChannel.Description, tgChannel.ChannelID),
 				)
 			}
 			text += strings.Join(channelInfo, "")
@@ -300,24 +348,28 @@ func (h *Handler) getNewsSources(ctx context.Context, msg *tgbotapi.Message) err
 		}
 	}
 
-	getVkGroups := func() (string, error) {
-		vkGroups, err := h.db.GetVkGroups(ctx)
-		if err != nil {
-			if errors.Is(err, storage.ErrNoRecordsFound) {
-				return "", nil
-			}
-
-			return "", err
-		} else {
-			text := "VK:\n"
-			var groupsInfo []string
-			for _, vkGroup := range vkGroups {
-				groupsInfo = append(groupsInfo,
-					fmt.Sprintf("%s %s\n", vkGroup.Name, vkGroup.Domain),
-				)
+	getSources := func(kind string) func() (string, error) {
+		return func() (string, error) {
+			src, _ := h.src.Get(kind)
+
+			infos, err := src.Describe(ctx)
+			if err != nil {
+				if errors.Is(err, storage.ErrNoRecordsFound) {
+					return "", nil
+				}
+
+				return "", err
+			} else {
+				text := strings.ToUpper(kind) + ":\n"
+				var sourcesInfo []string
+				for _, info := range infos {
+					sourcesInfo = append(sourcesInfo,
+						fmt.Sprintf("%s %s\n", info.Name, info.Key),
+					)
+				}
+				text += strings.Join(sourcesInfo, "")
+				return text, nil
 			}
-			text += strings.Join(groupsInfo, "")
-			return text, nil
 		}
 	}
 
@@ -325,7 +377,11 @@ func (h *Handler) getNewsSources(ctx context.Context, msg *tgbotapi.Message) err
 
 	switch msg.Text {
 	case getAll:
-		funcArr = append(funcArr, getTgGroups, getTgChannels, getVkGroups)
+		funcArr = append(funcArr, getTgGroups, getTgChannels)
+
+		for _, kind := range h.src.Kinds() {
+			funcArr = append(funcArr, getSources(kind))
+		}
 
 	case getTg:
 		funcArr = append(funcArr, getTgGroups, getTgChannels)
@@ -336,10 +392,12 @@ func (h *Handler) getNewsSources(ctx context.Context, msg *tgbotapi.Message) err
 	case getTgGroup:
 		funcArr = append(funcArr, getTgGroups)
 
-	case getVkGroup:
-		funcArr = append(funcArr, getVkGroups)
-
 	default:
+		kind := strings.TrimPrefix(msg.Text, getSourcesPrefix)
+
+		if _, ok := h.src.Get(kind); ok {
+			funcArr = append(funcArr, getSources(kind))
+		}
 	}
 
 	var reqText string
@@ -373,8 +431,8 @@ func (h *Handler) getNewsSources(ctx context.Context, msg *tgbotapi.Message) err
 	return nil
 }
 
-func (h *Handler) addVkGroup(ctx context.Context, msg *tgbotapi.Message) error {
-	const fn = "chat.addVkGroup"
+func (h *Handler) addSource(ctx context.Context, msg *tgbotapi.Message) error {
+	const fn = "chat.addSource"
 
 	h.log.Info("[CHAT]",
 		slog.String("fn", fn),
@@ -389,51 +447,40 @@ func (h *Handler) addVkGroup(ctx context.Context, msg *tgbotapi.Message) error {
 
 	log := h.log.With(slog.Any("ID", ctx.Value("ID")))
 
-	vkDomain := strings.TrimPrefix(msg.Text, addVkGroupCmd)
-
-	if err := h.vk.ListenStart(ctx, vkDomain); err != nil {
-		switch {
-		case errors.Is(err, vk.ErrVkGroupIsExists):
-			if err := h.sendReplyTgMsg(msg, msgVkGroupIsExists); err != nil {
-				log.Error(fn, err)
-			}
-
-			log.Error("Bad request", sl.Err(err))
-
-			return e.Wrap(fn, models.ErrBadRequest)
+	kind, key, err := h.parseSourceArgs(strings.TrimPrefix(msg.Text, addSourceCmd))
+	if err != nil {
+		if err := h.sendReplyTgMsg(msg, msgIncorrectArgs); err != nil {
+			log.Error(fn, sl.Err(err))
+		}
 
-		case errors.Is(err, vk.ErrVkGroupNotFound):
-			if err := h.sendReplyTgMsg(msg, msgVkGroupNotFound); err != nil {
-				log.Error(fn, err)
-			}
+		log.Error("Bad request", sl.Err(err))
 
-			log.Error("Bad request", sl.Err(err))
-
-			return e.Wrap(fn, models.ErrBadRequest)
+		return e.Wrap(fn, models.ErrBadRequest)
+	}
 
-		case errors.Is(err, vk.ErrVkGroupIsPrivate):
-			if err := h.sendReplyTgMsg(msg, msgVkGroupIsPrivate); err != nil {
-				log.Error(fn, err)
+	if err := h.src.ListenStart(ctx, kind, key); err != nil {
+		if text, ok := sourceErrText(err); ok {
+			if err := h.sendReplyTgMsg(msg, text); err != nil {
+				log.Error(fn, sl.Err(err))
 			}
 
 			log.Error("Bad request", sl.Err(err))
 
 			return e.Wrap(fn, models.ErrBadRequest)
-
-		default:
-			return e.Wrap(fn, err)
 		}
+
+		return e.Wrap(fn, err)
 	}
 
-	if err := h.sendReplyTgMsg(msg, msgSuccessfullyAddVKNewsGroup); err != nil {
-		log.Error(fn, err)
+	if err := h.sendReplyTgMsg(msg, msgSuccessfullyAddNewsSource); err != nil {
+		log.Error(fn, sl.Err(err))
 	}
 
 	return nil
 }
 
-func (h *Handler) deleteNewsVkGroup(ctx context.Context, msg *tgbotapi.Message) error {
-	const fn = "chat.deleteNewsVkGroup"
+func (h *Handler) deleteSource(ctx context.Context, msg *tgbotapi.Message) error {
+	const fn = "chat.deleteSource"
 
 	h.log.Info("[CHAT]",
 		slog.String("fn", fn),
@@ -448,12 +495,21 @@ func (h *Handler) deleteNewsVkGroup(ctx context.Context, msg *tgbotapi.Message)
 
 	log := h.log.With(slog.Any("ID", ctx.Value("ID")))
 
-	vkDomain := strings.TrimPrefix(msg.Text, deleteVkGroupCmd)
+	kind, key, err := h.parseSourceArgs(strings.TrimPrefix(msg.Text, deleteSourceCmd))
+	if err != nil {
+		if err := h.sendReplyTgMsg(msg, msgIncorrectArgs); err != nil {
+			log.Error(fn, sl.Err(err))
+		}
+
+		log.Error("Bad request", sl.Err(err))
+
+		return e.Wrap(fn, models.ErrBadRequest)
+	}
 
-	if err := h.vk.Shutdown(ctx, vkDomain); err != nil {
-		if errors.Is(err, vk.ErrVkGroupNotFound) {
-			if err := h.sendReplyTgMsg(msg, msgVkGroupNotFound); err != nil {
-				log.Error(fn, err)
+	if err := h.src.Shutdown(ctx, kind, key); err != nil {
+		if text, ok := sourceErrText(err); ok {
+			if err := h.sendReplyTgMsg(msg, text); err != nil {
+				log.Error(fn, sl.Err(err))
 			}
 
 			log.Error("Bad Request", sl.Err(err))
@@ -464,13 +520,43 @@ func (h *Handler) deleteNewsVkGroup(ctx context.Context, msg *tgbotapi.Message)
 		return e.Wrap(fn, err)
 	}
 
-	if err := h.sendReplyTgMsg(msg, msgSuccessfullyDeleteVkNewsGroup); err != nil {
-		log.Error(fn, err)
+	if err := h.sendReplyTgMsg(msg, msgSuccessfullyDeleteNewsSource); err != nil {
+		log.Error(fn, sl.Err(err))
 	}
 
 	return nil
 }
 
+// parseSourceArgs splits "<Kind> <Key>" and checks that the kind is registered
+func (h *Handler) parseSourceArgs(input string) (kind, key string, err error) {
+	args := strings.Fields(input)
+	if len(args) != 2 {
+		return "", "", ErrNotEnoughArgs
+	}
+
+	if _, ok := h.src.Get(args[0]); !ok {
+		return "", "", ErrIncorrectArgs
+	}
+
+	return args[0], args[1], nil
+}
+
+func sourceErrText(err error) (string, bool) {
+	switch {
+	case errors.Is(err, sources.ErrSourceIsExists):
+		return msgNewsSourceIsExists, true
+
+	case errors.Is(err, sources.ErrSourceNotFound):
+		return msgNewsSourceNotFound, true
+
+	case errors.Is(err, sources.ErrSourceIsPrivate):
+		return msgNewsSourceIsPrivate, true
+
+	default:
+		return "", false
+	}
+}
+
 func (h *Handler) addUser(ctx context.Context, msg *tgbotapi.Message) error {
 	const fn = "chat.addUser"
 
@@ -493,7 +579,7 @@ func (h *Handler) addUser(ctx context.Context, msg *tgbotapi.Message) error {
 
 	if len(args) != 2 {
 		if err := h.sendReplyTgMsg(msg, msgIncorrectArgs); err != nil {
-			log.Error(fn, err)
+			log.Error(fn, sl.Err(err))
 		}
 
 		log.Error("Bad Request", sl.Err(ErrIncorrectArgs))
@@ -508,7 +594,7 @@ func (h *Handler) addUser(ctx context.Context, msg *tgbotapi.Message) error {
 	h.ac.SetToMap(secretCodeMap, username, secretCode, 3*time.Hour)
 
 	if err := h.sendReplyTgMsg(msg, fmt.Sprintf(msgSuccessfullyAddUser, secretCode)); err != nil {
-		log.Error(fn, err)
+		log.Error(fn, sl.Err(err))
 	}
 
 	return nil
@@ -536,7 +622,7 @@ func (h *Handler) deleteUser(ctx context.Context, msg *tgbotapi.Message) error {
 
 	if len(args) != 2 {
 		if err := h.sendReplyTgMsg(msg, msgNotEnoughArgs); err != nil {
-			log.Error(fn, err)
+			log.Error(fn, sl.Err(err))
 		}
 
 		log.Error("Bad Request", sl.Err(ErrNotEnoughArgs))
@@ -550,7 +636,7 @@ func (h *Handler) deleteUser(ctx context.Context, msg *tgbotapi.Message) error {
 	if err != nil {
 		if errors.Is(err, storage.ErrNoRecordsFound) {
 			if err := h.sendReplyTgMsg(msg, msgUserNotFound); err != nil {
-				log.Error(fn, err)
+				log.Error(fn, sl.Err(err))
 			}
 
 			log.Error("Bad Request", sl.Err(ErrUserNotFound))
@@ -568,7 +654,7 @@ func (h *Handler) deleteUser(ctx context.Context, msg *tgbotapi.Message) error {
 
 	if defineRole(userRole, models.AdminRole) {
 		if err := h.sendReplyTgMsg(msg, msgIncorrectArgs); err != nil {
-			log.Error(fn, err)
+			log.Error(fn, sl.Err(err))
 		}
 
 		log.Error("Bad Request", sl.Err(ErrIncorrectArgs))
@@ -581,7 +667,7 @@ func (h *Handler) deleteUser(ctx context.Context, msg *tgbotapi.Message) error {
 	}
 
 	if err := h.sendReplyTgMsg(msg, msgSuccessfullyDeleteUser); err != nil {
-		log.Error(fn, err)
+		log.Error(fn, sl.Err(err))
 	}
 
 	if err := h.cdb.Del(ctx, strconv.FormatInt(user.UserID, 10)); err != nil {
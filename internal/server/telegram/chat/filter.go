@@ -0,0 +1,163 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log/slog"
+	"project/internal/filter"
+	"project/internal/models"
+	"project/internal/pkg/logger/sl"
+	"project/internal/storage"
+	"project/pkg/e"
+	"strconv"
+	"strings"
+)
+
+// addFilterRule /filter add <source> <rule> [value]
+func (h *Handler) addFilterRule(ctx context.Context, msg *tgbotapi.Message) error {
+	const fn = "chat.addFilterRule"
+
+	h.log.Info("[CHAT]",
+		slog.String("fn", fn),
+		slog.Any("ID", ctx.Value("ID")),
+		slog.String("username", msg.From.UserName),
+		slog.String("cmd", msg.Text),
+	)
+
+	if !defineRole(ctx.Value("Role").(string), models.SubUserRole, models.AdminRole) {
+		return models.ErrSkipEvent
+	}
+
+	log := h.log.With(slog.Any("ID", ctx.Value("ID")))
+
+	// The value is the rest of the line, regexes may contain spaces
+	args := strings.SplitN(strings.TrimSpace(strings.TrimPrefix(msg.Text, filterAddCmd)), " ", 3)
+	if len(args) < 2 {
+		if err := h.sendReplyTgMsg(msg, msgNotEnoughArgs); err != nil {
+			log.Error(fn, sl.Err(err))
+		}
+
+		log.Error("Bad request", sl.Err(ErrNotEnoughArgs))
+
+		return e.Wrap(fn, models.ErrBadRequest)
+	}
+
+	rule := models.FilterRule{
+		Scope: args[0],
+		Rule:  args[1],
+	}
+
+	if len(args) == 3 {
+		rule.Value = args[2]
+	}
+
+	id, err := h.flt.Add(ctx, rule)
+	if err != nil {
+		if errors.Is(err, filter.ErrUnknownRule) || errors.Is(err, filter.ErrBadValue) || errors.Is(err, filter.ErrBadScope) {
+			if err := h.sendReplyTgMsg(msg, msgIncorrectArgs); err != nil {
+				log.Error(fn, sl.Err(err))
+			}
+
+			log.Error("Bad request", sl.Err(err))
+
+			return e.Wrap(fn, models.ErrBadRequest)
+		}
+
+		return e.Wrap(fn, err)
+	}
+
+	if err := h.sendReplyTgMsg(msg, fmt.Sprintf(msgSuccessfullyAddFilterRule, id)); err != nil {
+		log.Error(fn, sl.Err(err))
+	}
+
+	return nil
+}
+
+// deleteFilterRule /filter delete <id>
+func (h *Handler) deleteFilterRule(ctx context.Context, msg *tgbotapi.Message) error {
+	const fn = "chat.deleteFilterRule"
+
+	h.log.Info("[CHAT]",
+		slog.String("fn", fn),
+		slog.Any("ID", ctx.Value("ID")),
+		slog.String("username", msg.From.UserName),
+		slog.String("cmd", msg.Text),
+	)
+
+	if !defineRole(ctx.Value("Role").(string), models.SubUserRole, models.AdminRole) {
+		return models.ErrSkipEvent
+	}
+
+	log := h.log.With(slog.Any("ID", ctx.Value("ID")))
+
+	id, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(msg.Text, filterDeleteCmd)), 10, 64)
+	if err != nil {
+		if err := h.sendReplyTgMsg(msg, msgIncorrectArgs); err != nil {
+			log.Error(fn, sl.Err(err))
+		}
+
+		log.Error("Bad request", sl.Err(err))
+
+		return e.Wrap(fn, models.ErrBadRequest)
+	}
+
+	if err := h.flt.Delete(ctx, id); err != nil {
+		if errors.Is(err, storage.ErrNoRecordsFound) {
+			if err := h.sendReplyTgMsg(msg, msgFilterRuleNotFound); err != nil {
+				log.Error(fn, sl.Err(err))
+			}
+
+			log.Error("Bad request", sl.Err(err))
+
+			return e.Wrap(fn, models.ErrBadRequest)
+		}
+
+		return e.Wrap(fn, err)
+	}
+
+	if err := h.sendReplyTgMsg(msg, msgSuccessfullyDeleteFilterRule); err != nil {
+		log.Error(fn, sl.Err(err))
+	}
+
+	return nil
+}
+
+// listFilterRules /filter list [source]
+func (h *Handler) listFilterRules(ctx context.Context, msg *tgbotapi.Message) error {
+	const fn = "chat.listFilterRules"
+
+	h.log.Info("[CHAT]",
+		slog.String("fn", fn),
+		slog.Any("ID", ctx.Value("ID")),
+		slog.String("username", msg.From.UserName),
+		slog.String("cmd", msg.Text),
+	)
+
+	if !defineRole(ctx.Value("Role").(string), models.SubUserRole, models.AdminRole) {
+		return models.ErrSkipEvent
+	}
+
+	rules := h.flt.Rules(strings.TrimSpace(strings.TrimPrefix(msg.Text, filterListCmd)))
+
+	text := msgFilterRulesNotFound
+
+	if len(rules) > 0 {
+		var b strings.Builder
+
+		b.WriteString("Фильтры:\n")
+
+		for _, rule := range rules {
+			fmt.Fprintf(&b, "%d: %s %s %s\n", rule.ID, rule.Scope, rule.Rule, rule.Value)
+		}
+
+		text = b.String()
+	}
+
+	if err := h.sendReplyTgMsg(msg, text); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log/slog"
+	"project/internal/models"
+	"project/internal/pkg/logger/sl"
+	"project/internal/sources"
+	"project/pkg/e"
+	"strings"
+	"time"
+)
+
+const vkKind = "vk"
+
+// setVkInterval /vk interval <Domain> <min> <max>, e.g. /vk interval durov 30s 2h
+func (h *Handler) setVkInterval(ctx context.Context, msg *tgbotapi.Message) error {
+	const fn = "chat.setVkInterval"
+
+	h.log.Info("[CHAT]",
+		slog.String("fn", fn),
+		slog.Any("ID", ctx.Value("ID")),
+		slog.String("username", msg.From.UserName),
+		slog.String("cmd", msg.Text),
+	)
+
+	if !defineRole(ctx.Value("Role").(string), models.SubUserRole, models.AdminRole) {
+		return models.ErrSkipEvent
+	}
+
+	log := h.log.With(slog.Any("ID", ctx.Value("ID")))
+
+	domain, min, max, err := parseIntervalArgs(strings.TrimPrefix(msg.Text, vkIntervalCmd))
+	if err != nil {
+		if err := h.sendReplyTgMsg(msg, msgIncorrectArgs); err != nil {
+			log.Error(fn, sl.Err(err))
+		}
+
+		log.Error("Bad request", sl.Err(err))
+
+		return e.Wrap(fn, models.ErrBadRequest)
+	}
+
+	if err := h.src.SetInterval(ctx, vkKind, domain, min, max); err != nil {
+		text, ok := sourceErrText(err)
+
+		if errors.Is(err, sources.ErrBadInterval) {
+			text, ok = msgBadInterval, true
+		}
+
+		if ok {
+			if err := h.sendReplyTgMsg(msg, text); err != nil {
+				log.Error(fn, sl.Err(err))
+			}
+
+			log.Error("Bad request", sl.Err(err))
+
+			return e.Wrap(fn, models.ErrBadRequest)
+		}
+
+		return e.Wrap(fn, err)
+	}
+
+	if err := h.sendReplyTgMsg(msg, msgSuccessfullySetInterval); err != nil {
+		log.Error(fn, sl.Err(err))
+	}
+
+	return nil
+}
+
+func parseIntervalArgs(input string) (string, time.Duration, time.Duration, error) {
+	args := strings.Fields(input)
+	if len(args) != 3 {
+		return "", 0, 0, ErrNotEnoughArgs
+	}
+
+	min, err := time.ParseDuration(args[1])
+	if err != nil {
+		return "", 0, 0, ErrIncorrectArgs
+	}
+
+	max, err := time.ParseDuration(args[2])
+	if err != nil {
+		return "", 0, 0, ErrIncorrectArgs
+	}
+
+	return args[0], min, max, nil
+}
@@ -1,18 +1,35 @@
 package chat
 
 const (
-	msgSuccessfullyGetPermission     = `Вам успешно предоставлены права доступа`
-	msgSuccessfullyDeleteUser        = `Пользователь успешно удалён`
-	msgSuccessfullyAddVKNewsGroup    = `VK группа успешна добавлена`
-	msgSuccessfullyDeleteVkNewsGroup = `VK группа успешна удалена`
+	msgSuccessfullyGetPermission    = `Вам успешно предоставлены права доступа`
+	msgSuccessfullyDeleteUser       = `Пользователь успешно удалён`
+	msgSuccessfullyAddNewsSource    = `Новостной источник успешно добавлен`
+	msgSuccessfullyDeleteNewsSource = `Новостной источник успешно удалён`
+	msgSuccessfullyAddFilterRule    = `Фильтр успешно добавлен, id: %d`
+	msgSuccessfullyDeleteFilterRule = `Фильтр успешно удалён`
+	msgModerationOn                 = `Модерация включена, новости источника будут приходить вам на одобрение`
+	msgModerationOff                = `Модерация выключена`
+	msgSuccessfullyRetract          = `Новость удалена из ленты`
+	msgSuccessfullySetInterval      = `Интервал опроса группы изменён`
 
 	msgNewsSourcesNotFound = `Новостные источники не найдены`
 	msgUserNotFound        = `Пользователь не найден`
 	msgNotEnoughArgs       = `Некорректное кол-во аргументов`
 	msgIncorrectArgs       = `Неверные аргументы`
-	msgVkGroupNotFound     = `VK группа не найдена`
-	msgVkGroupIsPrivate    = `VK группа приватная`
-	msgVkGroupIsExists     = `VK группа уже инициализирована`
+	msgNewsSourceNotFound  = `Новостной источник не найден`
+	msgNewsSourceIsPrivate = `Новостной источник приватный`
+	msgNewsSourceIsExists  = `Новостной источник уже инициализирован`
+	msgFilterRuleNotFound  = `Фильтр не найден`
+	msgFilterRulesNotFound = `Фильтры не найдены`
+	msgNewsNotFound        = `Новость не найдена`
+	msgBadInterval         = `Неверный интервал: минимум от 10s и не больше максимума`
+
+	msgBackfillProgress      = `Загрузка истории, добавлено новостей: %d`
+	msgBackfillDone          = `Загрузка истории завершена, добавлено новостей: %d`
+	msgBackfillFailed        = `Загрузка истории прервана из-за ошибки, добавлено новостей: %d`
+	msgBackfillIsRunning     = `Загрузка истории этого источника уже идёт`
+	msgBackfillUnsupported   = `Источник не поддерживает загрузку истории`
+	msgBackfillTgUnsupported = `Telegram не даёт ботам доступ к истории сообщений, загрузить её нельзя`
 )
 
 const msgSuccessfullyAddUser = `Передайте пользователю секретный код для получения прав доступа
@@ -29,9 +46,25 @@ const msgSubUserHelp = `Команды:
 /get tg ch    - Получение Telegram каналов
 /get tg group - Получение Telegram групп
 /get vk       - Получение VK групп
+/get rss      - Получение RSS лент
 
 /add vk <Domain>    - Добавление VK группы как новостного источника
 /delete vk <Domain> - Удаление VK группы
+/vk interval <Domain> <min> <max> - Интервал опроса VK группы, пример: /vk interval durov 30s 2h
+
+/add rss <Url>    - Добавление RSS/Atom ленты как новостного источника
+/delete rss <Url> - Удаление RSS/Atom ленты
+
+/backfill vk <Domain> <N>          - Загрузка последних N постов добавленной VK группы
+/backfill vk <Domain> <YYYY-MM-DD> - Загрузка постов начиная с даты
+
+/search <query> - Поиск по архиву новостей, пример: /search выборы -погода
+
+/filter list [source]                 - Получение фильтров новостей
+/filter add <source> <rule> [value]   - Добавление фильтра
+/filter delete <id>                   - Удаление фильтра
+  source: all, tg_group, tg_channel, vk, rss или <kind>:<key> (vk:durov, tg_group:<chat id>)
+  rule: include/exclude <regex>, keyword/stopword <word>, min_len/max_len <N>, media, hashtag <tag>
 
 Для добавления Telegram групп и каналов как новостных источников нужно добавить меня в них, и выдать права для доступа к сообщениям
 `
@@ -42,12 +75,33 @@ const msgAdminHelp = `Команды:
 /get tg ch    - Получение Telegram каналов
 /get tg group - Получение Telegram групп
 /get vk       - Получение VK групп
+/get rss      - Получение RSS лент
 
 /add user <@Username>    - Добавление Sub User
 /delete user <@Username> - Удаление Sub User
 
+/moderation on <tg_group|tg_channel> <id>  - Включение модерации новостей источника
+/moderation off <tg_group|tg_channel> <id> - Выключение модерации
+
+/retract <id> - Удаление новости из ленты (id новости на сайте)
+
 /add vk <Domain>    - Добавление VK группы как новостного источника
 /delete vk <Domain> - Удаление VK группы
+/vk interval <Domain> <min> <max> - Интервал опроса VK группы, пример: /vk interval durov 30s 2h
+
+/add rss <Url>    - Добавление RSS/Atom ленты как новостного источника
+/delete rss <Url> - Удаление RSS/Atom ленты
+
+/backfill vk <Domain> <N>          - Загрузка последних N постов добавленной VK группы
+/backfill vk <Domain> <YYYY-MM-DD> - Загрузка постов начиная с даты
+
+/search <query> - Поиск по архиву новостей, пример: /search выборы -погода
+
+/filter list [source]                 - Получение фильтров новостей
+/filter add <source> <rule> [value]   - Добавление фильтра
+/filter delete <id>                   - Удаление фильтра
+  source: all, tg_group, tg_channel, vk, rss или <kind>:<key> (vk:durov, tg_group:<chat id>)
+  rule: include/exclude <regex>, keyword/stopword <word>, min_len/max_len <N>, media, hashtag <tag>
 
 Для добавления Telegram групп и каналов как новостных источников нужно добавить меня в них, и выдать права для доступа к сообщениям
 `
@@ -0,0 +1,159 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log/slog"
+	"project/internal/models"
+	"project/internal/pkg/logger/sl"
+	"project/internal/server/telegram/moderation"
+	"project/internal/storage"
+	"project/pkg/e"
+	"strconv"
+	"strings"
+)
+
+// retract /retract <id> removes the news item from the web feed
+func (h *Handler) retract(ctx context.Context, msg *tgbotapi.Message) error {
+	const fn = "chat.retract"
+
+	h.log.Info("[CHAT]",
+		slog.String("fn", fn),
+		slog.Any("ID", ctx.Value("ID")),
+		slog.String("username", msg.From.UserName),
+		slog.String("cmd", msg.Text),
+	)
+
+	if !defineRole(ctx.Value("Role").(string), models.AdminRole) {
+		return models.ErrSkipEvent
+	}
+
+	log := h.log.With(slog.Any("ID", ctx.Value("ID")))
+
+	id, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(msg.Text, retractCmd)), 10, 64)
+	if err != nil {
+		if err := h.sendReplyTgMsg(msg, msgIncorrectArgs); err != nil {
+			log.Error(fn, sl.Err(err))
+		}
+
+		log.Error("Bad request", sl.Err(err))
+
+		return e.Wrap(fn, models.ErrBadRequest)
+	}
+
+	if err := h.db.DeleteWebMessage(ctx, id); err != nil {
+		if errors.Is(err, storage.ErrNoRecordsFound) {
+			if err := h.sendReplyTgMsg(msg, msgNewsNotFound); err != nil {
+				log.Error(fn, sl.Err(err))
+			}
+
+			log.Error("Bad request", sl.Err(err))
+
+			return e.Wrap(fn, models.ErrBadRequest)
+		}
+
+		return e.Wrap(fn, err)
+	}
+
+	if err := h.sendReplyTgMsg(msg, msgSuccessfullyRetract); err != nil {
+		log.Error(fn, sl.Err(err))
+	}
+
+	return nil
+}
+
+func (h *Handler) callback(ctx context.Context, cq *tgbotapi.CallbackQuery) error {
+	const fn = "chat.callback"
+
+	if !moderation.IsCallback(cq.Data) {
+		return models.ErrSkipEvent
+	}
+
+	role, err := h.getRole(ctx, cq.From.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNoRecordsFound):
+			return models.ErrUnknownUser
+		default:
+			return err
+		}
+	}
+
+	if !defineRole(role, models.SubUserRole, models.AdminRole) {
+		return models.ErrSkipEvent
+	}
+
+	if err := h.mod.Callback(ctx, cq); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+// setModeration /moderation on|off <tg_group|tg_channel> <chat id>
+func (h *Handler) setModeration(ctx context.Context, msg *tgbotapi.Message, moderated bool) error {
+	const fn = "chat.setModeration"
+
+	h.log.Info("[CHAT]",
+		slog.String("fn", fn),
+		slog.Any("ID", ctx.Value("ID")),
+		slog.String("username", msg.From.UserName),
+		slog.String("cmd", msg.Text),
+	)
+
+	if !defineRole(ctx.Value("Role").(string), models.AdminRole) {
+		return models.ErrSkipEvent
+	}
+
+	log := h.log.With(slog.Any("ID", ctx.Value("ID")))
+
+	input := strings.TrimPrefix(strings.TrimPrefix(msg.Text, moderationOnCmd), moderationOffCmd)
+
+	args := strings.Fields(input)
+	if len(args) != 2 {
+		if err := h.sendReplyTgMsg(msg, msgNotEnoughArgs); err != nil {
+			log.Error(fn, sl.Err(err))
+		}
+
+		log.Error("Bad request", sl.Err(ErrNotEnoughArgs))
+
+		return e.Wrap(fn, models.ErrBadRequest)
+	}
+
+	chatID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || (args[0] != models.KindTgGroup && args[0] != models.KindTgChannel) {
+		if err := h.sendReplyTgMsg(msg, msgIncorrectArgs); err != nil {
+			log.Error(fn, sl.Err(err))
+		}
+
+		log.Error("Bad request", sl.Err(ErrIncorrectArgs))
+
+		return e.Wrap(fn, models.ErrBadRequest)
+	}
+
+	if err := h.db.SetTgSourceModerated(ctx, args[0], chatID, moderated); err != nil {
+		if errors.Is(err, storage.ErrNoRecordsFound) {
+			if err := h.sendReplyTgMsg(msg, msgNewsSourceNotFound); err != nil {
+				log.Error(fn, sl.Err(err))
+			}
+
+			log.Error("Bad request", sl.Err(err))
+
+			return e.Wrap(fn, models.ErrBadRequest)
+		}
+
+		return e.Wrap(fn, err)
+	}
+
+	text := msgModerationOff
+	if moderated {
+		text = msgModerationOn
+	}
+
+	if err := h.sendReplyTgMsg(msg, text); err != nil {
+		log.Error(fn, sl.Err(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log/slog"
+	"project/internal/models"
+	"project/internal/pkg/logger/sl"
+	"project/internal/storage"
+	"project/pkg/e"
+	"strings"
+)
+
+const searchResultsLimit = 5
+
+// searchSnippet marks the matched words with «» in the plain text reply
+var searchSnippet = strings.NewReplacer(
+	models.SnippetStart, "«",
+	models.SnippetStop, "»",
+	"\n", " ",
+)
+
+// search /search <query>
+func (h *Handler) search(ctx context.Context, msg *tgbotapi.Message) error {
+	const fn = "chat.search"
+
+	h.log.Info("[CHAT]",
+		slog.String("fn", fn),
+		slog.Any("ID", ctx.Value("ID")),
+		slog.String("username", msg.From.UserName),
+		slog.String("cmd", msg.Text),
+	)
+
+	if !defineRole(ctx.Value("Role").(string), models.SubUserRole, models.AdminRole) {
+		return models.ErrSkipEvent
+	}
+
+	log := h.log.With(slog.Any("ID", ctx.Value("ID")))
+
+	query := strings.TrimSpace(strings.TrimPrefix(msg.Text, searchCmd))
+	if query == "" {
+		if err := h.sendReplyTgMsg(msg, msgNotEnoughArgs); err != nil {
+			log.Error(fn, sl.Err(err))
+		}
+
+		log.Error("Bad request", sl.Err(ErrNotEnoughArgs))
+
+		return e.Wrap(fn, models.ErrBadRequest)
+	}
+
+	hits, err := h.db.SearchWebMessages(ctx, models.SearchQuery{Query: query, Limit: searchResultsLimit})
+	if err != nil && !errors.Is(err, storage.ErrNoRecordsFound) {
+		return e.Wrap(fn, err)
+	}
+
+	text := msgNewsNotFound
+
+	if len(hits) > 0 {
+		var b strings.Builder
+
+		b.WriteString("Найденные новости:\n")
+
+		for _, hit := range hits {
+			fmt.Fprintf(&b, "\n%d: %s, %s\n%s\n",
+				hit.Message.ID,
+				hit.Message.GroupName,
+				hit.Message.CreatedAt.Format("02.01.2006 15:04"),
+				searchSnippet.Replace(hit.Snippet),
+			)
+		}
+
+		text = b.String()
+	}
+
+	if err := h.sendReplyTgMsg(msg, text); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"log/slog"
 	"project/internal/clients/tg_bot"
-	"project/internal/clients/vk"
+	"project/internal/filter"
 	"project/internal/models"
+	"project/internal/server/telegram/moderation"
+	"project/internal/sources"
 	"time"
 )
 
@@ -15,7 +17,9 @@ const (
 
 type Handler struct {
 	tg  *tg_bot.Client
-	vk  *vk.Handler
+	src *sources.Registry
+	flt *filter.Engine
+	mod *moderation.Moderator
 	db  Storage
 	cdb Cache
 	ac  AppCache
@@ -25,11 +29,13 @@ type Handler struct {
 type Storage interface {
 	GetTgGroups(ctx context.Context) ([]models.TgGroup, error)
 	GetTgChannels(ctx context.Context) ([]models.TgChannel, error)
-	GetVkGroups(ctx context.Context) ([]models.VkGroup, error)
 	InsertUsers(ctx context.Context, users []models.User) error
 	DeleteUser(ctx context.Context, userID int64) error
 	GetUserRole(ctx context.Context, userID int64) (string, error)
 	GetUserWithUsername(ctx context.Context, username string) (models.User, error)
+	DeleteWebMessage(ctx context.Context, id int64) error
+	SearchWebMessages(ctx context.Context, query models.SearchQuery) ([]models.SearchHit, error)
+	SetTgSourceModerated(ctx context.Context, kind string, chatID int64, moderated bool) error
 }
 
 type Cache interface {
@@ -44,10 +50,12 @@ type AppCache interface {
 	DeleteFromMap(name, key string)
 }
 
-func NewHandler(tg *tg_bot.Client, vk *vk.Handler, db Storage, cdb Cache, ac AppCache, log *slog.Logger) *Handler {
+func NewHandler(tg *tg_bot.Client, src *sources.Registry, flt *filter.Engine, mod *moderation.Moderator, db Storage, cdb Cache, ac AppCache, log *slog.Logger) *Handler {
 	return &Handler{
 		tg:  tg,
-		vk:  vk,
+		src: src,
+		flt: flt,
+		mod: mod,
 		db:  db,
 		cdb: cdb,
 		ac:  ac,
@@ -5,19 +5,17 @@ import (
 	"errors"
 	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-	"io"
 	"log/slog"
-	"mime"
 	"net/http"
 	"path/filepath"
-	"project/internal/files"
+	"project/internal/media"
 	"project/internal/models"
 	"project/internal/pkg/logger/sl"
+	"project/internal/pkg/tgtext"
+	"project/internal/server/telegram/moderation"
 	"project/internal/storage"
 	"project/pkg/e"
-	"regexp"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 )
@@ -65,6 +63,9 @@ func (h *Handler) GroupCmd(ctx context.Context, update *tgbotapi.Update) error {
 	}
 
 	switch {
+	case update.EditedMessage != nil:
+		return h.editMsg(ctx, update.EditedMessage)
+
 	case update.MyChatMember != nil && update.MyChatMember.NewChatMember.Status == "left":
 		return h.leaveChat(ctx, update.MyChatMember)
 
@@ -130,7 +131,7 @@ func (h *Handler) updateGroupToSupergroup(ctx context.Context, msg *tgbotapi.Mes
 	if err := h.db.UpdateTgGroup(ctx, msg.Chat.ID, supergroup); err != nil {
 		if errors.Is(err, storage.ErrNoRecordsFound) {
 			if err := h.tg.LeaveChat(ctx, msg.Chat.ID); err != nil {
-				log.Error(fn, err)
+				log.Error(fn, sl.Err(err))
 			}
 
 			return models.ErrSkipEvent
@@ -318,7 +319,7 @@ func (h *Handler) handleSaveTextMessage(ctx context.Context, msg *tgbotapi.Messa
 
 	var msgText = msg.Text
 
-	if !isNewsMessage(msg.Text) {
+	if !h.isNewsMessage(msg, msg.Text) {
 		log.Debug(fn, slog.Bool("msg type is news", false))
 		return models.ErrSkipEvent
 	}
@@ -330,13 +331,14 @@ func (h *Handler) handleSaveTextMessage(ctx context.Context, msg *tgbotapi.Messa
 		GroupID:   msg.Chat.ID,
 		Username:  getUsername(msg.From),
 		Text:      msgText,
+		Entities:  tgtext.FromTelegram(msg.Entities),
 		Metadata:  nil,
 		CreatedAt: time.Unix(int64(msg.Date), 0),
 	}
 
 	msgs := []models.TgGroupMessage{message}
 
-	if err := h.db.InsertTgGroupMessages(ctx, msgs); err != nil {
+	if err := h.insertMsgs(ctx, msg.Chat.Title, msgs); err != nil {
 		return e.Wrap(fn, err)
 	}
 
@@ -356,7 +358,7 @@ func (h *Handler) handleSaveCaptionMessage(ctx context.Context, msg *tgbotapi.Me
 
 	var msgText = msg.Caption
 
-	if !isNewsMessage(msgText) {
+	if !h.isNewsMessage(msg, msgText) {
 		log.Debug(fn, slog.Bool("msg type is news", false))
 		return models.ErrSkipEvent
 	}
@@ -372,8 +374,9 @@ func (h *Handler) handleSaveCaptionMessage(ctx context.Context, msg *tgbotapi.Me
 	)
 
 	metaPair := models.TgMetaPair{
-		ID:   msgMetadata.ID,
-		Type: msgMetadata.Type,
+		ID:       msgMetadata.ID,
+		UniqueID: msgMetadata.UniqueID,
+		Type:     msgMetadata.Type,
 	}
 
 	if msg.MediaGroupID != "" {
@@ -386,7 +389,7 @@ func (h *Handler) handleSaveCaptionMessage(ctx context.Context, msg *tgbotapi.Me
 func (h *Handler) handleSaveSingleCaptionMessage(ctx context.Context, msg *tgbotapi.Message, metaPair models.TgMetaPair, msgText string) error {
 	const fn = "group.handleSaveSingleCaptionMessage"
 
-	metaUrl, err := h.loadMetaByTgID(metaPair.ID, loadMediaFromTgTimeout)
+	meta, err := h.loadMetaByTgID(metaPair, loadMediaFromTgTimeout)
 	if err != nil {
 		return e.Wrap(fn, err)
 	}
@@ -396,16 +399,14 @@ func (h *Handler) handleSaveSingleCaptionMessage(ctx context.Context, msg *tgbot
 		GroupID:   msg.Chat.ID,
 		Username:  getUsername(msg.From),
 		Text:      msgText,
-		Metadata: []models.MetaPair{{
-			Url:  metaUrl,
-			Type: metaPair.Type,
-		}},
+		Entities:  tgtext.FromTelegram(msg.CaptionEntities),
+		Metadata:  []models.MetaPair{meta},
 		CreatedAt: time.Unix(int64(msg.Date), 0),
 	}
 
 	msgs := []models.TgGroupMessage{message}
 
-	if err := h.db.InsertTgGroupMessages(ctx, msgs); err != nil {
+	if err := h.insertMsgs(ctx, msg.Chat.Title, msgs); err != nil {
 		return e.Wrap(fn, err)
 	}
 
@@ -434,19 +435,16 @@ func (h *Handler) handleSaveMediaGroupCaptionMessage(ctx context.Context, msg *t
 			go func() {
 				defer wg.Done()
 
-				metaUrl, err := h.loadMetaByTgID(pairID.ID, loadMediaFromTgTimeout)
+				meta, err := h.loadMetaByTgID(pairID, loadMediaFromTgTimeout)
 				if err != nil {
-					log.Error(fn, err)
+					log.Error(fn, sl.Err(err))
 					return
 				}
 
 				mu.Lock()
 				defer mu.Unlock()
 
-				metaPairs = append(metaPairs, models.MetaPair{
-					Url:  metaUrl,
-					Type: pairID.Type,
-				})
+				metaPairs = append(metaPairs, meta)
 			}()
 		}
 
@@ -461,7 +459,7 @@ func (h *Handler) handleSaveMediaGroupCaptionMessage(ctx context.Context, msg *t
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		if err := h.db.InsertTgGroupMessages(ctx, msgs); err != nil {
+		if err := h.insertMsgs(ctx, msg.Chat.Title, msgs); err != nil {
 			log.Error(fn, sl.Err(err))
 		}
 	}
@@ -476,6 +474,7 @@ func (h *Handler) handleSaveMediaGroupCaptionMessage(ctx context.Context, msg *t
 			GroupID:    msg.Chat.ID,
 			Username:   getUsername(msg.From),
 			Text:       msgText,
+			Entities:   tgtext.FromTelegram(msg.CaptionEntities),
 			MetadataID: metadata,
 			CreatedAt:  time.Unix(int64(msg.Date), 0),
 		}
@@ -498,6 +497,7 @@ func (h *Handler) handleSaveMediaGroupCaptionMessage(ctx context.Context, msg *t
 			existingMsg.GroupID = msg.Chat.ID
 			existingMsg.Username = getUsername(msg.From)
 			existingMsg.Text = msgText
+			existingMsg.Entities = tgtext.FromTelegram(msg.CaptionEntities)
 			existingMsg.CreatedAt = time.Unix(int64(msg.Date), 0)
 
 			existingMsg.MetadataID = append(existingMsg.MetadataID, metaPair)
@@ -529,8 +529,9 @@ func (h *Handler) handleSaveMessageMedia(ctx context.Context, msg *tgbotapi.Mess
 	)
 
 	metaPair := models.TgMetaPair{
-		ID:   msgMetadata.ID,
-		Type: msgMetadata.Type,
+		ID:       msgMetadata.ID,
+		UniqueID: msgMetadata.UniqueID,
+		Type:     msgMetadata.Type,
 	}
 
 	resp, ok := h.ac.GetFromMap(models.MediaGroupMapName, msg.MediaGroupID)
@@ -572,26 +573,30 @@ func getMetadataFromTgMsg(msg *tgbotapi.Message) models.TgMetaPair {
 	switch {
 	case msg.Photo != nil:
 		return models.TgMetaPair{
-			ID:   msg.Photo[len(msg.Photo)-1].FileID,
-			Type: models.MsgPhoto,
+			ID:       msg.Photo[len(msg.Photo)-1].FileID,
+			UniqueID: msg.Photo[len(msg.Photo)-1].FileUniqueID,
+			Type:     models.MsgPhoto,
 		}
 
 	case msg.Video != nil:
 		return models.TgMetaPair{
-			ID:   msg.Video.FileID,
-			Type: models.MsgVideo,
+			ID:       msg.Video.FileID,
+			UniqueID: msg.Video.FileUniqueID,
+			Type:     models.MsgVideo,
 		}
 
 	case msg.Audio != nil:
 		return models.TgMetaPair{
-			ID:   msg.Audio.FileID,
-			Type: models.MsgAudio,
+			ID:       msg.Audio.FileID,
+			UniqueID: msg.Audio.FileUniqueID,
+			Type:     models.MsgAudio,
 		}
 
 	case msg.Document != nil:
 		return models.TgMetaPair{
-			ID:   msg.Document.FileID,
-			Type: models.MsgDocument,
+			ID:       msg.Document.FileID,
+			UniqueID: msg.Document.FileUniqueID,
+			Type:     models.MsgDocument,
 		}
 
 	default:
@@ -599,62 +604,34 @@ func getMetadataFromTgMsg(msg *tgbotapi.Message) models.TgMetaPair {
 	}
 }
 
-// TODO: Добавь ещё
-
-func isNewsMessage(text string) bool {
-	keywords := []string{
-		`(?i)новост`,
-		`(?i)событи`,
-		`(?i)информаци`,
-		`(?i)объявлени`,
-		`(?i)репортаж`,
-		`(?i)экстренное`,
-		`(?i)важн`,
-		`(?i)анализ`,
-		`(?i)news`,
-		`(?i)event`,
-		`(?i)information`,
-		`(?i)announcement`,
-		`(?i)report`,
-		`(?i)breaking`,
-		`(?i)urgent`,
-		`(?i)update`,
-	}
-
-	for _, keyword := range keywords {
-		matched, err := regexp.MatchString(keyword, text)
-		if err == nil && matched {
-			return true
-		}
-	}
-
-	return false
-}
-
-const mediaBucket = models.MediaBucket
-
-func (h *Handler) loadMetaByTgID(fileID string, timeout time.Duration) (string, error) {
+// loadMetaByTgID stores the file once per content, files seen before are not downloaded again
+func (h *Handler) loadMetaByTgID(meta models.TgMetaPair, timeout time.Duration) (models.MetaPair, error) {
 	const fn = "group.loadMetaByTgID"
 
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	file, err := h.tg.GetFile(tgbotapi.FileConfig{FileID: fileID})
+	obj, ok, err := h.md.ReuseTg(ctx, meta.UniqueID)
 	if err != nil {
-		return "", e.Wrap(fn, err)
+		return models.MetaPair{}, e.Wrap(fn, err)
 	}
 
-	path := strings.Split(file.FilePath, "/")
-	mediaType := path[0]
-	fileName := path[1]
+	if ok {
+		return media.MetaPair(obj, meta.Type), nil
+	}
+
+	file, err := h.tg.GetFile(tgbotapi.FileConfig{FileID: meta.ID})
+	if err != nil {
+		return models.MetaPair{}, e.Wrap(fn, err)
+	}
 
-	h.log.Debug(fn, slog.String("type", mediaType), slog.String("file", fileName))
+	h.log.Debug(fn, slog.String("file", file.FilePath), slog.String("file unique id", meta.UniqueID))
 
 	tgUrl := file.Link(h.tg.Token)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tgUrl, nil)
 	if err != nil {
-		return "", e.Wrap(fn, err)
+		return models.MetaPair{}, e.Wrap(fn, err)
 	}
 
 	req.Close = true
@@ -669,34 +646,102 @@ func (h *Handler) loadMetaByTgID(fileID string, timeout time.Duration) (string,
 	}
 
 	if err != nil {
-		return "", e.Wrap(fn, err)
+		return models.MetaPair{}, e.Wrap(fn, err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", e.Wrap(fn, fmt.Errorf("unexpected status code: %d", resp.StatusCode))
+		return models.MetaPair{}, e.Wrap(fn, fmt.Errorf("unexpected status code: %d", resp.StatusCode))
 	}
 
-	pr, pw := io.Pipe()
-	defer pr.Close()
+	obj, err = h.md.Save(ctx, resp.Body, filepath.Ext(file.FilePath), meta.UniqueID)
+	if err != nil {
+		return models.MetaPair{}, e.Wrap(fn, err)
+	}
 
-	go func() {
-		defer pw.Close()
+	return media.MetaPair(obj, meta.Type), nil
+}
 
-		_, err := io.Copy(pw, resp.Body)
-		if err != nil {
-			pw.CloseWithError(e.Wrap(fn, err))
+// editMsg updates the stored text, the news and its outbox event are updated in the same transaction
+func (h *Handler) editMsg(ctx context.Context, msg *tgbotapi.Message) error {
+	const fn = "group.editMsg"
+
+	h.log.Info("[TG GROUP]",
+		slog.String("fn", fn),
+		slog.Any("ID", ctx.Value("ID")),
+		slog.String("chat", msg.Chat.Title),
+		slog.Int("message id", msg.MessageID),
+	)
+
+	text, entities := msg.Text, msg.Entities
+	if text == "" {
+		text, entities = msg.Caption, msg.CaptionEntities
+	}
+
+	ref := models.TgMessageRef{
+		Kind:      models.KindTgGroup,
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.MessageID,
+	}
+
+	if err := h.db.UpdateTgMessageText(ctx, ref, text, tgtext.FromTelegram(entities)); err != nil {
+		// The message was filtered out or the text did not change
+		if errors.Is(err, storage.ErrNoRecordsFound) {
+			return models.ErrSkipEvent
 		}
-	}()
 
-	ext := filepath.Ext(fileName)
-	contentType := mime.TypeByExtension(ext)
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+// insertMsgs stores messages as pending and sends them to moderators when the group is moderated
+func (h *Handler) insertMsgs(ctx context.Context, source string, msgs []models.TgGroupMessage) error {
+	const fn = "group.insertMsgs"
 
-	fileUrl, err := h.fdb.SaveFile(ctx, mediaBucket, fileName, pr, files.PutObjectOptions{ContentType: contentType})
+	moderated, err := h.db.TgGroupIsModerated(ctx, msgs[0].GroupID)
 	if err != nil {
-		return "", e.Wrap(fn, err)
+		return e.Wrap(fn, err)
+	}
+
+	if moderated {
+		for i := range msgs {
+			msgs[i].Status = models.MsgStatusPending
+		}
 	}
 
-	return fileUrl, nil
+	if err := h.db.InsertTgGroupMessages(ctx, msgs); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if !moderated {
+		return nil
+	}
+
+	for _, msg := range msgs {
+		p := moderation.Preview{
+			Ref: models.TgMessageRef{
+				Kind:      models.KindTgGroup,
+				ChatID:    msg.GroupID,
+				MessageID: msg.MessageID,
+			},
+			Source:     source,
+			Text:       msg.Text,
+			MediaCount: len(msg.Metadata),
+		}
+
+		if err := h.mod.Submit(ctx, p); err != nil {
+			return e.Wrap(fn, err)
+		}
+	}
+
+	return nil
+}
+
+func (h *Handler) isNewsMessage(msg *tgbotapi.Message, text string) bool {
+	hasMedia := msg.Photo != nil || msg.Video != nil || msg.Audio != nil || msg.Document != nil
+
+	return h.flt.Match(models.KindTgGroup, strconv.FormatInt(msg.Chat.ID, 10), text, hasMedia)
 }
 
 func defineRole(role string, roles ...string) bool {
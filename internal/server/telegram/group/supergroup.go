@@ -40,6 +40,9 @@ func (h *Handler) SupergroupCmd(ctx context.Context, update *tgbotapi.Update) er
 	}
 
 	switch {
+	case update.EditedMessage != nil:
+		return h.editMsg(ctx, update.EditedMessage)
+
 	case update.MyChatMember != nil && update.MyChatMember.NewChatMember.Status == "left":
 		return h.leaveChat(ctx, update.MyChatMember)
 
@@ -0,0 +1,240 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log/slog"
+	"project/internal/models"
+	"project/internal/pkg/logger/sl"
+	"project/internal/pkg/tgtext"
+	"project/internal/storage"
+	"project/pkg/e"
+	"strconv"
+	"strings"
+)
+
+const (
+	msgPreview = `Новость на модерации
+Источник: %s
+Медиа: %d
+
+%s`
+
+	msgApproved         = `✅ Одобрено`
+	msgRejected         = `❌ Отклонено`
+	msgAlreadyModerated = `Новость уже обработана`
+	msgSendNewText      = `Отправьте новый текст новости`
+
+	btnApprove = `Одобрить`
+	btnReject  = `Отклонить`
+	btnEdit    = `Изменить`
+)
+
+// Submit sends the preview with Approve/Reject/Edit buttons to every user
+func (m *Moderator) Submit(ctx context.Context, p Preview) error {
+	const fn = "moderation.Submit"
+
+	ids, err := m.db.GetModerators(ctx)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	for _, id := range ids {
+		if err := m.sendPreview(id, p); err != nil {
+			m.log.Error(fn, sl.Err(err), slog.Int64("user id", id))
+		}
+	}
+
+	return nil
+}
+
+// Callback handles a button press on a preview
+func (m *Moderator) Callback(ctx context.Context, cq *tgbotapi.CallbackQuery) error {
+	const fn = "moderation.Callback"
+
+	action, ref, err := parseCallbackData(cq.Data)
+	if err != nil {
+		return e.Wrap(fn, models.ErrSkipEvent)
+	}
+
+	m.log.Info("[MODERATION]",
+		slog.String("fn", fn),
+		slog.Any("ID", ctx.Value("ID")),
+		slog.String("username", cq.From.UserName),
+		slog.String("action", action),
+		slog.String("kind", ref.Kind),
+		slog.Int64("chat id", ref.ChatID),
+		slog.Int("message id", ref.MessageID),
+	)
+
+	if action == actionEdit {
+		st := editState{ref: ref}
+
+		// Keeps the source and media lines for the updated preview
+		if cq.Message != nil {
+			st.header, _, _ = strings.Cut(cq.Message.Text, "\n\n")
+		}
+
+		m.ac.SetToMap(editMap, strconv.FormatInt(cq.From.ID, 10), st, editTTL)
+
+		if err := m.answer(cq, ""); err != nil {
+			return e.Wrap(fn, err)
+		}
+
+		if _, err := m.tg.Send(tgbotapi.NewMessage(cq.From.ID, msgSendNewText)); err != nil {
+			return e.Wrap(fn, err)
+		}
+
+		return nil
+	}
+
+	status, result := models.MsgStatusApproved, msgApproved
+	if action == actionReject {
+		status, result = models.MsgStatusRejected, msgRejected
+	}
+
+	if err := m.db.SetTgMessageStatus(ctx, ref, status); err != nil {
+		if errors.Is(err, storage.ErrNoRecordsFound) {
+			result = msgAlreadyModerated
+		} else {
+			return e.Wrap(fn, err)
+		}
+	}
+
+	if err := m.answer(cq, result); err != nil {
+		m.log.Error(fn, sl.Err(err))
+	}
+
+	// Drops the buttons from the preview
+	if cq.Message != nil {
+		edit := tgbotapi.NewEditMessageText(cq.Message.Chat.ID, cq.Message.MessageID, cq.Message.Text+"\n\n"+result)
+
+		if _, err := m.tg.Send(edit); err != nil {
+			m.log.Error(fn, sl.Err(err))
+		}
+	}
+
+	return nil
+}
+
+// EditPending reports whether the user pressed Edit and the bot waits for the new text
+func (m *Moderator) EditPending(userID int64) bool {
+	_, ok := m.ac.GetFromMap(editMap, strconv.FormatInt(userID, 10))
+
+	return ok
+}
+
+// ApplyEdit replaces the text of the pending post with the message text
+// and sends the updated preview back to the editor
+func (m *Moderator) ApplyEdit(ctx context.Context, msg *tgbotapi.Message) error {
+	const fn = "moderation.ApplyEdit"
+
+	key := strconv.FormatInt(msg.From.ID, 10)
+
+	v, ok := m.ac.GetFromMap(editMap, key)
+	if !ok {
+		return models.ErrSkipEvent
+	}
+
+	m.ac.DeleteFromMap(editMap, key)
+
+	st := v.(editState)
+
+	text, entities := msg.Text, msg.Entities
+	if text == "" {
+		text, entities = msg.Caption, msg.CaptionEntities
+	}
+
+	if err := m.db.UpdatePendingTgMessageText(ctx, st.ref, text, tgtext.FromTelegram(entities)); err != nil {
+		if errors.Is(err, storage.ErrNoRecordsFound) {
+			if _, err := m.tg.Send(tgbotapi.NewMessage(msg.Chat.ID, msgAlreadyModerated)); err != nil {
+				m.log.Error(fn, sl.Err(err))
+			}
+
+			return nil
+		}
+
+		return e.Wrap(fn, err)
+	}
+
+	if err := m.send(msg.Chat.ID, st.ref, st.header+"\n\n"+truncate(text)); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+func (m *Moderator) sendPreview(chatID int64, p Preview) error {
+	return m.send(chatID, p.Ref, fmt.Sprintf(msgPreview, p.Source, p.MediaCount, truncate(p.Text)))
+}
+
+func (m *Moderator) send(chatID int64, ref models.TgMessageRef, text string) error {
+	msg := tgbotapi.NewMessage(chatID, text)
+
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(btnApprove, callbackData(actionApprove, ref)),
+			tgbotapi.NewInlineKeyboardButtonData(btnReject, callbackData(actionReject, ref)),
+			tgbotapi.NewInlineKeyboardButtonData(btnEdit, callbackData(actionEdit, ref)),
+		),
+	)
+
+	_, err := m.tg.Send(msg)
+
+	return err
+}
+
+func truncate(text string) string {
+	if r := []rune(text); len(r) > previewMaxLen {
+		return string(r[:previewMaxLen]) + "…"
+	}
+
+	return text
+}
+
+func (m *Moderator) answer(cq *tgbotapi.CallbackQuery, text string) error {
+	_, err := m.tg.Request(tgbotapi.NewCallback(cq.ID, text))
+
+	return err
+}
+
+// callbackData "mod:<action>:<kind>:<chat id>:<message id>", telegram allows up to 64 bytes
+func callbackData(action string, ref models.TgMessageRef) string {
+	return fmt.Sprintf("%s:%s:%s:%d:%d", callbackPrefix, action, ref.Kind, ref.ChatID, ref.MessageID)
+}
+
+// IsCallback reports whether the callback data was produced by the moderator
+func IsCallback(data string) bool {
+	return strings.HasPrefix(data, callbackPrefix+":")
+}
+
+func parseCallbackData(data string) (string, models.TgMessageRef, error) {
+	parts := strings.Split(data, ":")
+	if len(parts) != 5 || parts[0] != callbackPrefix {
+		return "", models.TgMessageRef{}, ErrBadCallback
+	}
+
+	switch parts[1] {
+	case actionApprove, actionReject, actionEdit:
+	default:
+		return "", models.TgMessageRef{}, ErrBadCallback
+	}
+
+	if parts[2] != models.KindTgGroup && parts[2] != models.KindTgChannel {
+		return "", models.TgMessageRef{}, ErrBadCallback
+	}
+
+	chatID, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		return "", models.TgMessageRef{}, ErrBadCallback
+	}
+
+	msgID, err := strconv.Atoi(parts[4])
+	if err != nil {
+		return "", models.TgMessageRef{}, ErrBadCallback
+	}
+
+	return parts[1], models.TgMessageRef{Kind: parts[2], ChatID: chatID, MessageID: msgID}, nil
+}
@@ -0,0 +1,72 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"project/internal/clients/tg_bot"
+	"project/internal/models"
+	"time"
+)
+
+const (
+	editMap = models.ModerationEditMapName
+
+	// editTTL how long the bot waits for the new text after Edit is pressed
+	editTTL = 30 * time.Minute
+
+	callbackPrefix = "mod"
+
+	actionApprove = "a"
+	actionReject  = "r"
+	actionEdit    = "e"
+
+	previewMaxLen = 3000
+)
+
+// Moderator sends pending Telegram posts to users and handles their decisions
+type Moderator struct {
+	tg  *tg_bot.Client
+	db  Storage
+	ac  AppCache
+	log *slog.Logger
+}
+
+type Storage interface {
+	GetModerators(ctx context.Context) ([]int64, error)
+	SetTgMessageStatus(ctx context.Context, ref models.TgMessageRef, status string) error
+	UpdatePendingTgMessageText(ctx context.Context, ref models.TgMessageRef, text string, entities []models.Entity) error
+}
+
+type AppCache interface {
+	SetToMap(name, key string, value any, TTL time.Duration) bool
+	GetFromMap(name string, key string) (any, bool)
+	DeleteFromMap(name, key string)
+}
+
+// Preview of a pending post
+type Preview struct {
+	Ref        models.TgMessageRef
+	Source     string
+	Text       string
+	MediaCount int
+}
+
+// editState is stored while the bot waits for the new text
+type editState struct {
+	ref    models.TgMessageRef
+	header string
+}
+
+var (
+	ErrBadCallback = errors.New("bad moderation callback data")
+)
+
+func New(tg *tg_bot.Client, db Storage, ac AppCache, log *slog.Logger) *Moderator {
+	return &Moderator{
+		tg:  tg,
+		db:  db,
+		ac:  ac,
+		log: log,
+	}
+}
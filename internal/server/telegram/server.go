@@ -228,6 +228,8 @@ func fromUser(u *tgbotapi.Update) *tgbotapi.User {
 	switch {
 	case u.Message != nil:
 		return u.Message.From
+	case u.CallbackQuery != nil:
+		return u.CallbackQuery.From
 	case u.MyChatMember != nil:
 		return &u.MyChatMember.From
 	default:
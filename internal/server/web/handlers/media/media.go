@@ -0,0 +1,205 @@
+package media
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"project/internal/files"
+	"project/internal/models"
+	"project/internal/pkg/logger/sl"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Links Returns the metadata with links instead of object keys, presigned links expire after the ttl.
+// Urls of the media bucket stored before the private mode are treated as keys
+func (h *Handler) Links(ctx context.Context, metadata []models.MetaPair) []models.MetaPair {
+	return h.withLinks(metadata, func(key string) string {
+		return h.link(ctx, key)
+	})
+}
+
+// PermanentLinks Returns the metadata with long-lived links, for feed readers that fetch media later.
+// The private bucket files are proxied through baseURL, the links expire after feed_link_ttl
+func (h *Handler) PermanentLinks(baseURL string, metadata []models.MetaPair) []models.MetaPair {
+	return h.withLinks(metadata, func(key string) string {
+		if !h.private {
+			return h.link(context.Background(), key)
+		}
+
+		return h.proxyLink(strings.TrimSuffix(baseURL, "/"), key, h.feedTTL)
+	})
+}
+
+// withLinks copies the metadata, the stored messages are shared with other requests
+func (h *Handler) withLinks(metadata []models.MetaPair, link func(key string) string) []models.MetaPair {
+	if metadata == nil {
+		return nil
+	}
+
+	res := make([]models.MetaPair, 0, len(metadata))
+
+	for _, meta := range metadata {
+		if key := h.key(meta.Url, meta.Key); key != "" {
+			meta.Url, meta.Key = link(key), ""
+		}
+
+		if meta.Sizes != nil {
+			sizes := make([]models.MediaSize, 0, len(meta.Sizes))
+
+			for _, size := range meta.Sizes {
+				if key := h.key(size.Url, size.Key); key != "" {
+					size.Url, size.Key = link(key), ""
+				}
+
+				sizes = append(sizes, size)
+			}
+
+			meta.Sizes = sizes
+		}
+
+		res = append(res, meta)
+	}
+
+	return res
+}
+
+// key returns the object key a link has to be made for, empty for urls that are left as is
+func (h *Handler) key(url, key string) string {
+	if key != "" || !h.private {
+		return key
+	}
+
+	name, _ := h.fdb.ObjectName(models.MediaBucket, url)
+
+	return name
+}
+
+func (h *Handler) link(ctx context.Context, key string) string {
+	const fn = "media.link"
+
+	if !h.private {
+		u, err := h.fdb.GetFileUrl(models.MediaBucket, key)
+		if err != nil {
+			h.log.Error(fn, sl.Err(err), slog.String("key", key))
+		}
+
+		return u
+	}
+
+	if h.links == LinksProxy {
+		return h.proxyLink(h.publicURL, key, h.ttl)
+	}
+
+	u, err := h.fdb.PresignedFileUrl(ctx, models.MediaBucket, key, h.ttl)
+	if err != nil {
+		// localfs and memory drivers can not sign links
+		if !errors.Is(err, files.ErrPresignNotSupported) {
+			h.log.Error(fn, sl.Err(err), slog.String("key", key))
+		}
+
+		return h.proxyLink(h.publicURL, key, h.ttl)
+	}
+
+	return u
+}
+
+// proxyLink signs the key with the expiry time. The time is rounded up to a quarter of the ttl,
+// so the same file keeps its link for a while and stays in the browser cache
+func (h *Handler) proxyLink(base, key string, ttl time.Duration) string {
+	step := ttl / 4
+	exp := strconv.FormatInt(time.Now().Add(ttl).Truncate(step).Add(step).Unix(), 10)
+
+	q := url.Values{}
+	q.Set("exp", exp)
+	q.Set("sig", h.sign(key, exp))
+
+	return base + proxyPath + key + "?" + q.Encode()
+}
+
+func (h *Handler) sign(key, exp string) string {
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write([]byte(key + "\n" + exp))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validLink checks the signature and expiry time of a proxy link
+func (h *Handler) validLink(key string, q url.Values) bool {
+	exp, sig := q.Get("exp"), q.Get("sig")
+
+	expAt, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || time.Now().Unix() > expAt {
+		return false
+	}
+
+	return hmac.Equal([]byte(sig), []byte(h.sign(key, exp)))
+}
+
+// Serve GET /media/{key...}?exp=&sig= streams a media file, Range requests are supported.
+// Files of the private bucket need a signed link that has not expired
+func (h *Handler) Serve(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+
+	if h.private && !h.validLink(key, r.URL.Query()) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	h.serve(w, r, key)
+}
+
+// ServeFiles GET /files/{bucket}/{name...} serves the links of the localfs and memory drivers,
+// only the media bucket is public and only when it is not private
+func (h *Handler) ServeFiles(w http.ResponseWriter, r *http.Request) {
+	if h.private || r.PathValue("bucket") != models.MediaBucket {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.serve(w, r, r.PathValue("name"))
+}
+
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request, key string) {
+	const fn = "[HTTP SERVER] media.serve"
+
+	if key == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	object, info, err := h.fdb.OpenFile(r.Context(), models.MediaBucket, key)
+	if err != nil {
+		if errors.Is(err, files.ErrFileNotFound) || errors.Is(err, files.ErrBucketNotFound) || errors.Is(err, files.ErrBadFileName) {
+			http.NotFound(w, r)
+			return
+		}
+
+		h.log.Error(fn, sl.Err(err), slog.String("key", key))
+
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	defer object.Close()
+
+	if info.ContentType != "" {
+		w.Header().Set("Content-Type", info.ContentType)
+	}
+
+	if info.ETag != "" {
+		w.Header().Set("ETag", strconv.Quote(info.ETag))
+	}
+
+	// Objects are named by their content and never change
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+
+	// ServeContent answers Range, If-Range and If-None-Match
+	http.ServeContent(w, r, key, info.ModTime, object)
+}
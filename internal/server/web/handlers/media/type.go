@@ -0,0 +1,87 @@
+package media
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"log/slog"
+	"project/internal/config"
+	"project/internal/files"
+	"project/pkg/e"
+	"strings"
+	"time"
+)
+
+const (
+	LinksPresign = "presign"
+	LinksProxy   = "proxy"
+
+	defaultPresignTTL  = 24 * time.Hour
+	defaultFeedLinkTTL = 30 * 24 * time.Hour
+
+	// proxyPath is the route of the proxied files, GET /media/{key...}
+	proxyPath = "/media/"
+)
+
+// Handler makes links to stored media and proxies files of the private bucket
+type Handler struct {
+	fdb     Files
+	private bool
+	links   string
+	ttl     time.Duration
+	// feedTTL is the lifetime of the proxy links in the feeds
+	feedTTL time.Duration
+	// secret signs the proxy links
+	secret []byte
+	// publicURL is the base of proxy links, they are relative when it is empty
+	publicURL string
+	log       *slog.Logger
+}
+
+type Files interface {
+	GetFileUrl(bucketName, fileName string) (string, error)
+	PresignedFileUrl(ctx context.Context, bucketName, fileName string, ttl time.Duration) (string, error)
+	OpenFile(ctx context.Context, bucketName, fileName string) (io.ReadSeekCloser, files.ObjectInfo, error)
+	ObjectName(bucketName, fileUrl string) (string, bool)
+}
+
+func New(fdb Files, cfg *config.Files, publicURL string, log *slog.Logger) (*Handler, error) {
+	const fn = "media.New"
+
+	h := &Handler{
+		fdb:       fdb,
+		private:   cfg.Private,
+		links:     cfg.MediaLinks,
+		ttl:       cfg.PresignTTL,
+		feedTTL:   cfg.FeedLinkTTL,
+		secret:    []byte(cfg.ProxySecret),
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		log:       log,
+	}
+
+	if h.links != LinksProxy {
+		h.links = LinksPresign
+	}
+
+	if h.ttl <= 0 {
+		h.ttl = defaultPresignTTL
+	}
+
+	if h.feedTTL <= 0 {
+		h.feedTTL = defaultFeedLinkTTL
+	}
+
+	if len(h.secret) == 0 {
+		h.secret = make([]byte, 32)
+
+		if _, err := rand.Read(h.secret); err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		if h.private {
+			log.Warn("file_storage.proxy_secret is empty, /media links stop working after restart")
+		}
+	}
+
+	return h, nil
+}
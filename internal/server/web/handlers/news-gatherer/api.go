@@ -0,0 +1,171 @@
+package news_gatherer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"project/internal/models"
+	"project/internal/pkg/logger/sl"
+	"project/internal/storage"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	apiDefaultLimit = 20
+	apiMaxLimit     = 100
+
+	apiTimeout = 10 * time.Second
+)
+
+type newsListResp struct {
+	Items      []webMessageReq `json:"items"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+type errorResp struct {
+	Error string `json:"error"`
+}
+
+var (
+	ErrBadLimit  = errors.New("bad limit")
+	ErrBadCursor = errors.New("bad cursor")
+	ErrBadTime   = errors.New("bad time range, expected RFC3339")
+	ErrBadID     = errors.New("bad id")
+	ErrNotFound  = errors.New("not found")
+	ErrInternal  = errors.New("internal server error")
+)
+
+// NewsList GET /api/v1/news?limit=&cursor=&type=&source=&from=&to=
+// cursor is the next_cursor value of the previous page
+func NewsList(db Storage, md Media, log *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const fn = "[HTTP SERVER] news-gatherer.NewsList"
+
+		filter, err := parseNewsFilter(r.URL.Query())
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResp{err.Error()}, log)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), apiTimeout)
+		defer cancel()
+
+		msgs, err := db.GetWebMessages(ctx, filter)
+		if err != nil && !errors.Is(err, storage.ErrNoRecordsFound) {
+			log.Error(fn, sl.Err(err))
+
+			writeJSON(w, http.StatusInternalServerError, errorResp{ErrInternal.Error()}, log)
+			return
+		}
+
+		resp := newsListResp{
+			Items: make([]webMessageReq, 0, len(msgs)),
+		}
+
+		for _, msg := range msgs {
+			resp.Items = append(resp.Items, toWebMessageReq(ctx, md, msg, false))
+		}
+
+		if len(msgs) == filter.Limit {
+			resp.NextCursor = strconv.FormatInt(msgs[len(msgs)-1].ID, 10)
+		}
+
+		writeJSON(w, http.StatusOK, resp, log)
+	}
+}
+
+// NewsByID GET /api/v1/news/{id}
+func NewsByID(db Storage, md Media, log *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const fn = "[HTTP SERVER] news-gatherer.NewsByID"
+
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil || id <= 0 {
+			writeJSON(w, http.StatusBadRequest, errorResp{ErrBadID.Error()}, log)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), apiTimeout)
+		defer cancel()
+
+		msg, err := db.GetWebMessage(ctx, id)
+		if err != nil {
+			if errors.Is(err, storage.ErrNoRecordsFound) {
+				writeJSON(w, http.StatusNotFound, errorResp{ErrNotFound.Error()}, log)
+				return
+			}
+
+			log.Error(fn, sl.Err(err))
+
+			writeJSON(w, http.StatusInternalServerError, errorResp{ErrInternal.Error()}, log)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, toWebMessageReq(ctx, md, msg, false), log)
+	}
+}
+
+func parseNewsFilter(q url.Values) (models.WebMessageFilter, error) {
+	filter := models.WebMessageFilter{
+		Limit:     apiDefaultLimit,
+		GroupName: q.Get("source"),
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return models.WebMessageFilter{}, ErrBadLimit
+		}
+
+		filter.Limit = min(limit, apiMaxLimit)
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		cursor, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || cursor <= 0 {
+			return models.WebMessageFilter{}, ErrBadCursor
+		}
+
+		filter.BeforeID = cursor
+	}
+
+	if v := q.Get("type"); v != "" {
+		filter.Types = strings.Split(v, ",")
+	}
+
+	var err error
+
+	if filter.From, err = parseTime(q.Get("from")); err != nil {
+		return models.WebMessageFilter{}, ErrBadTime
+	}
+
+	if filter.To, err = parseTime(q.Get("to")); err != nil {
+		return models.WebMessageFilter{}, ErrBadTime
+	}
+
+	return filter, nil
+}
+
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, v)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any, log *slog.Logger) {
+	const fn = "[HTTP SERVER] news-gatherer.writeJSON"
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error(fn, sl.Err(err))
+	}
+}
@@ -4,8 +4,6 @@ import (
 	"fmt"
 	"sync"
 	"sync/atomic"
-
-	"github.com/gorilla/websocket"
 )
 
 type Clients struct {
@@ -13,10 +11,15 @@ type Clients struct {
 	mu sync.RWMutex
 }
 
+// Conn is a live news transport, *websocket.Conn or *SSEConn
+type Conn interface {
+	WriteJSON(v any) error
+}
+
 type Client struct {
-	wsConn *websocket.Conn
-	offset int
-	mu     sync.RWMutex
+	conn Conn
+	// mu serializes writes, websocket.Conn supports only one concurrent writer
+	mu sync.Mutex
 }
 
 func New() *Clients {
@@ -27,15 +30,15 @@ func New() *Clients {
 
 var clientCounter atomic.Uint64
 
-func (cs *Clients) Add(ws *websocket.Conn) string {
+func (cs *Clients) Add(conn Conn, addr string) string {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
 	c := &Client{
-		wsConn: ws,
+		conn: conn,
 	}
 
-	id := fmt.Sprintf("%s:%d", c.wsConn.LocalAddr().String(), clientCounter.Add(1))
+	id := fmt.Sprintf("%s:%d", addr, clientCounter.Add(1))
 	cs.m[id] = c
 
 	return id
@@ -70,21 +73,12 @@ func (cs *Clients) Remove(id string) {
 }
 
 func (c *Client) SendMsg(msg any) error {
-	if err := c.wsConn.WriteJSON(msg); err != nil {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.conn.WriteJSON(msg); err != nil {
 		return err
 	}
 
 	return nil
 }
-
-func (c *Client) AddOffset(v int) {
-	c.mu.Lock()
-	c.offset += v
-	c.mu.Unlock()
-}
-
-func (c *Client) GetOffset() int {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return c.offset
-}
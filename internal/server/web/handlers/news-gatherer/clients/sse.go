@@ -0,0 +1,112 @@
+package clients
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// EventIDer is implemented by messages that carry a stable id,
+// it is sent as the SSE event id and comes back as Last-Event-ID, zero means no id
+type EventIDer interface {
+	EventID() int64
+}
+
+// SSEConn writes messages as text/event-stream events
+type SSEConn struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+	// mu serializes events with heartbeats and Close
+	mu sync.Mutex
+	// closed the handler returned, the ResponseWriter must not be used
+	closed bool
+}
+
+var (
+	ErrStreamingUnsupported = errors.New("streaming unsupported")
+	ErrConnClosed           = errors.New("sse connection closed")
+)
+
+// NewSSEConn writes the event stream headers, the server write timeout is
+// disabled for the connection since the stream is long-lived
+func NewSSEConn(w http.ResponseWriter) (*SSEConn, error) {
+	rc := http.NewResponseController(w)
+
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return nil, err
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Disables response buffering in nginx
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if err := rc.Flush(); err != nil {
+		if errors.Is(err, http.ErrNotSupported) {
+			return nil, ErrStreamingUnsupported
+		}
+
+		return nil, err
+	}
+
+	return &SSEConn{
+		w:  w,
+		rc: rc,
+	}, nil
+}
+
+// WriteJSON sends v as one event
+func (s *SSEConn) WriteJSON(v any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrConnClosed
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if id, ok := v.(EventIDer); ok && id.EventID() > 0 {
+		if _, err := fmt.Fprintf(s.w, "id: %d\n", id.EventID()); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintf(s.w, "data: %s\n\n", data); err != nil {
+		return err
+	}
+
+	return s.rc.Flush()
+}
+
+// Heartbeat sends a comment line so proxies do not close an idle stream
+func (s *SSEConn) Heartbeat() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrConnClosed
+	}
+
+	if _, err := fmt.Fprint(s.w, ": ping\n\n"); err != nil {
+		return err
+	}
+
+	return s.rc.Flush()
+}
+
+// Close waits for a write in progress, the handler calls it before it returns
+func (s *SSEConn) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+}
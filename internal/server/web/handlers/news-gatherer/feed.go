@@ -0,0 +1,198 @@
+package news_gatherer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"project/internal/models"
+	"project/internal/pkg/logger/sl"
+	"project/internal/storage"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	FeedRSS  = "rss"
+	FeedAtom = "atom"
+	FeedJSON = "json"
+
+	feedTitleMaxLen = 100
+)
+
+// FeedInfo describes the outbound feed itself
+type FeedInfo struct {
+	Title string
+	// PublicURL is used for links, the request host is used when empty
+	PublicURL string
+}
+
+type feedRenderer func(info FeedInfo, selfURL string, msgs []models.WebMessage) ([]byte, string, error)
+
+var feedRenderers = map[string]feedRenderer{
+	FeedRSS:  renderRSS,
+	FeedAtom: renderAtom,
+	FeedJSON: renderJSONFeed,
+}
+
+// NewsFeed GET /feed.rss, /feed.atom, /feed.json
+// Accepts the same type, source and limit params as NewsList
+func NewsFeed(format string, info FeedInfo, db Storage, md Media, log *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	render := feedRenderers[format]
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const fn = "[HTTP SERVER] news-gatherer.NewsFeed"
+
+		filter, err := parseNewsFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), apiTimeout)
+		defer cancel()
+
+		msgs, err := db.GetWebMessages(ctx, filter)
+		if err != nil && !errors.Is(err, storage.ErrNoRecordsFound) {
+			log.Error(fn, sl.Err(err))
+
+			http.Error(w, ErrInternal.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		deletedAt, err := db.GetFeedDeletedAt(ctx)
+		if err != nil {
+			log.Error(fn, sl.Err(err))
+
+			http.Error(w, ErrInternal.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if info.PublicURL == "" {
+			info.PublicURL = requestBaseURL(r)
+		}
+
+		// Feed readers fetch enclosures long after the feed, presigned links would expire
+		for i := range msgs {
+			msgs[i].Metadata = md.PermanentLinks(info.PublicURL, msgs[i].Metadata)
+		}
+
+		body, contentType, err := render(info, info.PublicURL+r.URL.RequestURI(), msgs)
+		if err != nil {
+			log.Error(fn, sl.Err(err))
+
+			http.Error(w, ErrInternal.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		sum := sha256.Sum256(body)
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+
+		// ServeContent answers If-None-Match and If-Modified-Since with 304
+		http.ServeContent(w, r, "", feedModified(msgs, deletedAt), bytes.NewReader(body))
+	}
+}
+
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+
+	return scheme + "://" + r.Host
+}
+
+// feedUpdated msgs are ordered newest first
+func feedUpdated(msgs []models.WebMessage) time.Time {
+	if len(msgs) == 0 {
+		return time.Time{}
+	}
+
+	return msgs[0].CreatedAt
+}
+
+// feedModified is the newest change of the feed: an added or edited news or a deletion.
+// Deletions are not tracked per filter, any of them changes every feed
+func feedModified(msgs []models.WebMessage, deletedAt time.Time) time.Time {
+	modified := deletedAt
+
+	for _, msg := range msgs {
+		if msg.CreatedAt.After(modified) {
+			modified = msg.CreatedAt
+		}
+
+		if msg.UpdatedAt.After(modified) {
+			modified = msg.UpdatedAt
+		}
+	}
+
+	return modified
+}
+
+func entryTitle(msg models.WebMessage) string {
+	if msg.Title != "" {
+		return msg.Title
+	}
+
+	title, _, _ := strings.Cut(strings.TrimSpace(msg.Text), "\n")
+
+	if utf8.RuneCountInString(title) > feedTitleMaxLen {
+		title = string([]rune(title)[:feedTitleMaxLen]) + "…"
+	}
+
+	if title == "" {
+		title = msg.GroupName
+	}
+
+	return title
+}
+
+func entryURL(info FeedInfo, msg models.WebMessage) string {
+	if msg.Link != "" {
+		return msg.Link
+	}
+
+	return info.PublicURL + "/api/v1/news/" + strconv.FormatInt(msg.ID, 10)
+}
+
+func entryID(info FeedInfo, msg models.WebMessage) string {
+	return info.PublicURL + "/api/v1/news/" + strconv.FormatInt(msg.ID, 10)
+}
+
+// mediaType returns the MIME type of a media attachment, iframes and link cards are not attachments
+func mediaType(pair models.MetaPair) (string, bool) {
+	if pair.Type == models.MsgIframe || pair.Type == models.MsgLink {
+		return "", false
+	}
+
+	if t := mime.TypeByExtension(path.Ext(strings.SplitN(pair.Url, "?", 2)[0])); t != "" {
+		return t, true
+	}
+
+	switch pair.Type {
+	case models.MsgPhoto:
+		return "image/jpeg", true
+
+	case models.MsgVideo:
+		return "video/mp4", true
+
+	case models.MsgAudio:
+		return "audio/mpeg", true
+
+	default:
+		return "application/octet-stream", true
+	}
+}
@@ -0,0 +1,261 @@
+package news_gatherer
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"project/internal/models"
+	"strconv"
+	"time"
+)
+
+type rssDoc struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	MediaNS string     `xml:"xmlns:media,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string         `xml:"title"`
+	Link        string         `xml:"link"`
+	Description string         `xml:"description"`
+	Author      string         `xml:"author,omitempty"`
+	Category    string         `xml:"category,omitempty"`
+	GUID        rssGUID        `xml:"guid"`
+	PubDate     string         `xml:"pubDate"`
+	Enclosure   *rssEnclosure  `xml:"enclosure,omitempty"`
+	Media       []mediaContent `xml:"media:content"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length string `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type mediaContent struct {
+	URL  string `xml:"url,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title     string       `xml:"title"`
+	ID        string       `xml:"id"`
+	Updated   string       `xml:"updated"`
+	Published string       `xml:"published"`
+	Author    atomAuthor   `xml:"author"`
+	Category  atomCategory `xml:"category"`
+	Links     []atomLink   `xml:"link"`
+	Content   atomContent  `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// https://www.jsonfeed.org/version/1.1/
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string               `json:"id"`
+	URL           string               `json:"url"`
+	Title         string               `json:"title"`
+	ContentText   string               `json:"content_text"`
+	Image         string               `json:"image,omitempty"`
+	DatePublished string               `json:"date_published"`
+	Authors       []jsonFeedAuthor     `json:"authors"`
+	Tags          []string             `json:"tags"`
+	Attachments   []jsonFeedAttachment `json:"attachments,omitempty"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedAttachment struct {
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type"`
+}
+
+func renderRSS(info FeedInfo, selfURL string, msgs []models.WebMessage) ([]byte, string, error) {
+	doc := rssDoc{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		MediaNS: "http://search.yahoo.com/mrss/",
+		Channel: rssChannel{
+			Title:       info.Title,
+			Link:        info.PublicURL,
+			Description: info.Title,
+			AtomLink:    atomLink{Href: selfURL, Rel: "self", Type: "application/rss+xml"},
+		},
+	}
+
+	if updated := feedUpdated(msgs); !updated.IsZero() {
+		doc.Channel.LastBuildDate = updated.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, msg := range msgs {
+		item := rssItem{
+			Title:       entryTitle(msg),
+			Link:        entryURL(info, msg),
+			Description: msg.Text,
+			Category:    msg.Type,
+			GUID:        rssGUID{Value: entryID(info, msg)},
+			PubDate:     msg.CreatedAt.UTC().Format(time.RFC1123Z),
+		}
+
+		for _, pair := range msg.Metadata {
+			t, ok := mediaType(pair)
+			if !ok {
+				continue
+			}
+
+			// RSS 2.0 allows a single enclosure, the rest goes to media:content
+			if item.Enclosure == nil {
+				item.Enclosure = &rssEnclosure{URL: pair.Url, Length: "0", Type: t}
+			}
+
+			item.Media = append(item.Media, mediaContent{URL: pair.Url, Type: t})
+		}
+
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, "", err
+	}
+
+	return append([]byte(xml.Header), body...), "application/rss+xml; charset=utf-8", nil
+}
+
+func renderAtom(info FeedInfo, selfURL string, msgs []models.WebMessage) ([]byte, string, error) {
+	feed := atomFeed{
+		Title:   info.Title,
+		ID:      selfURL,
+		Updated: feedUpdated(msgs).UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: selfURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: info.PublicURL, Rel: "alternate"},
+		},
+	}
+
+	for _, msg := range msgs {
+		entry := atomEntry{
+			Title:     entryTitle(msg),
+			ID:        entryID(info, msg),
+			Updated:   msg.CreatedAt.UTC().Format(time.RFC3339),
+			Published: msg.CreatedAt.UTC().Format(time.RFC3339),
+			Author:    atomAuthor{Name: msg.GroupName},
+			Category:  atomCategory{Term: msg.Type},
+			Links:     []atomLink{{Href: entryURL(info, msg), Rel: "alternate"}},
+			Content:   atomContent{Type: "text", Value: msg.Text},
+		}
+
+		for _, pair := range msg.Metadata {
+			if t, ok := mediaType(pair); ok {
+				entry.Links = append(entry.Links, atomLink{Href: pair.Url, Rel: "enclosure", Type: t})
+			} else {
+				entry.Links = append(entry.Links, atomLink{Href: pair.Url, Rel: "related"})
+			}
+		}
+
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, "", err
+	}
+
+	return append([]byte(xml.Header), body...), "application/atom+xml; charset=utf-8", nil
+}
+
+func renderJSONFeed(info FeedInfo, selfURL string, msgs []models.WebMessage) ([]byte, string, error) {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       info.Title,
+		HomePageURL: info.PublicURL,
+		FeedURL:     selfURL,
+		Items:       make([]jsonFeedItem, 0, len(msgs)),
+	}
+
+	for _, msg := range msgs {
+		item := jsonFeedItem{
+			ID:            strconv.FormatInt(msg.ID, 10),
+			URL:           entryURL(info, msg),
+			Title:         entryTitle(msg),
+			ContentText:   msg.Text,
+			DatePublished: msg.CreatedAt.UTC().Format(time.RFC3339),
+			Authors:       []jsonFeedAuthor{{Name: msg.GroupName}},
+			Tags:          []string{msg.Type},
+		}
+
+		for _, pair := range msg.Metadata {
+			t, ok := mediaType(pair)
+			if !ok {
+				continue
+			}
+
+			if item.Image == "" && pair.Type == models.MsgPhoto {
+				item.Image = pair.Url
+			}
+
+			item.Attachments = append(item.Attachments, jsonFeedAttachment{URL: pair.Url, MimeType: t})
+		}
+
+		feed.Items = append(feed.Items, item)
+	}
+
+	body, err := json.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, "", err
+	}
+
+	return body, "application/feed+json; charset=utf-8", nil
+}
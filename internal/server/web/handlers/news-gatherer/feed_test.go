@@ -0,0 +1,105 @@
+package news_gatherer
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"project/internal/models"
+	"testing"
+	"time"
+)
+
+type feedStorage struct {
+	msgs      []models.WebMessage
+	deletedAt time.Time
+}
+
+func (s *feedStorage) GetWebMessages(context.Context, models.WebMessageFilter) ([]models.WebMessage, error) {
+	return s.msgs, nil
+}
+
+func (s *feedStorage) GetWebMessage(context.Context, int64) (models.WebMessage, error) {
+	return models.WebMessage{}, nil
+}
+
+func (s *feedStorage) SearchWebMessages(context.Context, models.SearchQuery) ([]models.SearchHit, error) {
+	return nil, nil
+}
+
+func (s *feedStorage) GetFeedDeletedAt(context.Context) (time.Time, error) {
+	return s.deletedAt, nil
+}
+
+type feedMedia struct{}
+
+func (feedMedia) Links(_ context.Context, metadata []models.MetaPair) []models.MetaPair {
+	return metadata
+}
+
+func (feedMedia) PermanentLinks(_ string, metadata []models.MetaPair) []models.MetaPair {
+	return metadata
+}
+
+func TestNewsFeedConditional(t *testing.T) {
+	created := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+
+	db := &feedStorage{msgs: []models.WebMessage{
+		{ID: 2, GroupName: "g", Text: "new", CreatedAt: created, UpdatedAt: created},
+		{ID: 1, GroupName: "g", Text: "old", CreatedAt: created.Add(-time.Hour), UpdatedAt: created.Add(-time.Hour)},
+	}}
+
+	handler := NewsFeed(FeedRSS, FeedInfo{Title: "News"}, db, feedMedia{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	get := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/feed.rss", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		return rec
+	}
+
+	first := get("", "")
+
+	lastModified, etag := first.Header().Get("Last-Modified"), first.Header().Get("ETag")
+
+	if lastModified != created.Format(http.TimeFormat) || etag == "" {
+		t.Fatalf("Last-Modified, ETag = %q, %q, want %q and an ETag", lastModified, etag, created.Format(http.TimeFormat))
+	}
+
+	if rec := get("If-Modified-Since", lastModified); rec.Code != http.StatusNotModified {
+		t.Errorf("unchanged If-Modified-Since status = %d, want 304", rec.Code)
+	}
+
+	if rec := get("If-None-Match", etag); rec.Code != http.StatusNotModified {
+		t.Errorf("unchanged If-None-Match status = %d, want 304", rec.Code)
+	}
+
+	// An edit of an older news
+	db.msgs[1].Text = "edited"
+	db.msgs[1].UpdatedAt = created.Add(time.Minute)
+
+	if rec := get("If-Modified-Since", lastModified); rec.Code != http.StatusOK {
+		t.Errorf("edited If-Modified-Since status = %d, want 200", rec.Code)
+	}
+
+	lastModified = get("", "").Header().Get("Last-Modified")
+
+	// A deletion
+	db.msgs = db.msgs[:1]
+	db.deletedAt = created.Add(2 * time.Minute)
+
+	rec := get("If-Modified-Since", lastModified)
+	if rec.Code != http.StatusOK {
+		t.Errorf("deleted If-Modified-Since status = %d, want 200", rec.Code)
+	}
+
+	if got := rec.Header().Get("Last-Modified"); got != db.deletedAt.Format(http.TimeFormat) {
+		t.Errorf("Last-Modified after deletion = %q, want %q", got, db.deletedAt.Format(http.TimeFormat))
+	}
+}
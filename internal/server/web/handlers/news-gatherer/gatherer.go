@@ -2,96 +2,112 @@ package news_gatherer
 
 import (
 	"context"
-	"fmt"
-	"github.com/lib/pq"
+	"errors"
 	"log/slog"
-	"project/internal/models"
+	"project/internal/events"
 	"project/internal/pkg/logger/sl"
 	"project/internal/server/web/handlers/news-gatherer/clients"
-	"project/pkg/e"
-	"sync"
 )
 
-const (
-	tgChannelMsgNotify = "insert_tg_channel_message"
-	tgGroupMsgNotify   = "insert_tg_group_message"
-	vkGroupMsgNotify   = "insert_vk_message"
+// recentKeysSize redelivered events come within one outbox batch or lease
+const recentKeysSize = 1024
+
+var (
+	ErrUnknownEvent = errors.New("error unknown news event")
+	// errNotBroadcast the event was handled but live clients must not receive it
+	errNotBroadcast = errors.New("message is not broadcast")
 )
 
-func NewsReader(db Storage, clients *clients.Clients, log *slog.Logger) func() {
+// NewsReader broadcasts the feed events to the live clients
+func NewsReader(bus Events, md Media, clients *clients.Clients, log *slog.Logger) func() {
 	return func() {
 		const fn = "[HTTP SERVER] web-socket.Reader"
 
-		notifyCh, err := mergeNotify(db, 32, tgChannelMsgNotify, tgGroupMsgNotify, vkGroupMsgNotify)
-		if err != nil {
-			log.Error(fn, sl.Err(err))
-		}
+		eventsCh, _ := bus.Subscribe(32)
+		seen := newRecentKeys(recentKeysSize)
 
-		for n := range notifyCh {
-			webMsg, err := toWebMsg(n)
-			if err != nil {
-				log.Error(fn, sl.Err(err))
+		for ev := range eventsCh {
+			// Events are delivered at least once
+			if !seen.Add(ev.IdempotencyKey()) {
 				continue
 			}
 
-			go func() {
-				reqWebMsg := webMessageReq{
-					GroupName: webMsg.GroupName,
-					Text:      webMsg.Text,
-					Metadata:  webMsg.Metadata,
-					CreatedAt: webMsg.CreatedAt,
-					Type:      webMsg.Type,
-					New:       true,
+			req, err := newsEvent(md, ev)
+			if err != nil {
+				if !errors.Is(err, errNotBroadcast) {
+					log.Error(fn, sl.Err(err))
 				}
 
+				continue
+			}
+
+			go func() {
 				for _, c := range clients.GetAll() {
-					c.AddOffset(1)
-					if err := c.SendMsg(reqWebMsg); err != nil {
+					if err := c.SendMsg(req); err != nil {
 						log.Error(fn, sl.Err(err))
 					}
 				}
 			}()
-
-			if err := db.InsertWebMessages(context.TODO(), []models.WebMessage{webMsg}); err != nil {
-				log.Error(fn, sl.Err(err))
-			}
 		}
 	}
 }
 
-func mergeNotify(db Storage, buf uint, notifyNames ...string) (<-chan *pq.Notification, error) {
-	var in []<-chan *pq.Notification
-
-	for _, name := range notifyNames {
-		notifyCh, err := db.AddNotifier(context.TODO(), name, buf)
-		if err != nil {
-			return nil, e.Wrap(fmt.Sprintf("notifier: %s started error", name), err)
+// newsEvent makes the message for the clients, backfilled history was never live and is not broadcast
+func newsEvent(md Media, ev events.Event) (any, error) {
+	switch ev := ev.(type) {
+	case events.NewsPublished:
+		if ev.News.Backfilled {
+			return nil, errNotBroadcast
 		}
 
-		in = append(in, notifyCh)
-	}
+		req := toWebMessageReq(context.TODO(), md, ev.News, true)
+		req.Key = ev.Key
 
-	var wg sync.WaitGroup
+		return req, nil
 
-	out := make(chan *pq.Notification)
+	case events.NewsUpdated:
+		req := toWebMessageReq(context.TODO(), md, ev.News, false)
+		req.Event = eventUpdate
+		req.Key = ev.Key
 
-	output := func(c <-chan *pq.Notification) {
-		defer wg.Done()
-		for n := range c {
-			out <- n
-		}
+		return req, nil
+
+	case events.NewsDeleted:
+		return newsEventReq{Event: eventDelete, ID: ev.ID, Key: ev.Key}, nil
+
+	default:
+		return nil, ErrUnknownEvent
 	}
+}
 
-	wg.Add(len(in))
+// recentKeys remembers the last size keys
+type recentKeys struct {
+	keys map[string]struct{}
+	ring []string
+	next int
+}
+
+func newRecentKeys(size int) *recentKeys {
+	return &recentKeys{
+		keys: make(map[string]struct{}, size),
+		ring: make([]string, size),
+	}
+}
+
+// Add returns false for a key seen recently, empty keys are never remembered
+func (r *recentKeys) Add(key string) bool {
+	if key == "" {
+		return true
+	}
 
-	for _, c := range in {
-		go output(c)
+	if _, ok := r.keys[key]; ok {
+		return false
 	}
 
-	go func() {
-		wg.Wait()
-		close(out)
-	}()
+	delete(r.keys, r.ring[r.next])
+	r.ring[r.next] = key
+	r.keys[key] = struct{}{}
+	r.next = (r.next + 1) % len(r.ring)
 
-	return out, nil
+	return true
 }
@@ -0,0 +1,131 @@
+package news_gatherer
+
+import (
+	"context"
+	"errors"
+	"html"
+	"log/slog"
+	"net/http"
+	"project/internal/models"
+	"project/internal/pkg/logger/sl"
+	"project/internal/storage"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	searchMaxQueryLen = 256
+
+	// eventSearch is the ws response to the search action
+	eventSearch = "search"
+)
+
+// searchHitReq Snippet is HTML with the matched words in <mark>
+type searchHitReq struct {
+	webMessageReq
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type searchResp struct {
+	// Event is set in ws responses only
+	Event      string         `json:"event,omitempty"`
+	Query      string         `json:"query"`
+	Items      []searchHitReq `json:"items"`
+	NextOffset int            `json:"next_offset,omitempty"`
+}
+
+var (
+	ErrBadQuery  = errors.New("bad query")
+	ErrBadOffset = errors.New("bad offset")
+)
+
+// snippetReplacer escapes the snippet text and turns the storage markers into <mark>
+var snippetReplacer = strings.NewReplacer(
+	models.SnippetStart, "<mark>",
+	models.SnippetStop, "</mark>",
+)
+
+// NewsSearch GET /api/v1/search?q=&limit=&offset=
+func NewsSearch(db Storage, md Media, log *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const fn = "[HTTP SERVER] news-gatherer.NewsSearch"
+
+		query, err := parseSearchQuery(r.URL.Query().Get("q"), r.URL.Query().Get("limit"), r.URL.Query().Get("offset"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResp{err.Error()}, log)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), apiTimeout)
+		defer cancel()
+
+		resp, err := search(ctx, db, md, query)
+		if err != nil {
+			log.Error(fn, sl.Err(err))
+
+			writeJSON(w, http.StatusInternalServerError, errorResp{ErrInternal.Error()}, log)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, resp, log)
+	}
+}
+
+func search(ctx context.Context, db Storage, md Media, query models.SearchQuery) (searchResp, error) {
+	hits, err := db.SearchWebMessages(ctx, query)
+	if err != nil && !errors.Is(err, storage.ErrNoRecordsFound) {
+		return searchResp{}, err
+	}
+
+	resp := searchResp{
+		Query: query.Query,
+		Items: make([]searchHitReq, 0, len(hits)),
+	}
+
+	for _, hit := range hits {
+		resp.Items = append(resp.Items, searchHitReq{
+			webMessageReq: toWebMessageReq(ctx, md, hit.Message, false),
+			Rank:          hit.Rank,
+			Snippet:       snippetReplacer.Replace(html.EscapeString(hit.Snippet)),
+		})
+	}
+
+	if len(hits) == query.Limit {
+		resp.NextOffset = query.Offset + len(hits)
+	}
+
+	return resp, nil
+}
+
+func parseSearchQuery(q, limit, offset string) (models.SearchQuery, error) {
+	query := models.SearchQuery{
+		Query: strings.TrimSpace(q),
+		Limit: apiDefaultLimit,
+	}
+
+	if query.Query == "" || utf8.RuneCountInString(query.Query) > searchMaxQueryLen {
+		return models.SearchQuery{}, ErrBadQuery
+	}
+
+	if limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > apiMaxLimit {
+			return models.SearchQuery{}, ErrBadLimit
+		}
+
+		query.Limit = n
+	}
+
+	if offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return models.SearchQuery{}, ErrBadOffset
+		}
+
+		query.Offset = n
+	}
+
+	return query, nil
+}
@@ -0,0 +1,120 @@
+package news_gatherer
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"project/internal/models"
+	"project/internal/pkg/logger/sl"
+	"project/internal/server/web/handlers/news-gatherer/clients"
+	"project/internal/storage"
+	"strconv"
+	"time"
+)
+
+const sseHeartbeat = 30 * time.Second
+
+// NewsStream GET /sse streams the same new messages as the websocket.
+// A reconnecting EventSource sends Last-Event-ID and receives the missed messages first,
+// last_event_id query param does the same for clients that can not set headers
+func NewsStream(db Storage, md Media, cs *clients.Clients, log *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const fn = "[HTTP SERVER] news-gatherer.NewsStream"
+
+		lastID, err := lastEventID(r)
+		if err != nil {
+			http.Error(w, ErrBadID.Error(), http.StatusBadRequest)
+			return
+		}
+
+		conn, err := clients.NewSSEConn(w)
+		if err != nil {
+			log.Error(fn, sl.Err(err))
+
+			http.Error(w, ErrInternal.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Broadcasts may still hold the conn after Remove
+		defer conn.Close()
+
+		// Registered before the replay so nothing published meanwhile is lost,
+		// such messages may be delivered twice and clients dedupe by id
+		connID := cs.Add(conn, r.RemoteAddr)
+
+		log := log.With(slog.String("connID", connID))
+
+		log.Info("[HTTP SERVER] new sse client")
+
+		defer func() {
+			log.Info("[HTTP SERVER] sse client disconnected")
+
+			cs.Remove(connID)
+		}()
+
+		c, _ := cs.Get(connID)
+
+		if lastID > 0 {
+			if err := replay(r.Context(), db, md, c, lastID); err != nil {
+				log.Error(fn, sl.Err(err))
+				return
+			}
+		}
+
+		ticker := time.NewTicker(sseHeartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+
+			case <-ticker.C:
+				if err := conn.Heartbeat(); err != nil {
+					log.Debug(fn, sl.Err(err))
+					return
+				}
+			}
+		}
+	}
+}
+
+// replay sends messages stored after lastID in insertion order
+func replay(ctx context.Context, db Storage, md Media, c *clients.Client, lastID int64) error {
+	for {
+		msgs, err := db.GetWebMessages(ctx, models.WebMessageFilter{Limit: apiMaxLimit, AfterID: lastID})
+		if err != nil {
+			if errors.Is(err, storage.ErrNoRecordsFound) {
+				return nil
+			}
+
+			return err
+		}
+
+		for _, msg := range msgs {
+			if err := c.SendMsg(toWebMessageReq(ctx, md, msg, true)); err != nil {
+				return err
+			}
+
+			lastID = msg.ID
+		}
+
+		if len(msgs) < apiMaxLimit {
+			return nil
+		}
+	}
+}
+
+func lastEventID(r *http.Request) (int64, error) {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = r.URL.Query().Get("last_event_id")
+	}
+
+	if v == "" {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(v, 10, 64)
+}
@@ -2,22 +2,94 @@ package news_gatherer
 
 import (
 	"context"
-	"github.com/lib/pq"
+	"project/internal/events"
 	"project/internal/models"
+	"project/internal/pkg/tgtext"
 	"time"
 )
 
 type Storage interface {
-	InsertWebMessages(ctx context.Context, msgs []models.WebMessage) error
-	GetWebMessages(ctx context.Context, limit int, offset int) ([]models.WebMessage, error)
-	AddNotifier(ctx context.Context, name string, buf uint) (<-chan *pq.Notification, error)
+	GetWebMessages(ctx context.Context, filter models.WebMessageFilter) ([]models.WebMessage, error)
+	GetWebMessage(ctx context.Context, id int64) (models.WebMessage, error)
+	SearchWebMessages(ctx context.Context, query models.SearchQuery) ([]models.SearchHit, error)
+	GetFeedDeletedAt(ctx context.Context) (time.Time, error)
+}
+
+// Events delivers the feed changes published by the outbox relay
+type Events interface {
+	Subscribe(buf int) (<-chan events.Event, func())
+}
+
+// Media makes links to stored media files, the stored metadata may hold object keys instead of urls
+type Media interface {
+	Links(ctx context.Context, metadata []models.MetaPair) []models.MetaPair
+	PermanentLinks(baseURL string, metadata []models.MetaPair) []models.MetaPair
 }
 
 type webMessageReq struct {
-	GroupName string            `json:"group_name"`
-	Text      string            `json:"text"`
+	ID        int64  `json:"id"`
+	GroupName string `json:"group_name"`
+	Title     string `json:"title,omitempty"`
+	Link      string `json:"link,omitempty"`
+	Text      string `json:"text"`
+	// HTML and Markdown are the text with its formatting, HTML is safe to insert as is
+	HTML      string            `json:"html"`
+	Markdown  string            `json:"markdown"`
 	Metadata  []models.MetaPair `json:"metadata,omitempty"`
 	CreatedAt time.Time         `json:"created_at"`
 	Type      string            `json:"type"`
 	New       bool              `json:"new"`
+	// Event is empty for new and history messages
+	Event string `json:"event,omitempty"`
+	// Key is the idempotency key of a live event, a redelivered event has the same key
+	Key string `json:"key,omitempty"`
+}
+
+const (
+	// eventUpdate the item with this id was edited and should be replaced
+	eventUpdate = "update"
+	// eventDelete the item with this id was retracted
+	eventDelete = "delete"
+)
+
+type newsEventReq struct {
+	Event string `json:"event"`
+	ID    int64  `json:"id"`
+	Key   string `json:"key,omitempty"`
+}
+
+// wsReq {"action": "getMsg", "before_id": 10} pages back through history,
+// {"action": "getMsg", "after_id": 10} returns messages missed since id 10,
+// {"action": "search", "query": "...", "offset": 10} returns search results
+type wsReq struct {
+	Action   string `json:"action"`
+	BeforeID int64  `json:"before_id"`
+	AfterID  int64  `json:"after_id"`
+	Query    string `json:"query"`
+	Offset   int    `json:"offset"`
+}
+
+func toWebMessageReq(ctx context.Context, md Media, msg models.WebMessage, isNew bool) webMessageReq {
+	return webMessageReq{
+		ID:        msg.ID,
+		GroupName: msg.GroupName,
+		Title:     msg.Title,
+		Link:      msg.Link,
+		Text:      msg.Text,
+		HTML:      tgtext.HTML(msg.Text, msg.Entities),
+		Markdown:  tgtext.Markdown(msg.Text, msg.Entities),
+		Metadata:  md.Links(ctx, msg.Metadata),
+		CreatedAt: msg.CreatedAt,
+		Type:      msg.Type,
+		New:       isNew,
+	}
+}
+
+// EventID only new messages move the SSE Last-Event-ID forward
+func (m webMessageReq) EventID() int64 {
+	if !m.New {
+		return 0
+	}
+
+	return m.ID
 }
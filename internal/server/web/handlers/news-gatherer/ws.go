@@ -7,12 +7,16 @@ import (
 	"github.com/gorilla/websocket"
 	"log/slog"
 	"net/http"
+	"project/internal/models"
 	"project/internal/pkg/logger/sl"
 	"project/internal/server/web/handlers/news-gatherer/clients"
 	"project/internal/storage"
+	"strconv"
 	"time"
 )
 
+const wsPageLimit = 10
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -21,7 +25,7 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-func NewsSender(db Storage, clients *clients.Clients, log *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
+func NewsSender(db Storage, md Media, clients *clients.Clients, log *slog.Logger) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const fn = "[HTTP SERVER] news-gatherer.New"
 
@@ -32,7 +36,7 @@ func NewsSender(db Storage, clients *clients.Clients, log *slog.Logger) func(w h
 			return
 		}
 
-		connID := clients.Add(conn)
+		connID := clients.Add(conn, r.RemoteAddr)
 
 		log.Info("[HTTP SERVER] new client", slog.String("connID", connID))
 
@@ -50,7 +54,12 @@ func NewsSender(db Storage, clients *clients.Clients, log *slog.Logger) func(w h
 			}
 		}()
 
-		prepareMsg, err := db.GetWebMessages(context.TODO(), 10, 0)
+		c, _ := clients.Get(connID)
+
+		// A reconnecting client passes the last id it saw to receive what it missed
+		afterID, _ := strconv.ParseInt(r.URL.Query().Get("after_id"), 10, 64)
+
+		prepareMsg, err := db.GetWebMessages(context.TODO(), models.WebMessageFilter{Limit: wsPageLimit, AfterID: afterID})
 		if err != nil {
 			if !errors.Is(err, storage.ErrNoRecordsFound) {
 				log.Error(fn, sl.Err(err))
@@ -61,20 +70,10 @@ func NewsSender(db Storage, clients *clients.Clients, log *slog.Logger) func(w h
 		var prepareMsgReq []webMessageReq
 
 		for _, msg := range prepareMsg {
-			prepareMsgReq = append(prepareMsgReq, webMessageReq{
-				GroupName: msg.GroupName,
-				Text:      msg.Text,
-				Metadata:  msg.Metadata,
-				CreatedAt: msg.CreatedAt,
-				Type:      msg.Type,
-				New:       false,
-			})
+			prepareMsgReq = append(prepareMsgReq, toWebMessageReq(context.TODO(), md, msg, false))
 		}
 
-		c, _ := clients.Get(connID)
-		c.AddOffset(len(prepareMsgReq))
-
-		err = conn.WriteJSON(prepareMsgReq)
+		err = c.SendMsg(prepareMsgReq)
 		if err != nil {
 			log.Error(fn, sl.Err(err))
 			return
@@ -126,7 +125,7 @@ func NewsSender(db Storage, clients *clients.Clients, log *slog.Logger) func(w h
 				continue
 			}
 
-			var req map[string]any
+			var req wsReq
 
 			err = json.Unmarshal(p, &req)
 			if err != nil {
@@ -136,10 +135,15 @@ func NewsSender(db Storage, clients *clients.Clients, log *slog.Logger) func(w h
 
 			log.Debug("read json", slog.Any("req", req))
 
-			if action, ok := req["action"].(string); ok && action == "getMsg" {
+			if req.Action == "getMsg" {
 
-				offset := c.GetOffset()
-				oldMsg, err := db.GetWebMessages(context.TODO(), 10, offset)
+				filter := models.WebMessageFilter{
+					Limit:    wsPageLimit,
+					BeforeID: req.BeforeID,
+					AfterID:  req.AfterID,
+				}
+
+				oldMsg, err := db.GetWebMessages(context.TODO(), filter)
 				if err != nil {
 					if errors.Is(err, storage.ErrNoRecordsFound) {
 						continue
@@ -152,19 +156,32 @@ func NewsSender(db Storage, clients *clients.Clients, log *slog.Logger) func(w h
 				var oldMsgReq []webMessageReq
 
 				for _, msg := range oldMsg {
-					oldMsgReq = append(oldMsgReq, webMessageReq{
-						GroupName: msg.GroupName,
-						Text:      msg.Text,
-						Metadata:  msg.Metadata,
-						CreatedAt: msg.CreatedAt,
-						Type:      msg.Type,
-						New:       false,
-					})
+					oldMsgReq = append(oldMsgReq, toWebMessageReq(context.TODO(), md, msg, false))
+				}
+
+				err = c.SendMsg(oldMsgReq)
+				if err != nil {
+					log.Error(fn, sl.Err(err))
+					return
+				}
+			}
+
+			if req.Action == "search" {
+				query, err := parseSearchQuery(req.Query, strconv.Itoa(wsPageLimit), strconv.Itoa(req.Offset))
+				if err != nil {
+					log.Debug(fn, sl.Err(err))
+					continue
+				}
+
+				resp, err := search(context.TODO(), db, md, query)
+				if err != nil {
+					log.Error(fn, sl.Err(err))
+					return
 				}
 
-				c.AddOffset(len(oldMsgReq))
+				resp.Event = eventSearch
 
-				err = conn.WriteJSON(oldMsgReq)
+				err = c.SendMsg(resp)
 				if err != nil {
 					log.Error(fn, sl.Err(err))
 					return
@@ -14,6 +14,20 @@ func (s *Server) Listener(h Handlers) {
 	})
 
 	http.HandleFunc("/ws", h.newsSender)
+	http.HandleFunc("GET /sse", h.newsStream)
+
+	http.HandleFunc("GET /api/v1/news", h.newsList)
+	http.HandleFunc("GET /api/v1/news/{id}", h.newsByID)
+	http.HandleFunc("GET /api/v1/search", h.newsSearch)
+
+	http.HandleFunc("GET /feed.rss", h.feedRSS)
+	http.HandleFunc("GET /feed.atom", h.feedAtom)
+	http.HandleFunc("GET /feed.json", h.feedJSON)
+
+	http.HandleFunc("POST /vk/callback", h.vkCallback)
+
+	http.HandleFunc("GET /media/{key...}", h.mediaFile)
+	http.HandleFunc("GET /files/{bucket}/{name...}", h.storedFile)
 
 	go h.newsReader()
 
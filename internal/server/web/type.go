@@ -4,8 +4,10 @@ import (
 	"log/slog"
 	"net/http"
 	"project/internal/config"
+	"project/internal/server/web/handlers/media"
 	news_gatherer "project/internal/server/web/handlers/news-gatherer"
 	clients "project/internal/server/web/handlers/news-gatherer/clients"
+	"strings"
 )
 
 type Server struct {
@@ -15,7 +17,17 @@ type Server struct {
 
 type Handlers struct {
 	newsSender func(w http.ResponseWriter, r *http.Request)
+	newsStream func(w http.ResponseWriter, r *http.Request)
 	newsReader func()
+	newsList   func(w http.ResponseWriter, r *http.Request)
+	newsByID   func(w http.ResponseWriter, r *http.Request)
+	newsSearch func(w http.ResponseWriter, r *http.Request)
+	feedRSS    func(w http.ResponseWriter, r *http.Request)
+	feedAtom   func(w http.ResponseWriter, r *http.Request)
+	feedJSON   func(w http.ResponseWriter, r *http.Request)
+	vkCallback func(w http.ResponseWriter, r *http.Request)
+	mediaFile  func(w http.ResponseWriter, r *http.Request)
+	storedFile func(w http.ResponseWriter, r *http.Request)
 }
 
 func NewServer(cfg *config.WebServer, log *slog.Logger) *Server {
@@ -32,11 +44,26 @@ func NewServer(cfg *config.WebServer, log *slog.Logger) *Server {
 	}
 }
 
-func NewHandler(db news_gatherer.Storage, log *slog.Logger) Handlers {
+func NewHandler(cfg *config.WebServer, db news_gatherer.Storage, bus news_gatherer.Events, md *media.Handler, vkCallback http.HandlerFunc, log *slog.Logger) Handlers {
 	wsConnClients := clients.New()
 
+	feedInfo := news_gatherer.FeedInfo{
+		Title:     cfg.FeedTitle,
+		PublicURL: strings.TrimSuffix(cfg.PublicURL, "/"),
+	}
+
 	return Handlers{
-		newsSender: news_gatherer.NewsSender(db, wsConnClients, log),
-		newsReader: news_gatherer.NewsReader(db, wsConnClients, log),
+		newsSender: news_gatherer.NewsSender(db, md, wsConnClients, log),
+		newsStream: news_gatherer.NewsStream(db, md, wsConnClients, log),
+		newsReader: news_gatherer.NewsReader(bus, md, wsConnClients, log),
+		newsList:   news_gatherer.NewsList(db, md, log),
+		newsByID:   news_gatherer.NewsByID(db, md, log),
+		newsSearch: news_gatherer.NewsSearch(db, md, log),
+		feedRSS:    news_gatherer.NewsFeed(news_gatherer.FeedRSS, feedInfo, db, md, log),
+		feedAtom:   news_gatherer.NewsFeed(news_gatherer.FeedAtom, feedInfo, db, md, log),
+		feedJSON:   news_gatherer.NewsFeed(news_gatherer.FeedJSON, feedInfo, db, md, log),
+		vkCallback: vkCallback,
+		mediaFile:  md.Serve,
+		storedFile: md.ServeFiles,
 	}
 }
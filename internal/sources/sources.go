@@ -0,0 +1,246 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"project/internal/pkg/logger/sl"
+	"project/internal/storage"
+	"project/pkg/e"
+	"time"
+)
+
+func (r *Registry) Register(src Source) {
+	if _, ok := r.sources[src.Kind()]; !ok {
+		r.kinds = append(r.kinds, src.Kind())
+	}
+
+	r.sources[src.Kind()] = src
+}
+
+func (r *Registry) Get(kind string) (Source, bool) {
+	src, ok := r.sources[kind]
+
+	return src, ok
+}
+
+// Kinds returns registered source kinds in registration order
+func (r *Registry) Kinds() []string {
+	return r.kinds
+}
+
+// PrepareNewsGatherer starts listeners for all stored sources
+func (r *Registry) PrepareNewsGatherer(ctx context.Context) error {
+	const fn = "sources.PrepareNewsGatherer"
+
+	for _, kind := range r.kinds {
+		src := r.sources[kind]
+
+		infos, err := src.Describe(ctx)
+		if err != nil {
+			if errors.Is(err, storage.ErrNoRecordsFound) {
+				continue
+			}
+
+			return e.Wrap(fn, err)
+		}
+
+		for _, info := range infos {
+			if _, err := src.Validate(ctx, info.Key); err != nil {
+				r.log.Error(fn,
+					sl.Err(err),
+					slog.String("kind", kind),
+					slog.String("key", info.Key),
+				)
+
+				continue
+			}
+
+			r.ls.mu.Lock()
+			r.run(kind, info)
+			r.ls.mu.Unlock()
+		}
+	}
+
+	return nil
+}
+
+func (r *Registry) ListenStart(ctx context.Context, kind, key string) error {
+	const fn = "sources.ListenStart"
+
+	src, ok := r.sources[kind]
+	if !ok {
+		return e.Wrap(fn, ErrUnknownKind)
+	}
+
+	r.ls.mu.RLock()
+	_, ok = r.ls.m[listenerKey(kind, key)]
+	if ok {
+		r.ls.mu.RUnlock()
+		return e.Wrap(fn, ErrSourceIsExists)
+	}
+	r.ls.mu.RUnlock()
+
+	info, err := src.Validate(ctx, key)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	r.ls.mu.Lock()
+	defer r.ls.mu.Unlock()
+
+	_, ok = r.ls.m[listenerKey(kind, key)]
+	if ok {
+		return e.Wrap(fn, ErrSourceIsExists)
+	}
+
+	info, err = src.Start(ctx, info)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	r.run(kind, info)
+
+	return nil
+}
+
+func (r *Registry) Shutdown(ctx context.Context, kind, key string) error {
+	const fn = "sources.Shutdown"
+
+	src, ok := r.sources[kind]
+	if !ok {
+		return e.Wrap(fn, ErrUnknownKind)
+	}
+
+	r.ls.mu.Lock()
+	defer r.ls.mu.Unlock()
+
+	listener, ok := r.ls.m[listenerKey(kind, key)]
+	if !ok {
+		return e.Wrap(fn, ErrSourceNotFound)
+	}
+
+	if err := src.Stop(ctx, listener.info); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	close(listener.stop)
+	delete(r.ls.m, listenerKey(kind, key))
+
+	r.log.Info("[SOURCES] Listener shutting down",
+		slog.String("kind", kind),
+		slog.String("key", key),
+	)
+
+	return nil
+}
+
+// Backfill loads the history of a started source, only one backfill per source runs at a time
+func (r *Registry) Backfill(ctx context.Context, kind, key string, opts BackfillOptions, progress func(stored int)) (int, error) {
+	const fn = "sources.Backfill"
+
+	src, ok := r.sources[kind]
+	if !ok {
+		return 0, e.Wrap(fn, ErrUnknownKind)
+	}
+
+	bf, ok := src.(Backfiller)
+	if !ok {
+		return 0, e.Wrap(fn, ErrBackfillUnsupported)
+	}
+
+	lk := listenerKey(kind, key)
+
+	r.ls.mu.Lock()
+
+	listener, ok := r.ls.m[lk]
+	if !ok {
+		r.ls.mu.Unlock()
+		return 0, e.Wrap(fn, ErrSourceNotFound)
+	}
+
+	if r.ls.backfills[lk] {
+		r.ls.mu.Unlock()
+		return 0, e.Wrap(fn, ErrBackfillIsRunning)
+	}
+
+	r.ls.backfills[lk] = true
+	r.ls.mu.Unlock()
+
+	defer func() {
+		r.ls.mu.Lock()
+		delete(r.ls.backfills, lk)
+		r.ls.mu.Unlock()
+	}()
+
+	stored, err := bf.Backfill(ctx, listener.info, opts, progress)
+	if err != nil {
+		return stored, e.Wrap(fn, err)
+	}
+
+	return stored, nil
+}
+
+func (r *Registry) SetInterval(ctx context.Context, kind, key string, min, max time.Duration) error {
+	const fn = "sources.SetInterval"
+
+	src, ok := r.sources[kind]
+	if !ok {
+		return e.Wrap(fn, ErrUnknownKind)
+	}
+
+	sc, ok := src.(Scheduled)
+	if !ok {
+		return e.Wrap(fn, ErrIntervalUnsupported)
+	}
+
+	r.ls.mu.RLock()
+	listener, ok := r.ls.m[listenerKey(kind, key)]
+	r.ls.mu.RUnlock()
+
+	if !ok {
+		return e.Wrap(fn, ErrSourceNotFound)
+	}
+
+	if err := sc.SetInterval(ctx, listener.info, min, max); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+// ShutdownAll stops all listeners without removing stored sources
+func (r *Registry) ShutdownAll() {
+	r.ls.mu.Lock()
+	defer r.ls.mu.Unlock()
+
+	for k, listener := range r.ls.m {
+		close(listener.stop)
+		delete(r.ls.m, k)
+	}
+}
+
+// run must be called with r.ls.mu locked
+func (r *Registry) run(kind string, info Info) {
+	listener := &Listener{
+		info: info,
+		stop: make(chan struct{}),
+	}
+
+	r.ls.m[listenerKey(kind, info.Key)] = listener
+
+	if p, ok := r.sources[kind].(Pusher); ok && p.Pushed(info) {
+		r.log.Info("[SOURCES] Source pushes its posts, listener is not started",
+			slog.String("kind", kind),
+			slog.String("key", info.Key),
+		)
+
+		return
+	}
+
+	go r.sources[kind].Listen(info, listener.stop)
+}
+
+func listenerKey(kind, key string) string {
+	return kind + ":" + key
+}
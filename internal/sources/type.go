@@ -0,0 +1,105 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Source is a polled news source kind (vk, rss, ...).
+// Telegram groups and channels push updates through the bot and are not sources.
+type Source interface {
+	// Kind is the short name used in bot commands, e.g. "/add vk <Domain>"
+	Kind() string
+
+	// Validate checks that the source with the given key (domain, url...) is reachable
+	Validate(ctx context.Context, key string) (Info, error)
+
+	// Describe returns all stored sources of this kind
+	Describe(ctx context.Context) ([]Info, error)
+
+	// Start stores a validated source and returns its stored form
+	Start(ctx context.Context, info Info) (Info, error)
+
+	// Stop removes a stored source
+	Stop(ctx context.Context, info Info) error
+
+	// Listen polls the source until stopCh is closed
+	Listen(info Info, stopCh <-chan struct{})
+}
+
+// Backfiller is implemented by sources that can load posts published before they were added
+type Backfiller interface {
+	// Backfill stores older posts of a started source without broadcasting them,
+	// progress is called after every loaded page with the number of stored posts
+	Backfill(ctx context.Context, info Info, opts BackfillOptions, progress func(stored int)) (int, error)
+}
+
+// BackfillOptions Limit caps the number of loaded posts, posts older than Since are not loaded
+type BackfillOptions struct {
+	Limit int
+	Since time.Time
+}
+
+// Pusher is implemented by sources that can receive posts without polling
+type Pusher interface {
+	// Pushed reports that the source pushes its posts and Listen must not be started
+	Pushed(info Info) bool
+}
+
+// Scheduled is implemented by sources with a configurable poll interval
+type Scheduled interface {
+	// SetInterval bounds the poll interval of a started source, it is applied without restart
+	SetInterval(ctx context.Context, info Info, min, max time.Duration) error
+}
+
+type Info struct {
+	ID   int64
+	Key  string
+	Name string
+}
+
+type Registry struct {
+	sources map[string]Source
+	kinds   []string
+	ls      *listeners
+	log     *slog.Logger
+}
+
+type listeners struct {
+	m  map[string]*Listener
+	mu sync.RWMutex
+	// backfills are the listener keys with a running backfill
+	backfills map[string]bool
+}
+
+type Listener struct {
+	info Info
+	stop chan struct{}
+}
+
+var (
+	ErrUnknownKind     = errors.New("unknown source kind")
+	ErrSourceIsPrivate = errors.New("source is private")
+	ErrSourceNotFound  = errors.New("source not found")
+	ErrSourceIsExists  = errors.New("source is exists")
+
+	ErrBackfillUnsupported = errors.New("source does not support backfill")
+	ErrBackfillIsRunning   = errors.New("backfill is already running")
+
+	ErrIntervalUnsupported = errors.New("source does not support poll intervals")
+	ErrBadInterval         = errors.New("bad poll interval")
+)
+
+func New(log *slog.Logger) *Registry {
+	return &Registry{
+		sources: make(map[string]Source),
+		ls: &listeners{
+			m:         make(map[string]*Listener),
+			backfills: make(map[string]bool),
+		},
+		log: log,
+	}
+}
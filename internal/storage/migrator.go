@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/golang-migrate/migrate/v4"
+	"log/slog"
+	"project/pkg/e"
+)
+
+var (
+	ErrDirtySchema = errors.New("database schema is dirty")
+)
+
+// Migrator applies versioned migrations. It never rolls back on its own,
+// Down is only reachable through Steps with an explicit negative count.
+type Migrator struct {
+	db  *sql.DB
+	m   *migrate.Migrate
+	log *slog.Logger
+}
+
+// NewMigrator takes over db and m, Close closes both
+func NewMigrator(db *sql.DB, m *migrate.Migrate, log *slog.Logger) *Migrator {
+	return &Migrator{
+		db:  db,
+		m:   m,
+		log: log,
+	}
+}
+
+// Up applies all pending migrations, refusing to run on a dirty schema
+func (mg *Migrator) Up() error {
+	const fn = "storage.Migrator.Up"
+
+	if err := mg.checkDirty(); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := mg.m.Up(); err != nil {
+		if errors.Is(err, migrate.ErrNoChange) {
+			mg.log.Info("[OK] Migrations: no change to apply")
+
+			return nil
+		}
+
+		return e.Wrap(fn, err)
+	}
+
+	mg.log.Info("[OK] Migrations applied successfully!")
+
+	return nil
+}
+
+// Steps applies n migrations up (n > 0) or rolls back -n migrations (n < 0)
+func (mg *Migrator) Steps(n int) error {
+	const fn = "storage.Migrator.Steps"
+
+	if err := mg.checkDirty(); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := mg.m.Steps(n); err != nil {
+		if errors.Is(err, migrate.ErrNoChange) {
+			mg.log.Info("[OK] Migrations: no change to apply")
+
+			return nil
+		}
+
+		return e.Wrap(fn, err)
+	}
+
+	mg.log.Info("[OK] Migrations applied successfully!", slog.Int("steps", n))
+
+	return nil
+}
+
+// Force sets the schema version and clears the dirty flag without running migrations
+func (mg *Migrator) Force(version int) error {
+	const fn = "storage.Migrator.Force"
+
+	if err := mg.m.Force(version); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	mg.log.Info("[OK] Migrations: version forced", slog.Int("version", version))
+
+	return nil
+}
+
+// Version returns 0 if no migration has been applied yet
+func (mg *Migrator) Version() (uint, bool, error) {
+	const fn = "storage.Migrator.Version"
+
+	version, dirty, err := mg.m.Version()
+	if err != nil {
+		if errors.Is(err, migrate.ErrNilVersion) {
+			return 0, false, nil
+		}
+
+		return 0, false, e.Wrap(fn, err)
+	}
+
+	return version, dirty, nil
+}
+
+func (mg *Migrator) Close() error {
+	const fn = "storage.Migrator.Close"
+
+	srcErr, dbErr := mg.m.Close()
+
+	if err := errors.Join(srcErr, dbErr, mg.db.Close()); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+func (mg *Migrator) checkDirty() error {
+	version, dirty, err := mg.Version()
+	if err != nil {
+		return err
+	}
+
+	if dirty {
+		return fmt.Errorf("%w at version %d: fix the schema manually and run \"migrate force <version>\"", ErrDirtySchema, version)
+	}
+
+	return nil
+}
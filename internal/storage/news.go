@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"encoding/json"
+	"project/internal/models"
+	"time"
+	"unicode/utf16"
+)
+
+// NewsColumns are selected by the storage backends to make the news of a source message:
+// source_ref, group_name, title, link, prefix, text, metadata, entities, created_at, type, backfilled.
+// prefix goes before the text, e.g. the author of a group message
+const NewsColumns = "source_ref, group_name, title, link, prefix, text, metadata, entities, created_at, type, backfilled"
+
+// ScanNews scans the NewsColumns of a source message into its news,
+// the entities are moved past the prefix
+func ScanNews(row interface{ Scan(dest ...any) error }) (models.WebMessage, error) {
+	var (
+		msg                models.WebMessage
+		prefix, text       string
+		metadata, entities []byte
+		createdAt          time.Time
+	)
+
+	err := row.Scan(&msg.SourceRef, &msg.GroupName, &msg.Title, &msg.Link, &prefix, &text,
+		&metadata, &entities, &createdAt, &msg.Type, &msg.Backfilled)
+	if err != nil {
+		return models.WebMessage{}, err
+	}
+
+	msg.Text = prefix + text
+	msg.CreatedAt = createdAt.UTC()
+
+	if metadata != nil {
+		if err := json.Unmarshal(metadata, &msg.Metadata); err != nil {
+			return models.WebMessage{}, err
+		}
+	}
+
+	if entities != nil {
+		if err := json.Unmarshal(entities, &msg.Entities); err != nil {
+			return models.WebMessage{}, err
+		}
+	}
+
+	offset := utf16Len(prefix)
+
+	for i := range msg.Entities {
+		msg.Entities[i].Offset += offset
+	}
+
+	return msg, nil
+}
+
+// utf16Len is the length in Telegram entity offsets
+func utf16Len(s string) int {
+	n := 0
+
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+
+	return n
+}
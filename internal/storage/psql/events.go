@@ -0,0 +1,382 @@
+package psql
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"log/slog"
+	"project/internal/events"
+	"project/internal/models"
+	"project/internal/pkg/logger/sl"
+	"project/internal/storage"
+	"project/pkg/e"
+	"slices"
+	"strings"
+	"time"
+)
+
+// eventsChannel carries the events between the instances sharing the database
+const eventsChannel = "news_events"
+
+const receiveEventTimeout = 10 * time.Second
+
+// eventNotify holds the id of the news only, the receiver reads the news from web_messages.
+// Event is the outbox event, Key the idempotency key of the delivered event
+type eventNotify struct {
+	Origin string `json:"origin"`
+	Event  string `json:"event"`
+	ID     int64  `json:"id"`
+	Key    string `json:"key"`
+}
+
+// sourceKey is a stored source message, msgID is the guid for RSS items
+type sourceKey struct {
+	sourceID int64
+	msgID    string
+}
+
+// newsSelects select storage.NewsColumns of the source messages in the feed,
+// $1 and $2 are the source ids and message ids
+var newsSelects = map[string]string{
+	models.KindTgGroup: `
+	SELECT 'tg_group:' || m.group_id || ':' || m.msg_id, 'Группа: ' || g.name, '', '',
+		'От: ' || m.username || E'\n\n', m.text, m.metadata, m.entities, m.created_at, 'tg', FALSE
+	FROM tg_group_messages m
+	JOIN tg_groups g ON g.id = m.group_id
+	JOIN unnest($1::bigint[], $2::bigint[]) AS k(source_id, msg_id) ON k.source_id = m.group_id AND k.msg_id = m.msg_id
+	WHERE m.status = 'approved'`,
+
+	models.KindTgChannel: `
+	SELECT 'tg_channel:' || m.channel_id || ':' || m.msg_id, 'Канал: ' || g.name, '', '',
+		'', m.text, m.metadata, m.entities, m.created_at, 'tg', FALSE
+	FROM tg_channel_messages m
+	JOIN tg_channels g ON g.id = m.channel_id
+	JOIN unnest($1::bigint[], $2::bigint[]) AS k(source_id, msg_id) ON k.source_id = m.channel_id AND k.msg_id = m.msg_id
+	WHERE m.status = 'approved'`,
+
+	"vk": `
+	SELECT 'vk:' || m.group_id || ':' || m.msg_id, g.name, '', '',
+		'', m.text, m.metadata, NULL::jsonb, m.created_at, 'vk', m.backfilled
+	FROM vk_messages m
+	JOIN vk_groups g ON g.id = m.group_id
+	JOIN unnest($1::bigint[], $2::bigint[]) AS k(source_id, msg_id) ON k.source_id = m.group_id AND k.msg_id = m.msg_id`,
+
+	"rss": `
+	SELECT 'rss:' || m.feed_id || ':' || m.guid, f.title, m.title, m.link,
+		'', m.text, m.metadata, NULL::jsonb, m.created_at, 'rss', FALSE
+	FROM rss_items m
+	JOIN rss_feeds f ON f.id = m.feed_id
+	JOIN unnest($1::bigint[], $2::text[]) AS k(source_id, msg_id) ON k.source_id = m.feed_id AND k.msg_id = m.guid`,
+}
+
+// querier is *sql.DB or *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// addNews adds the news of the stored messages to the feed and writes models.OutboxPublished entries,
+// tx is the transaction that stored the messages
+func addNews(ctx context.Context, tx *sql.Tx, kind string, keys []sourceKey) error {
+	news, err := selectNews(ctx, tx, kind, keys)
+	if err != nil || len(news) == 0 {
+		return err
+	}
+
+	ids, err := insertWebMessages(ctx, tx, news)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]models.OutboxEntry, 0, len(news))
+
+	for i := range news {
+		news[i].ID = ids[i]
+		entries = append(entries, models.OutboxEntry{Event: models.OutboxPublished, NewsID: ids[i], News: &news[i]})
+	}
+
+	return writeOutbox(ctx, tx, entries)
+}
+
+// updateNews replaces the news of the edited messages and writes models.OutboxUpdated entries,
+// messages not in the feed are skipped
+func updateNews(ctx context.Context, tx *sql.Tx, kind string, keys []sourceKey) error {
+	news, err := selectNews(ctx, tx, kind, keys)
+	if err != nil {
+		return err
+	}
+
+	var entries []models.OutboxEntry
+
+	for _, msg := range news {
+		upd, err := updateWebMessageBySourceRef(ctx, tx, msg.SourceRef, msg.Text, msg.Metadata, msg.Entities)
+		if err != nil {
+			if errors.Is(err, storage.ErrNoRecordsFound) {
+				continue
+			}
+
+			return err
+		}
+
+		entries = append(entries, models.OutboxEntry{Event: models.OutboxUpdated, NewsID: upd.ID, News: &upd})
+	}
+
+	return writeOutbox(ctx, tx, entries)
+}
+
+// commitNews commits the feed changes with their outbox entries and wakes the relay
+func (s *Storage) commitNews(tx *sql.Tx) error {
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.outbox.Wake()
+
+	return nil
+}
+
+func writeOutbox(ctx context.Context, q querier, entries []models.OutboxEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var args []interface{}
+	var sets []string
+	idx := 1
+
+	for _, entry := range entries {
+		newsSQL := sql.NullString{}
+
+		if entry.News != nil {
+			newsJSON, err := json.Marshal(entry.News)
+			if err != nil {
+				return err
+			}
+
+			newsSQL = sql.NullString{
+				String: string(newsJSON),
+				Valid:  true,
+			}
+		}
+
+		sets = append(sets, fmt.Sprintf("($%d, $%d, $%d)", idx, idx+1, idx+2))
+		args = append(args, entry.Event, entry.NewsID, newsSQL)
+		idx += 3
+	}
+
+	_, err := q.ExecContext(ctx, `INSERT INTO news_outbox (event, news_id, news) VALUES `+strings.Join(sets, ", "), args...)
+
+	return err
+}
+
+// ClaimOutbox Returns the oldest entries not claimed by another relay, they are claimed for the lease
+func (s *Storage) ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEntry, error) {
+	const fn = "psql.ClaimOutbox"
+
+	q := `
+	UPDATE news_outbox SET claimed_until = CURRENT_TIMESTAMP + make_interval(secs => $2)
+	WHERE id IN (
+		SELECT id FROM news_outbox
+		WHERE claimed_until IS NULL OR claimed_until < CURRENT_TIMESTAMP
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING id, event, news_id, news`
+
+	rows, err := s.db.QueryContext(ctx, q, limit, lease.Seconds())
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	var entries []models.OutboxEntry
+
+	for rows.Next() {
+		var (
+			entry models.OutboxEntry
+			news  []byte
+		)
+
+		if err := rows.Scan(&entry.ID, &entry.Event, &entry.NewsID, &news); err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		if news != nil {
+			if err := json.Unmarshal(news, &entry.News); err != nil {
+				return nil, e.Wrap(fn, err)
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	// RETURNING keeps no order
+	slices.SortFunc(entries, func(a, b models.OutboxEntry) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return entries, nil
+}
+
+// DeleteOutbox Removes the delivered entries, with notify_events they are sent to the other instances on commit
+func (s *Storage) DeleteOutbox(ctx context.Context, ids []int64) error {
+	const fn = "psql.DeleteOutbox"
+
+	if s.notify == nil {
+		if _, err := s.db.ExecContext(ctx, `DELETE FROM news_outbox WHERE id = ANY($1)`, pq.Array(ids)); err != nil {
+			return e.Wrap(fn, err)
+		}
+
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	defer tx.Rollback()
+
+	q := `
+	WITH deleted AS (
+		DELETE FROM news_outbox WHERE id = ANY($1) RETURNING id, event, news_id
+	)
+	SELECT pg_notify($2, json_build_object('origin', $3::text, 'event', event, 'id', news_id, 'key', id::text)::text)
+	FROM deleted
+	ORDER BY id`
+
+	if _, err := tx.ExecContext(ctx, q, pq.Array(ids), eventsChannel, s.notify.origin); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+func selectNews(ctx context.Context, q querier, kind string, keys []sourceKey) ([]models.WebMessage, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	query, ok := newsSelects[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown source kind %q", kind)
+	}
+
+	sourceIDs := make([]int64, 0, len(keys))
+	msgIDs := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		sourceIDs = append(sourceIDs, key.sourceID)
+		msgIDs = append(msgIDs, key.msgID)
+	}
+
+	rows, err := q.QueryContext(ctx, query, pq.Array(sourceIDs), pq.StringArray(msgIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var news []models.WebMessage
+
+	for rows.Next() {
+		msg, err := storage.ScanNews(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		news = append(news, msg)
+	}
+
+	return news, rows.Err()
+}
+
+// listenEvents publishes the events of the other instances in-process.
+// Events sent while the connection is lost are not received again
+func (s *Storage) listenEvents(connStr string) (*pq.Listener, error) {
+	const fn = "psql.listenEvents"
+
+	listener := pq.NewListener(connStr, 10*time.Second, 60*time.Second, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			s.log.Error(fn, sl.Err(err))
+		}
+	})
+
+	if err := listener.Listen(eventsChannel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	go func() {
+		for n := range listener.Notify {
+			if n == nil {
+				s.log.Warn("Connection re-established", slog.String("fn", fn))
+				continue
+			}
+
+			if err := s.receiveEvent(n.Extra); err != nil {
+				s.log.Error(fn, sl.Err(err), slog.String("payload", n.Extra))
+			}
+		}
+	}()
+
+	return listener, nil
+}
+
+func (s *Storage) receiveEvent(payload string) error {
+	var n eventNotify
+
+	if err := json.Unmarshal([]byte(payload), &n); err != nil {
+		return err
+	}
+
+	if n.Origin == s.notify.origin {
+		return nil
+	}
+
+	// Events of the other instances are not delivered again, a stuck subscriber must not stop the listener
+	ctx, cancel := context.WithTimeout(context.Background(), receiveEventTimeout)
+	defer cancel()
+
+	switch n.Event {
+	case models.OutboxPublished, models.OutboxUpdated:
+		msg, err := s.GetWebMessage(ctx, n.ID)
+		if err != nil {
+			// Deleted since
+			if errors.Is(err, storage.ErrNoRecordsFound) {
+				return nil
+			}
+
+			return err
+		}
+
+		if n.Event == models.OutboxPublished {
+			s.events.Publish(ctx, events.NewsPublished{Key: n.Key, News: msg})
+		} else {
+			s.events.Publish(ctx, events.NewsUpdated{Key: n.Key, News: msg})
+		}
+
+	case models.OutboxDeleted:
+		s.events.Publish(ctx, events.NewsDeleted{Key: n.Key, ID: n.ID})
+
+	default:
+		return fmt.Errorf("unknown event %q", n.Event)
+	}
+
+	return nil
+}
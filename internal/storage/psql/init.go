@@ -2,42 +2,48 @@ package psql
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
-	"errors"
-	"fmt"
+	"encoding/hex"
 	"github.com/lib/pq"
 	"log/slog"
 	"project/internal/config"
-	"project/internal/pkg/logger/sl"
+	"project/internal/events"
 	"project/pkg/e"
-	"sync"
-	"time"
 
-	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	_ "github.com/lib/pq"
 )
 
 type Storage struct {
-	db        *sql.DB
-	log       *slog.Logger
-	notifiers *notifiers
+	db     *sql.DB
+	events Events
+	outbox Outbox
+	// notify is set when the events are also sent to other instances through NOTIFY
+	notify *eventsNotify
+	log    *slog.Logger
 }
 
-type notifiers struct {
-	m        map[string]chan *pq.Notification
-	mu       sync.RWMutex
+// Events receives the feed changes of the other instances
+type Events interface {
+	Publish(ctx context.Context, evs ...events.Event) int
+}
+
+// Outbox is woken after the feed changes are committed to news_outbox
+type Outbox interface {
+	Wake()
+}
+
+// eventsNotify origin tells the instance's own notifications from the others
+type eventsNotify struct {
+	origin   string
 	listener *pq.Listener
 }
 
-func New(ctx context.Context, cfg *config.DB, migratePath string, log *slog.Logger) (*Storage, error) {
+func New(ctx context.Context, cfg *config.DB, migratePath string, ev Events, ob Outbox, log *slog.Logger) (*Storage, error) {
 	const fn = "psql.New"
 
-	connStr := fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName,
-	)
+	connStr := connString(cfg)
 
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
@@ -50,82 +56,37 @@ func New(ctx context.Context, cfg *config.DB, migratePath string, log *slog.Logg
 
 	log.Info("[OK] psql successfully connected")
 
-	migrationDriver, err := postgres.WithInstance(db, &postgres.Config{})
-	if err != nil {
-		return nil, e.Wrap(fn, err)
-	}
-
-	m, err := migrate.NewWithDatabaseInstance(migratePath, "postgres", migrationDriver)
+	mg, err := NewMigrator(ctx, cfg, migratePath, log)
 	if err != nil {
 		return nil, e.Wrap(fn, err)
 	}
 
-	if err := m.Down(); err != nil {
-		if errors.Is(err, migrate.ErrNoChange) {
-			log.Info("[OK] nothing to do")
-		} else {
-			return nil, e.Wrap(fn, err)
-		}
-	}
+	defer mg.Close()
 
-	if err := m.Up(); err != nil {
-		if errors.Is(err, migrate.ErrNoChange) {
-			log.Info("[OK] Migrations: no change to apply")
-		} else {
-			return nil, e.Wrap(fn, err)
-		}
-	} else {
-		log.Info("[OK] Migrations applied successfully!")
+	if err := mg.Up(); err != nil {
+		return nil, e.Wrap(fn, err)
 	}
 
 	s := &Storage{
-		db:  db,
-		log: log,
-		notifiers: &notifiers{
-			m: make(map[string]chan *pq.Notification),
-		},
+		db:     db,
+		events: ev,
+		outbox: ob,
+		log:    log,
 	}
 
-	s.notifiers.listener = s.listenNotifications(connStr)
-
-	return s, nil
-}
+	if cfg.NotifyEvents {
+		origin := make([]byte, 8)
 
-func (s *Storage) listenNotifications(connStr string) *pq.Listener {
-	const fn = "psql.listenNotifications"
+		if _, err := rand.Read(origin); err != nil {
+			return nil, e.Wrap(fn, err)
+		}
 
-	listener := pq.NewListener(connStr, 10*time.Second, 60*time.Second, func(ev pq.ListenerEventType, err error) {
-		if err != nil {
-			s.log.Error(fn, sl.Err(err))
+		s.notify = &eventsNotify{origin: hex.EncodeToString(origin)}
 
-			return
-		}
-	})
-
-	go func() {
-		for n := range listener.Notify {
-			if n == nil {
-				s.log.Warn("Connection re-established", slog.String("fn", fn))
-				continue
-			}
-
-			s.notifiers.mu.RLock()
-
-			notifyCh, ok := s.notifiers.m[n.Channel]
-			if !ok {
-				s.log.Warn("notify channel not found", slog.String("channel", n.Channel), slog.String("fn", fn))
-				continue
-			}
-
-			select {
-			case notifyCh <- n:
-			default:
-				s.log.Debug("notify chan overflow", slog.String("channel", n.Channel), slog.String("fn", fn))
-			}
-
-			s.notifiers.mu.RUnlock()
+		if s.notify.listener, err = s.listenEvents(connStr); err != nil {
+			return nil, e.Wrap(fn, err)
 		}
-	}()
+	}
 
-	return listener
+	return s, nil
 }
@@ -0,0 +1,48 @@
+package psql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"log/slog"
+	"project/internal/config"
+	"project/internal/storage"
+	"project/pkg/e"
+)
+
+func NewMigrator(ctx context.Context, cfg *config.DB, migratePath string, log *slog.Logger) (*storage.Migrator, error) {
+	const fn = "psql.NewMigrator"
+
+	db, err := sql.Open("postgres", connString(cfg))
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, e.Wrap(fn, err)
+	}
+
+	migrationDriver, err := postgres.WithInstance(db, &postgres.Config{})
+	if err != nil {
+		db.Close()
+		return nil, e.Wrap(fn, err)
+	}
+
+	m, err := migrate.NewWithDatabaseInstance(migratePath, "postgres", migrationDriver)
+	if err != nil {
+		db.Close()
+		return nil, e.Wrap(fn, err)
+	}
+
+	return storage.NewMigrator(db, m, log), nil
+}
+
+func connString(cfg *config.DB) string {
+	return fmt.Sprintf(
+		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName,
+	)
+}
@@ -8,11 +8,14 @@ import (
 	"fmt"
 	"github.com/lib/pq"
 	_ "github.com/lib/pq"
+	"maps"
 	"project/internal/models"
 	"project/internal/storage"
 	"project/pkg/e"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func (s *Storage) GetUsersRole() (map[string]string, error) {
@@ -358,6 +361,7 @@ func (s *Storage) DeleteTgGroup(ctx context.Context, groupID int64) error {
 	return nil
 }
 
+// InsertTgChannelMessages Approved messages are published to the feed
 func (s *Storage) InsertTgChannelMessages(ctx context.Context, msgs []models.TgChMessage) error {
 	const fn = "psql.InsertTgChannelMessages"
 
@@ -365,7 +369,7 @@ func (s *Storage) InsertTgChannelMessages(ctx context.Context, msgs []models.TgC
 	var sets []string
 	idx := 1
 
-	q := `INSERT INTO tg_channel_messages (msg_id, channel_id, text, metadata, created_at) VALUES `
+	q := `INSERT INTO tg_channel_messages (msg_id, channel_id, text, metadata, created_at, status, entities) VALUES `
 
 	for _, msg := range msgs {
 		metadataSQL := sql.NullString{}
@@ -382,6 +386,11 @@ func (s *Storage) InsertTgChannelMessages(ctx context.Context, msgs []models.TgC
 			}
 		}
 
+		entitiesSQL, err := entitiesJSON(msg.Entities)
+		if err != nil {
+			return e.Wrap(fn, err)
+		}
+
 		createdAt := sql.NullTime{}
 
 		if !msg.CreatedAt.IsZero() {
@@ -393,26 +402,43 @@ func (s *Storage) InsertTgChannelMessages(ctx context.Context, msgs []models.TgC
 
 		sets = append(sets,
 			fmt.Sprintf(
-				"($%d, $%d, $%d, $%d, COALESCE($%d, CURRENT_TIMESTAMP))",
-				idx, idx+1, idx+2, idx+3, idx+4),
+				"($%d, $%d, $%d, $%d, COALESCE($%d, CURRENT_TIMESTAMP), COALESCE(NULLIF($%d, ''), 'approved'), $%d)",
+				idx, idx+1, idx+2, idx+3, idx+4, idx+5, idx+6),
 		)
-		args = append(args, msg.MessageID, msg.ChannelID, msg.Text, metadataSQL, createdAt)
-		idx += 5
+		args = append(args, msg.MessageID, msg.ChannelID, msg.Text, metadataSQL, createdAt, msg.Status, entitiesSQL)
+		idx += 7
 	}
 
 	q += strings.Join(sets, ", ")
 
 	q += `
-	ON CONFLICT (msg_id, channel_id) DO NOTHING`
+	ON CONFLICT (msg_id, channel_id) DO NOTHING
+	RETURNING channel_id, msg_id::text`
 
-	_, err := s.db.ExecContext(ctx, q, args...)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	defer tx.Rollback()
+
+	keys, err := insertKeys(ctx, tx, q, args)
 	if err != nil {
 		return e.Wrap(fn, err)
 	}
 
+	if err := addNews(ctx, tx, models.KindTgChannel, keys); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := s.commitNews(tx); err != nil {
+		return e.Wrap(fn, err)
+	}
+
 	return nil
 }
 
+// InsertTgGroupMessages Approved messages are published to the feed
 func (s *Storage) InsertTgGroupMessages(ctx context.Context, msgs []models.TgGroupMessage) error {
 	const fn = "psql.InsertTgGroupMessages"
 
@@ -420,7 +446,7 @@ func (s *Storage) InsertTgGroupMessages(ctx context.Context, msgs []models.TgGro
 	var sets []string
 	idx := 1
 
-	q := `INSERT INTO tg_group_messages (msg_id, group_id, username, text, metadata, created_at) VALUES `
+	q := `INSERT INTO tg_group_messages (msg_id, group_id, username, text, metadata, created_at, status, entities) VALUES `
 
 	for _, msg := range msgs {
 		metadataSQL := sql.NullString{}
@@ -437,6 +463,11 @@ func (s *Storage) InsertTgGroupMessages(ctx context.Context, msgs []models.TgGro
 			}
 		}
 
+		entitiesSQL, err := entitiesJSON(msg.Entities)
+		if err != nil {
+			return e.Wrap(fn, err)
+		}
+
 		createdAt := sql.NullTime{}
 
 		if !msg.CreatedAt.IsZero() {
@@ -448,34 +479,77 @@ func (s *Storage) InsertTgGroupMessages(ctx context.Context, msgs []models.TgGro
 
 		sets = append(sets,
 			fmt.Sprintf(
-				"($%d, $%d, $%d, $%d, $%d, COALESCE($%d, CURRENT_TIMESTAMP))",
-				idx, idx+1, idx+2, idx+3, idx+4, idx+5),
+				"($%d, $%d, $%d, $%d, $%d, COALESCE($%d, CURRENT_TIMESTAMP), COALESCE(NULLIF($%d, ''), 'approved'), $%d)",
+				idx, idx+1, idx+2, idx+3, idx+4, idx+5, idx+6, idx+7),
 		)
-		args = append(args, msg.MessageID, msg.GroupID, msg.Username, msg.Text, metadataSQL, createdAt)
-		idx += 6
+		args = append(args, msg.MessageID, msg.GroupID, msg.Username, msg.Text, metadataSQL, createdAt, msg.Status, entitiesSQL)
+		idx += 8
 	}
 
 	q += strings.Join(sets, ", ")
 
 	q += `
-	ON CONFLICT (msg_id, group_id) DO NOTHING`
+	ON CONFLICT (msg_id, group_id) DO NOTHING
+	RETURNING group_id, msg_id::text`
 
-	_, err := s.db.ExecContext(ctx, q, args...)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	defer tx.Rollback()
+
+	keys, err := insertKeys(ctx, tx, q, args)
 	if err != nil {
 		return e.Wrap(fn, err)
 	}
 
+	if err := addNews(ctx, tx, models.KindTgGroup, keys); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := s.commitNews(tx); err != nil {
+		return e.Wrap(fn, err)
+	}
+
 	return nil
 }
 
-func (s *Storage) InsertWebMessages(ctx context.Context, msgs []models.WebMessage) error {
-	const fn = "psql.InsertWebMessages"
+// insertKeys runs the insert returning the source id and message id of the inserted rows
+func insertKeys(ctx context.Context, q querier, query string, args []interface{}) ([]sourceKey, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var keys []sourceKey
+
+	for rows.Next() {
+		var key sourceKey
+
+		if err := rows.Scan(&key.sourceID, &key.msgID); err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// insertWebMessages Returns ids of the inserted messages in the same order
+func insertWebMessages(ctx context.Context, q querier, msgs []models.WebMessage) ([]int64, error) {
+	const fn = "psql.insertWebMessages"
 
 	var args []interface{}
 	var sets []string
 	idx := 1
 
-	q := `INSERT INTO web_messages (group_name, text, metadata, created_at, type) VALUES `
+	now := time.Now().UTC()
+
+	query := `INSERT INTO web_messages (group_name, title, link, text, metadata, created_at, type, source_ref, entities, backfilled, updated_at) VALUES `
 
 	for _, msg := range msgs {
 		metadataSQL := sql.NullString{}
@@ -483,7 +557,7 @@ func (s *Storage) InsertWebMessages(ctx context.Context, msgs []models.WebMessag
 		if msg.Metadata != nil {
 			metadataJSON, err := json.Marshal(msg.Metadata)
 			if err != nil {
-				return e.Wrap(fn, err)
+				return nil, e.Wrap(fn, err)
 			}
 
 			metadataSQL = sql.NullString{
@@ -492,31 +566,111 @@ func (s *Storage) InsertWebMessages(ctx context.Context, msgs []models.WebMessag
 			}
 		}
 
+		entitiesSQL, err := entitiesJSON(msg.Entities)
+		if err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
 		sets = append(sets,
 			fmt.Sprintf(
-				"($%d, $%d, $%d, $%d, $%d)",
-				idx, idx+1, idx+2, idx+3, idx+4),
+				"($%d, NULLIF($%d, ''), NULLIF($%d, ''), $%d, $%d, $%d, $%d, NULLIF($%d, ''), $%d, $%d, $%d)",
+				idx, idx+1, idx+2, idx+3, idx+4, idx+5, idx+6, idx+7, idx+8, idx+9, idx+10),
 		)
-		args = append(args, msg.GroupName, msg.Text, metadataSQL, msg.CreatedAt, msg.Type)
-		idx += 5
+		args = append(args, msg.GroupName, msg.Title, msg.Link, msg.Text, metadataSQL, msg.CreatedAt, msg.Type, msg.SourceRef, entitiesSQL, msg.Backfilled, now)
+		idx += 11
 	}
 
-	q += strings.Join(sets, ", ")
+	query += strings.Join(sets, ", ")
 
-	_, err := s.db.ExecContext(ctx, q, args...)
+	query += ` RETURNING id`
+
+	rows, err := q.QueryContext(ctx, query, args...)
 	if err != nil {
-		return e.Wrap(fn, err)
+		return nil, e.Wrap(fn, err)
 	}
 
-	return nil
+	defer rows.Close()
+
+	ids := make([]int64, 0, len(msgs))
+
+	for rows.Next() {
+		var id int64
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	return ids, nil
 }
 
-func (s *Storage) GetWebMessages(ctx context.Context, limit int, offset int) ([]models.WebMessage, error) {
+const webMessageColumns = `id, group_name, COALESCE(title, ''), COALESCE(link, ''), text, metadata, created_at, type, entities,
+	COALESCE(source_ref, ''), backfilled, updated_at`
+
+func (s *Storage) GetWebMessages(ctx context.Context, filter models.WebMessageFilter) ([]models.WebMessage, error) {
 	const fn = "psql.GetWebMessages"
 
-	q := `SELECT * FROM web_messages ORDER BY created_at DESC LIMIT $1 OFFSET $2`
+	var (
+		conds []string
+		args  []interface{}
+	)
+
+	addCond := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if len(filter.Types) != 0 {
+		addCond("type = ANY($%d)", pq.Array(filter.Types))
+	}
+
+	if filter.GroupName != "" {
+		addCond("strpos(lower(group_name), lower($%d)) > 0", filter.GroupName)
+	}
+
+	if !filter.From.IsZero() {
+		addCond("created_at >= $%d", filter.From)
+	}
+
+	if !filter.To.IsZero() {
+		addCond("created_at < $%d", filter.To)
+	}
+
+	if filter.BeforeID != 0 {
+		// A deleted cursor falls back to the id order, so paging goes on instead of returning nothing
+		addCond(`CASE WHEN EXISTS (SELECT 1 FROM web_messages WHERE id = $%[1]d)
+			THEN (created_at, id) < (SELECT created_at, id FROM web_messages WHERE id = $%[1]d)
+			ELSE id < $%[1]d END`, filter.BeforeID)
+	}
+
+	order := "created_at DESC, id DESC"
+
+	// Backfilled history was never live, so it is not replayed as missed news
+	if filter.AfterID != 0 {
+		addCond("id > $%d AND NOT backfilled", filter.AfterID)
+
+		order = "id ASC"
+	}
+
+	q := `SELECT ` + webMessageColumns + ` FROM web_messages`
+
+	if len(conds) != 0 {
+		q += ` WHERE ` + strings.Join(conds, " AND ")
+	}
 
-	rows, err := s.db.QueryContext(ctx, q, limit, offset)
+	args = append(args, filter.Limit)
+
+	q += fmt.Sprintf(`
+	ORDER BY %s
+	LIMIT $%d`, order, len(args))
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
 	if err != nil {
 		return nil, e.Wrap(fn, err)
 	}
@@ -526,22 +680,9 @@ func (s *Storage) GetWebMessages(ctx context.Context, limit int, offset int) ([]
 	var msgs []models.WebMessage
 
 	for rows.Next() {
-		var msg models.WebMessage
-		var metadataStr sql.NullString
-
-		err := rows.Scan(&msg.ID, &msg.GroupName, &msg.Text, &metadataStr, &msg.CreatedAt, &msg.Type)
+		msg, err := scanWebMessage(rows)
 		if err != nil {
-			return nil, err
-		}
-
-		if metadataStr.Valid {
-			var metadata []models.MetaPair
-			err = json.Unmarshal([]byte(metadataStr.String), &metadata)
-			if err != nil {
-				return nil, err
-			}
-
-			msg.Metadata = metadata
+			return nil, e.Wrap(fn, err)
 		}
 
 		msgs = append(msgs, msg)
@@ -554,10 +695,149 @@ func (s *Storage) GetWebMessages(ctx context.Context, limit int, offset int) ([]
 	return msgs, nil
 }
 
+// GetFeedDeletedAt Returns the time a news was last deleted from the feed, zero when none was
+func (s *Storage) GetFeedDeletedAt(ctx context.Context) (time.Time, error) {
+	const fn = "psql.GetFeedDeletedAt"
+
+	var deletedAt sql.NullTime
+
+	err := s.db.QueryRowContext(ctx, `SELECT deleted_at FROM web_messages_state`).Scan(&deletedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, e.Wrap(fn, err)
+	}
+
+	return deletedAt.Time, nil
+}
+
+func (s *Storage) GetWebMessage(ctx context.Context, id int64) (models.WebMessage, error) {
+	const fn = "psql.GetWebMessage"
+
+	q := `SELECT ` + webMessageColumns + ` FROM web_messages WHERE id = $1`
+
+	msg, err := scanWebMessage(s.db.QueryRowContext(ctx, q, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.WebMessage{}, storage.ErrNoRecordsFound
+		}
+
+		return models.WebMessage{}, e.Wrap(fn, err)
+	}
+
+	return msg, nil
+}
+
+// SearchWebMessages The query is matched with both russian and english stemming, best matches first
+func (s *Storage) SearchWebMessages(ctx context.Context, query models.SearchQuery) ([]models.SearchHit, error) {
+	const fn = "psql.SearchWebMessages"
+
+	q := `
+	WITH q AS (
+		SELECT websearch_to_tsquery('russian', $1) || websearch_to_tsquery('english', $1) AS query
+	)
+	SELECT ` + webMessageColumns + `,
+		ts_rank_cd(search_vector, query) AS rank,
+		ts_headline('russian', text, query, $4) AS snippet
+	FROM web_messages, q
+	WHERE search_vector @@ query
+	ORDER BY rank DESC, created_at DESC, id DESC
+	LIMIT $2 OFFSET $3`
+
+	headline := fmt.Sprintf(`StartSel="%s", StopSel="%s", MaxFragments=2, MaxWords=25, MinWords=8, FragmentDelimiter=" … "`,
+		models.SnippetStart, models.SnippetStop)
+
+	rows, err := s.db.QueryContext(ctx, q, query.Query, query.Limit, query.Offset, headline)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	var hits []models.SearchHit
+
+	for rows.Next() {
+		var hit models.SearchHit
+
+		hit.Message, err = scanWebMessage(rows, &hit.Rank, &hit.Snippet)
+		if err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		hits = append(hits, hit)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	if len(hits) == 0 {
+		return nil, storage.ErrNoRecordsFound
+	}
+
+	return hits, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanWebMessage extra receives the columns selected after webMessageColumns
+func scanWebMessage(row scanner, extra ...any) (models.WebMessage, error) {
+	var msg models.WebMessage
+	var metadataStr, entitiesStr sql.NullString
+	var updatedAt sql.NullTime
+
+	dest := []any{&msg.ID, &msg.GroupName, &msg.Title, &msg.Link, &msg.Text, &metadataStr, &msg.CreatedAt, &msg.Type, &entitiesStr,
+		&msg.SourceRef, &msg.Backfilled, &updatedAt}
+
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return models.WebMessage{}, err
+	}
+
+	// News added before updated_at was tracked count as changed when created
+	msg.UpdatedAt = msg.CreatedAt
+	if updatedAt.Valid {
+		msg.UpdatedAt = updatedAt.Time
+	}
+
+	if metadataStr.Valid {
+		var metadata []models.MetaPair
+		err = json.Unmarshal([]byte(metadataStr.String), &metadata)
+		if err != nil {
+			return models.WebMessage{}, err
+		}
+
+		msg.Metadata = metadata
+	}
+
+	if entitiesStr.Valid {
+		err = json.Unmarshal([]byte(entitiesStr.String), &msg.Entities)
+		if err != nil {
+			return models.WebMessage{}, err
+		}
+	}
+
+	return msg, nil
+}
+
+// entitiesJSON Messages without entities are stored as NULL
+func entitiesJSON(entities []models.Entity) (sql.NullString, error) {
+	if len(entities) == 0 {
+		return sql.NullString{}, nil
+	}
+
+	entitiesJSON, err := json.Marshal(entities)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	return sql.NullString{String: string(entitiesJSON), Valid: true}, nil
+}
+
 func (s *Storage) GetTgChannels(ctx context.Context) ([]models.TgChannel, error) {
 	const fn = "psql.GetTgChannels"
 
-	q := `SELECT * FROM tg_channels`
+	q := `SELECT id, name, description FROM tg_channels`
 
 	rows, err := s.db.QueryContext(ctx, q)
 	if err != nil {
@@ -602,7 +882,7 @@ func (s *Storage) GetTgChannels(ctx context.Context) ([]models.TgChannel, error)
 func (s *Storage) GetTgGroups(ctx context.Context) ([]models.TgGroup, error) {
 	const fn = "psql.GetTgGroups"
 
-	q := `SELECT * FROM tg_groups`
+	q := `SELECT id, name, description FROM tg_groups`
 
 	rows, err := s.db.QueryContext(ctx, q)
 	if err != nil {
@@ -644,14 +924,116 @@ func (s *Storage) GetTgGroups(ctx context.Context) ([]models.TgGroup, error) {
 	return groups, nil
 }
 
+// InsertVkMessages Edited posts replace the stored ones and update their news
 func (s *Storage) InsertVkMessages(ctx context.Context, msgs []models.VkMessage) error {
 	const fn = "psql.InsertVkMessages"
 
+	q, args, err := insertVkMessagesQuery(msgs)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	// xmax is 0 for the inserted rows
+	q += `
+	ON CONFLICT (msg_id, group_id) DO UPDATE
+	SET text = EXCLUDED.text, metadata = EXCLUDED.metadata, edited_at = EXCLUDED.edited_at
+	WHERE EXCLUDED.edited_at IS NOT NULL AND vk_messages.edited_at IS DISTINCT FROM EXCLUDED.edited_at
+	RETURNING group_id, msg_id::text, xmax = 0`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, q, args...)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	var inserted, edited []sourceKey
+
+	for rows.Next() {
+		var (
+			key   sourceKey
+			isNew bool
+		)
+
+		if err := rows.Scan(&key.sourceID, &key.msgID, &isNew); err != nil {
+			return e.Wrap(fn, err)
+		}
+
+		if isNew {
+			inserted = append(inserted, key)
+		} else {
+			edited = append(edited, key)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	rows.Close()
+
+	if err := addNews(ctx, tx, "vk", inserted); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := updateNews(ctx, tx, "vk", edited); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := s.commitNews(tx); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+// InsertBackfillVkMessages Returns the number of inserted messages, stored ones are skipped
+func (s *Storage) InsertBackfillVkMessages(ctx context.Context, msgs []models.VkMessage) (int, error) {
+	const fn = "psql.InsertBackfillVkMessages"
+
+	q, args, err := insertVkMessagesQuery(msgs)
+	if err != nil {
+		return 0, e.Wrap(fn, err)
+	}
+
+	q += ` ON CONFLICT DO NOTHING RETURNING group_id, msg_id::text`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, e.Wrap(fn, err)
+	}
+
+	defer tx.Rollback()
+
+	keys, err := insertKeys(ctx, tx, q, args)
+	if err != nil {
+		return 0, e.Wrap(fn, err)
+	}
+
+	if err := addNews(ctx, tx, "vk", keys); err != nil {
+		return 0, e.Wrap(fn, err)
+	}
+
+	if err := s.commitNews(tx); err != nil {
+		return 0, e.Wrap(fn, err)
+	}
+
+	return len(keys), nil
+}
+
+func insertVkMessagesQuery(msgs []models.VkMessage) (string, []interface{}, error) {
 	var args []interface{}
 	var sets []string
 	idx := 1
 
-	q := `INSERT INTO vk_messages (msg_id, group_id, text, metadata, created_at) VALUES `
+	q := `INSERT INTO vk_messages (msg_id, group_id, text, metadata, created_at, edited_at, backfilled) VALUES `
 
 	for _, msg := range msgs {
 		metadataSQL := sql.NullString{}
@@ -659,7 +1041,7 @@ func (s *Storage) InsertVkMessages(ctx context.Context, msgs []models.VkMessage)
 		if msg.Metadata != nil {
 			metadataJSON, err := json.Marshal(msg.Metadata)
 			if err != nil {
-				return e.Wrap(fn, err)
+				return "", nil, err
 			}
 
 			metadataSQL = sql.NullString{
@@ -668,31 +1050,33 @@ func (s *Storage) InsertVkMessages(ctx context.Context, msgs []models.VkMessage)
 			}
 		}
 
+		editedAt := sql.NullTime{}
+
+		if !msg.EditedAt.IsZero() {
+			editedAt = sql.NullTime{
+				Valid: true,
+				Time:  msg.EditedAt,
+			}
+		}
+
 		sets = append(sets,
 			fmt.Sprintf(
-				"($%d, $%d, $%d, $%d, $%d)",
-				idx, idx+1, idx+2, idx+3, idx+4),
+				"($%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+				idx, idx+1, idx+2, idx+3, idx+4, idx+5, idx+6),
 		)
-		args = append(args, msg.MessageID, msg.GroupID, msg.Text, metadataSQL, msg.CreatedAt)
-		idx += 5
+		args = append(args, msg.MessageID, msg.GroupID, msg.Text, metadataSQL, msg.CreatedAt, editedAt, msg.Backfilled)
+		idx += 7
 	}
 
 	q += strings.Join(sets, ", ")
 
-	q += ` ON CONFLICT DO NOTHING`
-
-	_, err := s.db.ExecContext(ctx, q, args...)
-	if err != nil {
-		return e.Wrap(fn, err)
-	}
-
-	return nil
+	return q, args, nil
 }
 
 func (s *Storage) GetVkGroups(ctx context.Context) ([]models.VkGroup, error) {
 	const fn = "psql.GetVkGroups"
 
-	q := `SELECT * FROM vk_groups`
+	q := `SELECT id, name, domain FROM vk_groups`
 
 	rows, err := s.db.QueryContext(ctx, q)
 	if err != nil {
@@ -727,17 +1111,118 @@ func (s *Storage) GetVkGroups(ctx context.Context) ([]models.VkGroup, error) {
 	return groups, nil
 }
 
-func (s *Storage) DeleteVkGroup(ctx context.Context, vkDomain string) error {
-	const fn = "psql.DeleteVkGroup"
+func (s *Storage) GetVkGroupByID(ctx context.Context, groupID int) (models.VkGroup, error) {
+	const fn = "psql.GetVkGroupByID"
 
-	q := `DELETE FROM vk_groups WHERE domain = $1`
+	q := `SELECT id, name, domain FROM vk_groups WHERE id = $1`
 
-	res, err := s.db.ExecContext(ctx, q, vkDomain)
-	if err != nil {
-		return e.Wrap(fn, err)
+	var group models.VkGroup
+
+	if err := s.db.QueryRowContext(ctx, q, groupID).Scan(&group.ID, &group.Name, &group.Domain); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.VkGroup{}, storage.ErrNoRecordsFound
+		}
+
+		return models.VkGroup{}, e.Wrap(fn, err)
 	}
 
-	rows, err := res.RowsAffected()
+	return group, nil
+}
+
+// GetVkCursor Returns the id of the newest stored post of the group, 0 for a new group
+func (s *Storage) GetVkCursor(ctx context.Context, groupID int) (int, error) {
+	const fn = "psql.GetVkCursor"
+
+	q := `SELECT last_post_id FROM vk_groups WHERE id = $1`
+
+	var postID int
+
+	if err := s.db.QueryRowContext(ctx, q, groupID).Scan(&postID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, storage.ErrNoRecordsFound
+		}
+
+		return 0, e.Wrap(fn, err)
+	}
+
+	return postID, nil
+}
+
+// SetVkCursor The cursor never moves back
+func (s *Storage) SetVkCursor(ctx context.Context, groupID int, postID int) error {
+	const fn = "psql.SetVkCursor"
+
+	q := `UPDATE vk_groups SET last_post_id = GREATEST(last_post_id, $1) WHERE id = $2`
+
+	res, err := s.db.ExecContext(ctx, q, postID, groupID)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if rows == 0 {
+		return e.Wrap(fn, storage.ErrNoRecordsFound)
+	}
+
+	return nil
+}
+
+// GetVkInterval Returns zero durations when the interval was not set by the command
+func (s *Storage) GetVkInterval(ctx context.Context, groupID int) (time.Duration, time.Duration, error) {
+	const fn = "psql.GetVkInterval"
+
+	q := `SELECT COALESCE(min_interval, 0), COALESCE(max_interval, 0) FROM vk_groups WHERE id = $1`
+
+	var minSec, maxSec int64
+
+	if err := s.db.QueryRowContext(ctx, q, groupID).Scan(&minSec, &maxSec); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, 0, storage.ErrNoRecordsFound
+		}
+
+		return 0, 0, e.Wrap(fn, err)
+	}
+
+	return time.Duration(minSec) * time.Second, time.Duration(maxSec) * time.Second, nil
+}
+
+func (s *Storage) SetVkInterval(ctx context.Context, groupID int, min, max time.Duration) error {
+	const fn = "psql.SetVkInterval"
+
+	q := `UPDATE vk_groups SET min_interval = $1, max_interval = $2 WHERE id = $3`
+
+	res, err := s.db.ExecContext(ctx, q, int64(min.Seconds()), int64(max.Seconds()), groupID)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if rows == 0 {
+		return e.Wrap(fn, storage.ErrNoRecordsFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteVkGroup(ctx context.Context, vkDomain string) error {
+	const fn = "psql.DeleteVkGroup"
+
+	q := `DELETE FROM vk_groups WHERE domain = $1`
+
+	res, err := s.db.ExecContext(ctx, q, vkDomain)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	rows, err := res.RowsAffected()
 	if err != nil {
 		return e.Wrap(fn, err)
 	}
@@ -749,76 +1234,913 @@ func (s *Storage) DeleteVkGroup(ctx context.Context, vkDomain string) error {
 	return nil
 }
 
-func (s *Storage) AddNotifier(ctx context.Context, name string, buf uint) (<-chan *pq.Notification, error) {
-	const fn = "psql.AddNotifier"
+func (s *Storage) InsertRssFeed(ctx context.Context, feed models.RssFeed) (int64, error) {
+	const fn = "psql.InsertRssFeed"
+
+	q := `INSERT INTO rss_feeds (url, title) VALUES ($1, $2) RETURNING id`
+
+	var id int64
+
+	err := s.db.QueryRowContext(ctx, q, feed.Url, feed.Title).Scan(&id)
+	if err != nil {
+		return 0, e.Wrap(fn, err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) GetRssFeeds(ctx context.Context) ([]models.RssFeed, error) {
+	const fn = "psql.GetRssFeeds"
+
+	q := `SELECT id, url, title FROM rss_feeds`
 
-	_, err := s.db.ExecContext(ctx, fmt.Sprintf("LISTEN %s", name))
+	rows, err := s.db.QueryContext(ctx, q)
 	if err != nil {
 		return nil, e.Wrap(fn, err)
 	}
 
-	err = s.notifiers.listener.Listen(name)
+	defer rows.Close()
+
+	var feeds []models.RssFeed
+
+	for rows.Next() {
+		var feed models.RssFeed
+
+		err := rows.Scan(&feed.ID, &feed.Url, &feed.Title)
+		if err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		feeds = append(feeds, feed)
+	}
+
+	if len(feeds) == 0 {
+		return nil, storage.ErrNoRecordsFound
+	}
+
+	return feeds, nil
+}
+
+func (s *Storage) DeleteRssFeed(ctx context.Context, feedURL string) error {
+	const fn = "psql.DeleteRssFeed"
+
+	q := `DELETE FROM rss_feeds WHERE url = $1`
+
+	res, err := s.db.ExecContext(ctx, q, feedURL)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	rows, err := res.RowsAffected()
 	if err != nil {
-		if errors.Is(err, pq.ErrChannelAlreadyOpen) {
-			return nil, e.Wrap(fn, storage.ErrChannelAlreadyOpen)
+		return e.Wrap(fn, err)
+	}
+
+	if rows == 0 {
+		return storage.ErrNoRecordsFound
+	}
+
+	return nil
+}
+
+// InsertRssItems Items with an already stored guid are skipped
+func (s *Storage) InsertRssItems(ctx context.Context, items []models.RssItem) error {
+	const fn = "psql.InsertRssItems"
+
+	var args []interface{}
+	var sets []string
+	idx := 1
+
+	q := `INSERT INTO rss_items (guid, feed_id, title, link, text, author, metadata, created_at) VALUES `
+
+	for _, item := range items {
+		metadataSQL := sql.NullString{}
+
+		if item.Metadata != nil {
+			metadataJSON, err := json.Marshal(item.Metadata)
+			if err != nil {
+				return e.Wrap(fn, err)
+			}
+
+			metadataSQL = sql.NullString{
+				String: string(metadataJSON),
+				Valid:  true,
+			}
 		}
 
-		return nil, e.Wrap(fn, err)
+		sets = append(sets,
+			fmt.Sprintf(
+				"($%d, $%d, $%d, $%d, $%d, NULLIF($%d, ''), $%d, $%d)",
+				idx, idx+1, idx+2, idx+3, idx+4, idx+5, idx+6, idx+7),
+		)
+		args = append(args, item.GUID, item.FeedID, item.Title, item.Link, item.Text, item.Author, metadataSQL, item.CreatedAt)
+		idx += 8
+	}
+
+	q += strings.Join(sets, ", ")
+
+	q += `
+	ON CONFLICT (guid, feed_id) DO NOTHING
+	RETURNING feed_id, guid`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return e.Wrap(fn, err)
 	}
 
-	if buf == 0 {
-		buf = 10
+	defer tx.Rollback()
+
+	keys, err := insertKeys(ctx, tx, q, args)
+	if err != nil {
+		return e.Wrap(fn, err)
 	}
 
-	notifyChan := make(chan *pq.Notification, buf)
+	if err := addNews(ctx, tx, "rss", keys); err != nil {
+		return e.Wrap(fn, err)
+	}
 
-	s.notifiers.mu.Lock()
-	s.notifiers.m[name] = notifyChan
-	s.notifiers.mu.Unlock()
+	if err := s.commitNews(tx); err != nil {
+		return e.Wrap(fn, err)
+	}
 
-	return notifyChan, nil
+	return nil
 }
 
-func (s *Storage) GetNotifier(name string) (<-chan *pq.Notification, error) {
-	const fn = "psql.GetNotifier"
+func (s *Storage) GetFilterRules(ctx context.Context) ([]models.FilterRule, error) {
+	const fn = "psql.GetFilterRules"
 
-	s.notifiers.mu.RLock()
-	notifyCh, ok := s.notifiers.m[name]
-	if !ok {
-		return nil, e.Wrap(fn, storage.ErrChannelNotFound)
+	q := `SELECT id, scope, rule, value FROM filter_rules ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	var rules []models.FilterRule
+
+	for rows.Next() {
+		var rule models.FilterRule
+
+		err := rows.Scan(&rule.ID, &rule.Scope, &rule.Rule, &rule.Value)
+		if err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	if len(rules) == 0 {
+		return nil, storage.ErrNoRecordsFound
+	}
+
+	return rules, nil
+}
+
+func (s *Storage) InsertFilterRule(ctx context.Context, rule models.FilterRule) (int64, error) {
+	const fn = "psql.InsertFilterRule"
+
+	q := `INSERT INTO filter_rules (scope, rule, value) VALUES ($1, $2, $3)
+		ON CONFLICT (scope, rule, value) DO UPDATE SET value = EXCLUDED.value
+		RETURNING id`
+
+	var id int64
+
+	err := s.db.QueryRowContext(ctx, q, rule.Scope, rule.Rule, rule.Value).Scan(&id)
+	if err != nil {
+		return 0, e.Wrap(fn, err)
 	}
-	s.notifiers.mu.RUnlock()
 
-	return notifyCh, nil
+	return id, nil
 }
 
-func (s *Storage) RemoveNotifier(ctx context.Context, name string) error {
-	const fn = "psql.RemoveNotifier"
+func (s *Storage) DeleteFilterRule(ctx context.Context, id int64) error {
+	const fn = "psql.DeleteFilterRule"
+
+	q := `DELETE FROM filter_rules WHERE id = $1`
 
-	_, err := s.db.ExecContext(ctx, fmt.Sprintf("UNLISTEN %s", name))
+	res, err := s.db.ExecContext(ctx, q, id)
 	if err != nil {
 		return e.Wrap(fn, err)
 	}
 
-	s.notifiers.mu.RLock()
-	_, ok := s.notifiers.m[name]
-	if !ok {
-		return e.Wrap(fn, storage.ErrChannelNotFound)
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if rows == 0 {
+		return storage.ErrNoRecordsFound
+	}
+
+	return nil
+}
+
+func (s *Storage) TgGroupIsModerated(ctx context.Context, groupID int64) (bool, error) {
+	const fn = "psql.TgGroupIsModerated"
+
+	q := `SELECT moderated FROM tg_groups WHERE id = $1`
+
+	var moderated bool
+
+	err := s.db.QueryRowContext(ctx, q, groupID).Scan(&moderated)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, storage.ErrNoRecordsFound
+		}
+
+		return false, e.Wrap(fn, err)
 	}
-	s.notifiers.mu.RUnlock()
 
-	err = s.notifiers.listener.Unlisten(name)
+	return moderated, nil
+}
+
+func (s *Storage) TgChannelIsModerated(ctx context.Context, channelID int64) (bool, error) {
+	const fn = "psql.TgChannelIsModerated"
+
+	q := `SELECT moderated FROM tg_channels WHERE id = $1`
+
+	var moderated bool
+
+	err := s.db.QueryRowContext(ctx, q, channelID).Scan(&moderated)
 	if err != nil {
-		if errors.Is(err, pq.ErrChannelNotOpen) {
-			return e.Wrap(fn, storage.ErrChannelNotFound)
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, storage.ErrNoRecordsFound
 		}
 
+		return false, e.Wrap(fn, err)
+	}
+
+	return moderated, nil
+}
+
+// SetTgSourceModerated kind is models.KindTgGroup or models.KindTgChannel
+func (s *Storage) SetTgSourceModerated(ctx context.Context, kind string, chatID int64, moderated bool) error {
+	const fn = "psql.SetTgSourceModerated"
+
+	table, _, err := tgMessageTables(kind)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	q := fmt.Sprintf(`UPDATE %s SET moderated = $1 WHERE id = $2`, table)
+
+	res, err := s.db.ExecContext(ctx, q, moderated, chatID)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
 		return e.Wrap(fn, err)
 	}
 
-	s.notifiers.mu.Lock()
-	delete(s.notifiers.m, name)
-	s.notifiers.mu.Unlock()
+	if rows == 0 {
+		return storage.ErrNoRecordsFound
+	}
 
 	return nil
 }
+
+// GetModerators Returns telegram ids of all users
+func (s *Storage) GetModerators(ctx context.Context) ([]int64, error) {
+	const fn = "psql.GetModerators"
+
+	q := `SELECT id FROM users`
+
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	var ids []int64
+
+	for rows.Next() {
+		var id int64
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	if len(ids) == 0 {
+		return nil, storage.ErrNoRecordsFound
+	}
+
+	return ids, nil
+}
+
+// SetTgMessageStatus Changes only pending messages, approved ones are published to the feed
+func (s *Storage) SetTgMessageStatus(ctx context.Context, ref models.TgMessageRef, status string) error {
+	const fn = "psql.SetTgMessageStatus"
+
+	_, table, err := tgMessageTables(ref.Kind)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	q := fmt.Sprintf(`UPDATE %s SET status = $1 WHERE msg_id = $2 AND %s = $3 AND status = 'pending'`,
+		table, tgChatColumn(ref.Kind))
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, q, status, ref.MessageID, ref.ChatID)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if rows == 0 {
+		return storage.ErrNoRecordsFound
+	}
+
+	if status == models.MsgStatusApproved {
+		if err := addNews(ctx, tx, ref.Kind, []sourceKey{tgSourceKey(ref)}); err != nil {
+			return e.Wrap(fn, err)
+		}
+	}
+
+	if err := s.commitNews(tx); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) UpdatePendingTgMessageText(ctx context.Context, ref models.TgMessageRef, text string, entities []models.Entity) error {
+	const fn = "psql.UpdatePendingTgMessageText"
+
+	_, table, err := tgMessageTables(ref.Kind)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	entitiesSQL, err := entitiesJSON(entities)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	q := fmt.Sprintf(`UPDATE %s SET text = $1, entities = $2 WHERE msg_id = $3 AND %s = $4 AND status = 'pending'`,
+		table, tgChatColumn(ref.Kind))
+
+	res, err := s.db.ExecContext(ctx, q, text, entitiesSQL, ref.MessageID, ref.ChatID)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if rows == 0 {
+		return storage.ErrNoRecordsFound
+	}
+
+	return nil
+}
+
+var ErrUnknownTgKind = errors.New("unknown telegram source kind")
+
+// tgMessageTables returns the source and messages tables of a telegram source kind
+func tgMessageTables(kind string) (string, string, error) {
+	switch kind {
+	case models.KindTgGroup:
+		return "tg_groups", "tg_group_messages", nil
+
+	case models.KindTgChannel:
+		return "tg_channels", "tg_channel_messages", nil
+
+	default:
+		return "", "", ErrUnknownTgKind
+	}
+}
+
+func tgSourceKey(ref models.TgMessageRef) sourceKey {
+	return sourceKey{sourceID: ref.ChatID, msgID: strconv.Itoa(ref.MessageID)}
+}
+
+func tgChatColumn(kind string) string {
+	if kind == models.KindTgGroup {
+		return "group_id"
+	}
+
+	return "channel_id"
+}
+
+// UpdateTgMessageText Approved messages update their news in the feed
+func (s *Storage) UpdateTgMessageText(ctx context.Context, ref models.TgMessageRef, text string, entities []models.Entity) error {
+	const fn = "psql.UpdateTgMessageText"
+
+	_, table, err := tgMessageTables(ref.Kind)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	entitiesSQL, err := entitiesJSON(entities)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	q := fmt.Sprintf(`UPDATE %s SET text = $1, entities = $2::jsonb
+	WHERE msg_id = $3 AND %s = $4 AND (text <> $1 OR entities IS DISTINCT FROM $2::jsonb)
+	RETURNING status`,
+		table, tgChatColumn(ref.Kind))
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	defer tx.Rollback()
+
+	var status string
+
+	err = tx.QueryRowContext(ctx, q, text, entitiesSQL, ref.MessageID, ref.ChatID).Scan(&status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrNoRecordsFound
+		}
+
+		return e.Wrap(fn, err)
+	}
+
+	if status == models.MsgStatusApproved {
+		if err := updateNews(ctx, tx, ref.Kind, []sourceKey{tgSourceKey(ref)}); err != nil {
+			return e.Wrap(fn, err)
+		}
+	}
+
+	if err := s.commitNews(tx); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+func updateWebMessageBySourceRef(ctx context.Context, q querier, sourceRef, text string, metadata []models.MetaPair, entities []models.Entity) (models.WebMessage, error) {
+	const fn = "psql.updateWebMessageBySourceRef"
+
+	metadataSQL := sql.NullString{}
+
+	if metadata != nil {
+		metadataJSON, err := json.Marshal(metadata)
+		if err != nil {
+			return models.WebMessage{}, e.Wrap(fn, err)
+		}
+
+		metadataSQL = sql.NullString{
+			String: string(metadataJSON),
+			Valid:  true,
+		}
+	}
+
+	entitiesSQL, err := entitiesJSON(entities)
+	if err != nil {
+		return models.WebMessage{}, e.Wrap(fn, err)
+	}
+
+	query := `UPDATE web_messages SET text = $1, metadata = $2, entities = $3, updated_at = $5 WHERE source_ref = $4 RETURNING ` + webMessageColumns
+
+	msg, err := scanWebMessage(q.QueryRowContext(ctx, query, text, metadataSQL, entitiesSQL, sourceRef, time.Now().UTC()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.WebMessage{}, storage.ErrNoRecordsFound
+		}
+
+		return models.WebMessage{}, e.Wrap(fn, err)
+	}
+
+	return msg, nil
+}
+
+// DeleteWebMessage Removes the message from the feed and writes a models.OutboxDeleted entry
+func (s *Storage) DeleteWebMessage(ctx context.Context, id int64) error {
+	const fn = "psql.DeleteWebMessage"
+
+	q := `
+	WITH news AS (
+		DELETE FROM web_messages WHERE id = $1 RETURNING id
+	), state AS (
+		UPDATE web_messages_state SET deleted_at = $3 WHERE EXISTS (SELECT 1 FROM news)
+	)
+	INSERT INTO news_outbox (event, news_id) SELECT $2, id FROM news`
+
+	res, err := s.db.ExecContext(ctx, q, id, models.OutboxDeleted, time.Now().UTC())
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if rows == 0 {
+		return storage.ErrNoRecordsFound
+	}
+
+	s.outbox.Wake()
+
+	return nil
+}
+
+// GetRetentionSources Returns all sources the retention policies may apply to
+func (s *Storage) GetRetentionSources(ctx context.Context) ([]models.RetentionSource, error) {
+	const fn = "psql.GetRetentionSources"
+
+	q := `
+	SELECT 'tg_group', id, id::text FROM tg_groups
+	UNION ALL
+	SELECT 'tg_channel', id, id::text FROM tg_channels
+	UNION ALL
+	SELECT 'vk', id, domain FROM vk_groups
+	UNION ALL
+	SELECT 'rss', id, url FROM rss_feeds`
+
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	var res []models.RetentionSource
+
+	for rows.Next() {
+		var src models.RetentionSource
+
+		if err := rows.Scan(&src.Kind, &src.ID, &src.Key); err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		res = append(res, src)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	if len(res) == 0 {
+		return nil, storage.ErrNoRecordsFound
+	}
+
+	return res, nil
+}
+
+// messageTable describes the message table of a source kind
+type messageTable struct {
+	name      string
+	sourceCol string
+	msgCol    string
+	msgType   string
+	// where skips messages the retention must not touch
+	where string
+}
+
+var messageTables = map[string]messageTable{
+	models.KindTgGroup:   {"tg_group_messages", "group_id", "msg_id", "bigint", "AND t.status <> 'pending'"},
+	models.KindTgChannel: {"tg_channel_messages", "channel_id", "msg_id", "bigint", "AND t.status <> 'pending'"},
+	"vk":                 {"vk_messages", "group_id", "msg_id", "bigint", ""},
+	"rss":                {"rss_items", "feed_id", "guid", "text", ""},
+}
+
+// GetExpiredMessages Returns up to limit oldest messages of the source created before the time
+// or not among the newest keep messages, zero before and keep are ignored
+func (s *Storage) GetExpiredMessages(ctx context.Context, src models.RetentionSource, before time.Time, keep, limit int) ([]models.ExpiredMessage, error) {
+	const fn = "psql.GetExpiredMessages"
+
+	table, ok := messageTables[src.Kind]
+	if !ok {
+		return nil, e.Wrap(fn, fmt.Errorf("unknown source kind %q", src.Kind))
+	}
+
+	beforeSQL := sql.NullTime{
+		Time:  before,
+		Valid: !before.IsZero(),
+	}
+
+	q := fmt.Sprintf(`
+	SELECT m.%[3]s::text, to_jsonb(m) - 'rn',
+	       CASE WHEN w.id IS NULL THEN NULL ELSE to_jsonb(w) - 'search_vector' END
+	FROM (
+		SELECT t.*, ROW_NUMBER() OVER (ORDER BY t.created_at DESC) AS rn
+		FROM %[1]s t
+		WHERE t.%[2]s = $1 %[4]s
+	) m
+	LEFT JOIN web_messages w ON w.source_ref = $2 || m.%[3]s::text
+	WHERE ($3 > 0 AND m.rn > $3) OR m.created_at < $4
+	ORDER BY m.created_at
+	LIMIT $5`, table.name, table.sourceCol, table.msgCol, table.where)
+
+	refPrefix := fmt.Sprintf("%s:%d:", src.Kind, src.ID)
+
+	rows, err := s.db.QueryContext(ctx, q, src.ID, refPrefix, keep, beforeSQL, limit)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	var res []models.ExpiredMessage
+
+	for rows.Next() {
+		var (
+			msg  models.ExpiredMessage
+			news []byte
+		)
+
+		if err := rows.Scan(&msg.MsgID, &msg.Message, &news); err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		msg.Kind = src.Kind
+		msg.SourceID = src.ID
+		msg.News = news
+
+		res = append(res, msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	if len(res) == 0 {
+		return nil, storage.ErrNoRecordsFound
+	}
+
+	return res, nil
+}
+
+// DeleteExpiredMessages Deletes the source messages with the news made from them,
+// models.OutboxDeleted entries are written for the news
+func (s *Storage) DeleteExpiredMessages(ctx context.Context, src models.RetentionSource, msgIDs []string) error {
+	const fn = "psql.DeleteExpiredMessages"
+
+	table, ok := messageTables[src.Kind]
+	if !ok {
+		return e.Wrap(fn, fmt.Errorf("unknown source kind %q", src.Kind))
+	}
+
+	refs := make([]string, 0, len(msgIDs))
+
+	for _, id := range msgIDs {
+		refs = append(refs, fmt.Sprintf("%s:%d:%s", src.Kind, src.ID, id))
+	}
+
+	q := fmt.Sprintf(`
+	WITH news AS (
+		DELETE FROM web_messages WHERE source_ref = ANY($3) RETURNING id
+	), msgs AS (
+		DELETE FROM %[1]s WHERE %[2]s = $1 AND %[3]s = ANY($2::%[4]s[])
+	), state AS (
+		UPDATE web_messages_state SET deleted_at = $5 WHERE EXISTS (SELECT 1 FROM news)
+	)
+	INSERT INTO news_outbox (event, news_id) SELECT $4, id FROM news`,
+		table.name, table.sourceCol, table.msgCol, table.msgType)
+
+	res, err := s.db.ExecContext(ctx, q, src.ID, pq.StringArray(msgIDs), pq.StringArray(refs), models.OutboxDeleted, time.Now().UTC())
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if rows > 0 {
+		s.outbox.Wake()
+	}
+
+	return nil
+}
+
+const mediaObjectColumns = `sha256, name, url, COALESCE(content_type, ''), size,
+	COALESCE(width, 0), COALESCE(height, 0), COALESCE(blurhash, ''), variants`
+
+func scanMediaObject(row scanner) (models.MediaObject, error) {
+	var (
+		obj      models.MediaObject
+		variants []byte
+	)
+
+	err := row.Scan(&obj.SHA256, &obj.Name, &obj.Url, &obj.ContentType, &obj.Size,
+		&obj.Width, &obj.Height, &obj.Blurhash, &variants,
+	)
+	if err != nil {
+		return models.MediaObject{}, err
+	}
+
+	if variants != nil {
+		if err := json.Unmarshal(variants, &obj.Variants); err != nil {
+			return models.MediaObject{}, err
+		}
+	}
+
+	return obj, nil
+}
+
+// AcquireTgMediaFile Returns the stored file of the Telegram file and takes a reference to it
+func (s *Storage) AcquireTgMediaFile(ctx context.Context, fileUniqueID string) (models.MediaObject, error) {
+	const fn = "psql.AcquireTgMediaFile"
+
+	q := `
+	UPDATE media_objects m SET refcount = m.refcount + 1
+	FROM tg_media_files f
+	WHERE f.file_unique_id = $1 AND m.sha256 = f.sha256
+	RETURNING m.sha256, m.name, m.url, COALESCE(m.content_type, ''), m.size,
+		COALESCE(m.width, 0), COALESCE(m.height, 0), COALESCE(m.blurhash, ''), m.variants`
+
+	obj, err := scanMediaObject(s.db.QueryRowContext(ctx, q, fileUniqueID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MediaObject{}, storage.ErrNoRecordsFound
+		}
+
+		return models.MediaObject{}, e.Wrap(fn, err)
+	}
+
+	return obj, nil
+}
+
+// AcquireMediaObject Returns the stored file with the content hash and takes a reference to it,
+// a non-empty fileUniqueID is remembered for AcquireTgMediaFile
+func (s *Storage) AcquireMediaObject(ctx context.Context, sha256, fileUniqueID string) (models.MediaObject, error) {
+	const fn = "psql.AcquireMediaObject"
+
+	q := `
+	WITH obj AS (
+		UPDATE media_objects SET refcount = refcount + 1 WHERE sha256 = $1
+		RETURNING ` + mediaObjectColumns + `
+	), file AS (
+		INSERT INTO tg_media_files (file_unique_id, sha256)
+		SELECT $2, sha256 FROM obj WHERE $2 <> ''
+		ON CONFLICT (file_unique_id) DO NOTHING
+	)
+	SELECT * FROM obj`
+
+	obj, err := scanMediaObject(s.db.QueryRowContext(ctx, q, sha256, fileUniqueID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MediaObject{}, storage.ErrNoRecordsFound
+		}
+
+		return models.MediaObject{}, e.Wrap(fn, err)
+	}
+
+	return obj, nil
+}
+
+// InsertMediaObject Stores the uploaded file with one reference, the file uploaded
+// at the same time by someone else gets one more reference instead
+func (s *Storage) InsertMediaObject(ctx context.Context, obj models.MediaObject, fileUniqueID string) (models.MediaObject, error) {
+	const fn = "psql.InsertMediaObject"
+
+	contentTypeSQL := sql.NullString{
+		String: obj.ContentType,
+		Valid:  obj.ContentType != "",
+	}
+
+	variantsSQL := sql.NullString{}
+
+	if obj.Variants != nil {
+		variantsJSON, err := json.Marshal(obj.Variants)
+		if err != nil {
+			return models.MediaObject{}, e.Wrap(fn, err)
+		}
+
+		variantsSQL = sql.NullString{
+			String: string(variantsJSON),
+			Valid:  true,
+		}
+	}
+
+	q := `
+	WITH obj AS (
+		INSERT INTO media_objects (sha256, name, url, content_type, size, width, height, blurhash, variants, refcount)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($7, 0), NULLIF($8, 0), NULLIF($9, ''), $10, 1)
+		ON CONFLICT (sha256) DO UPDATE SET refcount = media_objects.refcount + 1
+		RETURNING ` + mediaObjectColumns + `
+	), file AS (
+		INSERT INTO tg_media_files (file_unique_id, sha256)
+		SELECT $6, sha256 FROM obj WHERE $6 <> ''
+		ON CONFLICT (file_unique_id) DO NOTHING
+	)
+	SELECT * FROM obj`
+
+	res, err := scanMediaObject(s.db.QueryRowContext(ctx, q,
+		obj.SHA256, obj.Name, obj.Url, contentTypeSQL, obj.Size, fileUniqueID,
+		obj.Width, obj.Height, obj.Blurhash, variantsSQL,
+	))
+	if err != nil {
+		return models.MediaObject{}, e.Wrap(fn, err)
+	}
+
+	return res, nil
+}
+
+// ReleaseMediaObjects Drops one reference per name, returns the names of files nobody references anymore
+// with their resized copies.
+// Names unknown to media_objects are returned as is, they were stored before the deduplication
+func (s *Storage) ReleaseMediaObjects(ctx context.Context, names []string) ([]string, error) {
+	const fn = "psql.ReleaseMediaObjects"
+
+	q := `
+	UPDATE media_objects m SET refcount = m.refcount - r.n
+	FROM (SELECT name, count(*) AS n FROM unnest($1::text[]) AS name GROUP BY name) r
+	WHERE m.name = r.name
+	RETURNING m.name`
+
+	rows, err := s.db.QueryContext(ctx, q, pq.StringArray(names))
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	tracked := make(map[string]struct{})
+
+	for rows.Next() {
+		var name string
+
+		if err := rows.Scan(&name); err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		tracked[name] = struct{}{}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	var res []string
+
+	for _, name := range names {
+		if _, ok := tracked[name]; !ok && !slices.Contains(res, name) {
+			res = append(res, name)
+		}
+	}
+
+	if len(tracked) == 0 {
+		return res, nil
+	}
+
+	// A file acquired again since the update keeps its row
+	q = `DELETE FROM media_objects WHERE name = ANY($1) AND refcount <= 0 RETURNING name, variants`
+
+	rows, err = s.db.QueryContext(ctx, q, pq.StringArray(slices.Collect(maps.Keys(tracked))))
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			name     string
+			variants []byte
+		)
+
+		if err := rows.Scan(&name, &variants); err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		res = append(res, name)
+
+		if variants == nil {
+			continue
+		}
+
+		var copies []models.MediaVariant
+
+		if err := json.Unmarshal(variants, &copies); err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		for _, v := range copies {
+			res = append(res, v.Name)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,109 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"project/internal/config"
+	"project/pkg/e"
+	"strings"
+
+	"modernc.org/sqlite"
+)
+
+const defaultPath = "data/news.db"
+
+type Storage struct {
+	db     *sql.DB
+	outbox Outbox
+	log    *slog.Logger
+}
+
+// Outbox is woken after the feed changes are committed to news_outbox
+type Outbox interface {
+	Wake()
+}
+
+func init() {
+	// SQLite lower() knows ASCII only, group names are mostly cyrillic
+	sqlite.MustRegisterDeterministicScalarFunction("unicode_lower", 1,
+		func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			switch v := args[0].(type) {
+			case string:
+				return strings.ToLower(v), nil
+			case nil:
+				return nil, nil
+			default:
+				return strings.ToLower(fmt.Sprint(v)), nil
+			}
+		},
+	)
+}
+
+func New(ctx context.Context, cfg *config.DB, migratePath string, ob Outbox, log *slog.Logger) (*Storage, error) {
+	const fn = "sqlite.New"
+
+	mg, err := NewMigrator(ctx, cfg, migratePath, log)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer mg.Close()
+
+	if err := mg.Up(); err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	db, err := open(ctx, cfg)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	log.Info("[OK] sqlite successfully opened", slog.String("path", dbPath(cfg)))
+
+	return &Storage{
+		db:     db,
+		outbox: ob,
+		log:    log,
+	}, nil
+}
+
+// open allows one connection: SQLite has a single writer and waiting for the lock
+// inside the pool is cheaper than busy errors
+func open(ctx context.Context, cfg *config.DB) (*sql.DB, error) {
+	path := dbPath(cfg)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	// Times are stored as "2006-01-02 15:04:05.999999999-07:00" in UTC, so they compare as strings
+	dsn := "file:" + path +
+		"?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_time_format=sqlite"
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(1)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func dbPath(cfg *config.DB) string {
+	if cfg.Path == "" {
+		return defaultPath
+	}
+
+	return cfg.Path
+}
@@ -0,0 +1,36 @@
+package sqlite
+
+import (
+	"context"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"log/slog"
+	"project/internal/config"
+	"project/internal/storage"
+	"project/pkg/e"
+)
+
+// NewMigrator migratePath holds the SQLite migrations, they differ from the Postgres ones
+func NewMigrator(ctx context.Context, cfg *config.DB, migratePath string, log *slog.Logger) (*storage.Migrator, error) {
+	const fn = "sqlite.NewMigrator"
+
+	db, err := open(ctx, cfg)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	migrationDriver, err := sqlite.WithInstance(db, &sqlite.Config{})
+	if err != nil {
+		db.Close()
+		return nil, e.Wrap(fn, err)
+	}
+
+	m, err := migrate.NewWithDatabaseInstance(migratePath, "sqlite", migrationDriver)
+	if err != nil {
+		db.Close()
+		return nil, e.Wrap(fn, err)
+	}
+
+	return storage.NewMigrator(db, m, log), nil
+}
@@ -0,0 +1,286 @@
+package sqlite
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"project/internal/models"
+	"project/internal/storage"
+	"project/pkg/e"
+	"slices"
+	"strings"
+	"time"
+)
+
+// sourceKey is a stored source message, msgID is the guid for RSS items
+type sourceKey struct {
+	sourceID int64
+	msgID    any
+}
+
+// newsSelects select storage.NewsColumns of the source messages in the feed,
+// %s is the list of (source id, message id) rows
+var newsSelects = map[string]string{
+	models.KindTgGroup: `
+	SELECT 'tg_group:' || m.group_id || ':' || m.msg_id, 'Группа: ' || g.name, '', '',
+		'От: ' || m.username || char(10) || char(10), m.text, m.metadata, m.entities, m.created_at, 'tg', 0
+	FROM tg_group_messages m JOIN tg_groups g ON g.id = m.group_id
+	WHERE m.status = 'approved' AND (m.group_id, m.msg_id) IN (VALUES %s)`,
+
+	models.KindTgChannel: `
+	SELECT 'tg_channel:' || m.channel_id || ':' || m.msg_id, 'Канал: ' || g.name, '', '',
+		'', m.text, m.metadata, m.entities, m.created_at, 'tg', 0
+	FROM tg_channel_messages m JOIN tg_channels g ON g.id = m.channel_id
+	WHERE m.status = 'approved' AND (m.channel_id, m.msg_id) IN (VALUES %s)`,
+
+	"vk": `
+	SELECT 'vk:' || m.group_id || ':' || m.msg_id, g.name, '', '',
+		'', m.text, m.metadata, NULL, m.created_at, 'vk', m.backfilled
+	FROM vk_messages m JOIN vk_groups g ON g.id = m.group_id
+	WHERE (m.group_id, m.msg_id) IN (VALUES %s)`,
+
+	"rss": `
+	SELECT 'rss:' || m.feed_id || ':' || m.guid, f.title, m.title, m.link,
+		'', m.text, m.metadata, NULL, m.created_at, 'rss', 0
+	FROM rss_items m JOIN rss_feeds f ON f.id = m.feed_id
+	WHERE (m.feed_id, m.guid) IN (VALUES %s)`,
+}
+
+// querier is *sql.DB or *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// addNews adds the news of the stored messages to the feed and writes models.OutboxPublished entries,
+// tx is the transaction that stored the messages
+func addNews(ctx context.Context, tx *sql.Tx, kind string, keys []sourceKey) error {
+	news, err := selectNews(ctx, tx, kind, keys)
+	if err != nil || len(news) == 0 {
+		return err
+	}
+
+	ids, err := insertWebMessages(ctx, tx, news)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]models.OutboxEntry, 0, len(news))
+
+	for i := range news {
+		news[i].ID = ids[i]
+		entries = append(entries, models.OutboxEntry{Event: models.OutboxPublished, NewsID: ids[i], News: &news[i]})
+	}
+
+	return writeOutbox(ctx, tx, entries)
+}
+
+// updateNews replaces the news of the edited messages and writes models.OutboxUpdated entries,
+// messages not in the feed are skipped
+func updateNews(ctx context.Context, tx *sql.Tx, kind string, keys []sourceKey) error {
+	news, err := selectNews(ctx, tx, kind, keys)
+	if err != nil {
+		return err
+	}
+
+	var entries []models.OutboxEntry
+
+	for _, msg := range news {
+		upd, err := updateWebMessageBySourceRef(ctx, tx, msg.SourceRef, msg.Text, msg.Metadata, msg.Entities)
+		if err != nil {
+			if errors.Is(err, storage.ErrNoRecordsFound) {
+				continue
+			}
+
+			return err
+		}
+
+		entries = append(entries, models.OutboxEntry{Event: models.OutboxUpdated, NewsID: upd.ID, News: &upd})
+	}
+
+	return writeOutbox(ctx, tx, entries)
+}
+
+// deletedNews writes models.OutboxDeleted entries of the news removed from the feed
+// and moves the feed deletion time
+func deletedNews(ctx context.Context, tx *sql.Tx, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE web_messages_state SET deleted_at = ?`, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	entries := make([]models.OutboxEntry, 0, len(ids))
+
+	for _, id := range ids {
+		entries = append(entries, models.OutboxEntry{Event: models.OutboxDeleted, NewsID: id})
+	}
+
+	return writeOutbox(ctx, tx, entries)
+}
+
+// commitNews commits the feed changes with their outbox entries and wakes the relay
+func (s *Storage) commitNews(tx *sql.Tx) error {
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.outbox.Wake()
+
+	return nil
+}
+
+func writeOutbox(ctx context.Context, q querier, entries []models.OutboxEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, 3*len(entries))
+
+	for _, entry := range entries {
+		newsSQL := sql.NullString{}
+
+		if entry.News != nil {
+			newsJSON, err := json.Marshal(entry.News)
+			if err != nil {
+				return err
+			}
+
+			newsSQL = sql.NullString{
+				String: string(newsJSON),
+				Valid:  true,
+			}
+		}
+
+		args = append(args, entry.Event, entry.NewsID, newsSQL)
+	}
+
+	_, err := q.ExecContext(ctx, `INSERT INTO news_outbox (event, news_id, news) VALUES `+
+		strings.Repeat(", (?, ?, ?)", len(entries))[2:], args...)
+
+	return err
+}
+
+// ClaimOutbox Returns the oldest entries not claimed by another relay, they are claimed for the lease
+func (s *Storage) ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEntry, error) {
+	const fn = "sqlite.ClaimOutbox"
+
+	now := time.Now().UTC()
+
+	q := `
+	UPDATE news_outbox SET claimed_until = ?
+	WHERE id IN (
+		SELECT id FROM news_outbox
+		WHERE claimed_until IS NULL OR claimed_until < ?
+		ORDER BY id
+		LIMIT ?
+	)
+	RETURNING id, event, news_id, news`
+
+	rows, err := s.db.QueryContext(ctx, q, now.Add(lease), now, limit)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	var entries []models.OutboxEntry
+
+	for rows.Next() {
+		var (
+			entry models.OutboxEntry
+			news  sql.NullString
+		)
+
+		if err := rows.Scan(&entry.ID, &entry.Event, &entry.NewsID, &news); err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		if news.Valid {
+			if err := json.Unmarshal([]byte(news.String), &entry.News); err != nil {
+				return nil, e.Wrap(fn, err)
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	// RETURNING keeps no order
+	slices.SortFunc(entries, func(a, b models.OutboxEntry) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return entries, nil
+}
+
+// DeleteOutbox Removes the delivered entries
+func (s *Storage) DeleteOutbox(ctx context.Context, ids []int64) error {
+	const fn = "sqlite.DeleteOutbox"
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, len(ids))
+
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	q := `DELETE FROM news_outbox WHERE id IN (` + placeholders(len(ids)) + `)`
+
+	if _, err := s.db.ExecContext(ctx, q, args...); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+func selectNews(ctx context.Context, q querier, kind string, keys []sourceKey) ([]models.WebMessage, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	query, ok := newsSelects[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown source kind %q", kind)
+	}
+
+	rows := make([]string, 0, len(keys))
+	args := make([]any, 0, 2*len(keys))
+
+	for _, key := range keys {
+		rows = append(rows, "(?, ?)")
+		args = append(args, key.sourceID, key.msgID)
+	}
+
+	res, err := q.QueryContext(ctx, fmt.Sprintf(query, strings.Join(rows, ", ")), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Close()
+
+	var news []models.WebMessage
+
+	for res.Next() {
+		msg, err := storage.ScanNews(res)
+		if err != nil {
+			return nil, err
+		}
+
+		news = append(news, msg)
+	}
+
+	return news, res.Err()
+}
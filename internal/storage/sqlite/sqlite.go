@@ -0,0 +1,2080 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"project/internal/models"
+	"project/internal/storage"
+	"project/pkg/e"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+func (s *Storage) GetUsersRole() (map[string]string, error) {
+	const fn = "sqlite.GetUsersRole"
+
+	q := `
+	SELECT u.id, r.name
+	FROM users u
+	LEFT JOIN roles r ON r.id = u.role_id`
+
+	rows, err := s.db.Query(q)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	res := map[string]string{}
+
+	for rows.Next() {
+		var (
+			userID int64
+			role   string
+		)
+
+		err := rows.Scan(&userID, &role)
+		if err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		res[strconv.FormatInt(userID, 10)] = role
+	}
+
+	if len(res) == 0 {
+		return nil, storage.ErrNoRecordsFound
+	}
+
+	return res, nil
+}
+
+func (s *Storage) GetRoleIDs(ctx context.Context) ([]models.Role, error) {
+	const fn = "sqlite.GetRoleIDs"
+
+	q := `SELECT id, name FROM roles`
+
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	var roles []models.Role
+
+	for rows.Next() {
+		var role models.Role
+
+		err := rows.Scan(&role.RoleID, &role.RoleName)
+		if err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		roles = append(roles, role)
+	}
+
+	return roles, nil
+}
+
+func (s *Storage) GetUserRole(ctx context.Context, userID int64) (string, error) {
+	const fn = "sqlite.GetUserRole"
+
+	q := `
+	SELECT r.name
+	FROM users u
+	LEFT JOIN roles r ON r.id = u.role_id
+	WHERE u.id = ?`
+
+	var role string
+
+	err := s.db.QueryRowContext(ctx, q, userID).Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", storage.ErrNoRecordsFound
+		}
+
+		return "", e.Wrap(fn, err)
+	}
+
+	return role, nil
+}
+
+func (s *Storage) GetUserWithUsername(ctx context.Context, username string) (models.User, error) {
+	const fn = "sqlite.GetUserWithUsername"
+
+	q := `SELECT id, username, first_name, last_name, role_id FROM users WHERE username = ?`
+
+	var u models.User
+
+	err := s.db.QueryRowContext(ctx, q, username).Scan(&u.UserID, &u.Username, &u.FirstName, &u.LastName, &u.RoleID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, storage.ErrNoRecordsFound
+		}
+
+		return models.User{}, e.Wrap(fn, err)
+	}
+
+	return u, nil
+}
+
+// InsertUsers If the user already exists, the role will not change
+func (s *Storage) InsertUsers(ctx context.Context, users []models.User) error {
+	const fn = "sqlite.InsertUsers"
+
+	if len(users) == 0 {
+		return nil
+	}
+
+	var args []any
+
+	for _, user := range users {
+		args = append(args, user.UserID, user.Username, user.FirstName, user.LastName, user.RoleID)
+	}
+
+	q := `INSERT INTO users (id, username, first_name, last_name, role_id) VALUES ` + valueRows(len(users), 5) + `
+	ON CONFLICT (id)
+	DO UPDATE SET
+		username = excluded.username,
+		first_name = excluded.first_name,
+		last_name = excluded.last_name`
+
+	_, err := s.db.ExecContext(ctx, q, args...)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) CreateTgChannel(ctx context.Context, group models.TgChannel) error {
+	const fn = "sqlite.CreateTgChannel"
+
+	q := `INSERT INTO tg_channels (id, name, description) VALUES (?, ?, ?)
+	ON CONFLICT (id)
+	DO UPDATE SET
+		name = excluded.name,
+		description = excluded.description`
+
+	_, err := s.db.ExecContext(ctx, q, group.ChannelID, group.Name, group.Description)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) CreateTgGroup(ctx context.Context, group models.TgGroup) error {
+	const fn = "sqlite.CreateTgGroup"
+
+	q := `INSERT INTO tg_groups (id, name, description) VALUES (?, ?, ?)
+	ON CONFLICT (id)
+	DO UPDATE SET
+		name = excluded.name,
+		description = excluded.description`
+
+	_, err := s.db.ExecContext(ctx, q, group.GroupID, group.Name, group.Description)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) UpdateTgGroup(ctx context.Context, groupID int64, group models.TgGroup) error {
+	const fn = "sqlite.UpdateTgGroup"
+
+	q := `UPDATE tg_groups SET id = ?, name = ?, description = ? WHERE id = ?`
+
+	res, err := s.db.ExecContext(ctx, q, group.GroupID, group.Name, group.Description, groupID)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return affected(fn, res, e.Wrap(fn, storage.ErrNoRecordsFound))
+}
+
+func (s *Storage) TgGroupIsExists(ctx context.Context, groupID int64) (bool, error) {
+	const fn = "sqlite.TgGroupIsExists"
+
+	q := `SELECT EXISTS (SELECT 1 FROM tg_groups WHERE id = ?)`
+
+	var exists bool
+
+	if err := s.db.QueryRowContext(ctx, q, groupID).Scan(&exists); err != nil {
+		return false, e.Wrap(fn, err)
+	}
+
+	return exists, nil
+}
+
+func (s *Storage) TgChannelIsExists(ctx context.Context, channelID int64) (bool, error) {
+	const fn = "sqlite.TgChannelIsExists"
+
+	q := `SELECT EXISTS (SELECT 1 FROM tg_channels WHERE id = ?)`
+
+	var exists bool
+
+	if err := s.db.QueryRowContext(ctx, q, channelID).Scan(&exists); err != nil {
+		return false, e.Wrap(fn, err)
+	}
+
+	return exists, nil
+}
+
+func (s *Storage) DeleteUser(ctx context.Context, userID int64) error {
+	const fn = "sqlite.DeleteUser"
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, userID)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return affected(fn, res, e.Wrap(fn, storage.ErrNoRecordsFound))
+}
+
+func (s *Storage) InsertVkGroup(ctx context.Context, vkGroup models.VkGroup) error {
+	const fn = "sqlite.InsertVkGroup"
+
+	q := `INSERT INTO vk_groups (id, name, domain) VALUES (?, ?, ?)`
+
+	_, err := s.db.ExecContext(ctx, q, vkGroup.ID, vkGroup.Name, vkGroup.Domain)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) UpdateTgGroupInfo(ctx context.Context, group models.TgGroup) error {
+	const fn = "sqlite.UpdateTgGroupInfo"
+
+	q := `UPDATE tg_groups SET name = ?, description = ? WHERE id = ?`
+
+	res, err := s.db.ExecContext(ctx, q, group.Name, group.Description, group.GroupID)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return affected(fn, res, e.Wrap(fn, storage.ErrNoRecordsFound))
+}
+
+func (s *Storage) DeleteTgChannel(ctx context.Context, channelID int64) error {
+	const fn = "sqlite.DeleteTgChannel"
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM tg_channels WHERE id = ?`, channelID)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return affected(fn, res, e.Wrap(fn, storage.ErrNoRecordsFound))
+}
+
+func (s *Storage) DeleteTgGroup(ctx context.Context, groupID int64) error {
+	const fn = "sqlite.DeleteTgGroup"
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM tg_groups WHERE id = ?`, groupID)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return affected(fn, res, e.Wrap(fn, storage.ErrNoRecordsFound))
+}
+
+// InsertTgChannelMessages Approved messages are published to the feed
+func (s *Storage) InsertTgChannelMessages(ctx context.Context, msgs []models.TgChMessage) error {
+	const fn = "sqlite.InsertTgChannelMessages"
+
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	var args []any
+
+	for _, msg := range msgs {
+		metadataSQL, err := metadataJSON(msg.Metadata)
+		if err != nil {
+			return e.Wrap(fn, err)
+		}
+
+		entitiesSQL, err := entitiesJSON(msg.Entities)
+		if err != nil {
+			return e.Wrap(fn, err)
+		}
+
+		args = append(args, msg.MessageID, msg.ChannelID, msg.Text, metadataSQL, createdAt(msg.CreatedAt), msg.Status, entitiesSQL)
+	}
+
+	q := `INSERT INTO tg_channel_messages (msg_id, channel_id, text, metadata, created_at, status, entities) VALUES ` +
+		strings.Repeat(", (?, ?, ?, ?, ?, COALESCE(NULLIF(?, ''), 'approved'), ?)", len(msgs))[2:] + `
+	ON CONFLICT (msg_id, channel_id) DO NOTHING
+	RETURNING channel_id, msg_id`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	defer tx.Rollback()
+
+	keys, err := insertKeys(ctx, tx, q, args)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := addNews(ctx, tx, models.KindTgChannel, keys); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := s.commitNews(tx); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+// InsertTgGroupMessages Approved messages are published to the feed
+func (s *Storage) InsertTgGroupMessages(ctx context.Context, msgs []models.TgGroupMessage) error {
+	const fn = "sqlite.InsertTgGroupMessages"
+
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	var args []any
+
+	for _, msg := range msgs {
+		metadataSQL, err := metadataJSON(msg.Metadata)
+		if err != nil {
+			return e.Wrap(fn, err)
+		}
+
+		entitiesSQL, err := entitiesJSON(msg.Entities)
+		if err != nil {
+			return e.Wrap(fn, err)
+		}
+
+		args = append(args, msg.MessageID, msg.GroupID, msg.Username, msg.Text, metadataSQL, createdAt(msg.CreatedAt), msg.Status, entitiesSQL)
+	}
+
+	q := `INSERT INTO tg_group_messages (msg_id, group_id, username, text, metadata, created_at, status, entities) VALUES ` +
+		strings.Repeat(", (?, ?, ?, ?, ?, ?, COALESCE(NULLIF(?, ''), 'approved'), ?)", len(msgs))[2:] + `
+	ON CONFLICT (msg_id, group_id) DO NOTHING
+	RETURNING group_id, msg_id`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	defer tx.Rollback()
+
+	keys, err := insertKeys(ctx, tx, q, args)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := addNews(ctx, tx, models.KindTgGroup, keys); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := s.commitNews(tx); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+// insertKeys runs the insert returning the source id and message id of the inserted rows
+func insertKeys(ctx context.Context, q querier, query string, args []any) ([]sourceKey, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var keys []sourceKey
+
+	for rows.Next() {
+		var key sourceKey
+
+		if err := rows.Scan(&key.sourceID, &key.msgID); err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// insertWebMessages Returns ids of the inserted messages in the same order
+func insertWebMessages(ctx context.Context, q querier, msgs []models.WebMessage) ([]int64, error) {
+	const fn = "sqlite.insertWebMessages"
+
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now().UTC()
+
+	var args []any
+
+	for _, msg := range msgs {
+		metadataSQL, err := metadataJSON(msg.Metadata)
+		if err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		entitiesSQL, err := entitiesJSON(msg.Entities)
+		if err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		args = append(args, msg.GroupName, msg.Title, msg.Link, msg.Text, metadataSQL, msg.CreatedAt.UTC(), msg.Type, msg.SourceRef, entitiesSQL, msg.Backfilled, now)
+	}
+
+	query := `INSERT INTO web_messages (group_name, title, link, text, metadata, created_at, type, source_ref, entities, backfilled, updated_at) VALUES ` +
+		strings.Repeat(", (?, NULLIF(?, ''), NULLIF(?, ''), ?, ?, ?, ?, NULLIF(?, ''), ?, ?, ?)", len(msgs))[2:] + `
+	RETURNING id`
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	ids := make([]int64, 0, len(msgs))
+
+	for rows.Next() {
+		var id int64
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	return ids, nil
+}
+
+const webMessageColumns = `id, group_name, COALESCE(title, ''), COALESCE(link, ''), text, metadata, created_at, type, entities,
+	COALESCE(source_ref, ''), backfilled, updated_at`
+
+func (s *Storage) GetWebMessages(ctx context.Context, filter models.WebMessageFilter) ([]models.WebMessage, error) {
+	const fn = "sqlite.GetWebMessages"
+
+	var (
+		conds []string
+		args  []any
+	)
+
+	if len(filter.Types) != 0 {
+		conds = append(conds, "type IN ("+placeholders(len(filter.Types))+")")
+
+		for _, t := range filter.Types {
+			args = append(args, t)
+		}
+	}
+
+	if filter.GroupName != "" {
+		conds = append(conds, "instr(unicode_lower(group_name), unicode_lower(?)) > 0")
+		args = append(args, filter.GroupName)
+	}
+
+	if !filter.From.IsZero() {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, filter.From.UTC())
+	}
+
+	if !filter.To.IsZero() {
+		conds = append(conds, "created_at < ?")
+		args = append(args, filter.To.UTC())
+	}
+
+	if filter.BeforeID != 0 {
+		// A deleted cursor falls back to the id order, so paging goes on instead of returning nothing
+		conds = append(conds, `CASE WHEN EXISTS (SELECT 1 FROM web_messages WHERE id = ?)
+			THEN (created_at, id) < (SELECT created_at, id FROM web_messages WHERE id = ?)
+			ELSE id < ? END`)
+		args = append(args, filter.BeforeID, filter.BeforeID, filter.BeforeID)
+	}
+
+	order := "created_at DESC, id DESC"
+
+	// Backfilled history was never live, so it is not replayed as missed news
+	if filter.AfterID != 0 {
+		conds = append(conds, "id > ? AND NOT backfilled")
+		args = append(args, filter.AfterID)
+
+		order = "id ASC"
+	}
+
+	q := `SELECT ` + webMessageColumns + ` FROM web_messages`
+
+	if len(conds) != 0 {
+		q += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+
+	q += `
+	ORDER BY ` + order + `
+	LIMIT ?`
+
+	args = append(args, filter.Limit)
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	var msgs []models.WebMessage
+
+	for rows.Next() {
+		msg, err := scanWebMessage(rows)
+		if err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		msgs = append(msgs, msg)
+	}
+
+	if len(msgs) == 0 {
+		return nil, storage.ErrNoRecordsFound
+	}
+
+	return msgs, nil
+}
+
+// GetFeedDeletedAt Returns the time a news was last deleted from the feed, zero when none was
+func (s *Storage) GetFeedDeletedAt(ctx context.Context) (time.Time, error) {
+	const fn = "sqlite.GetFeedDeletedAt"
+
+	var deletedAt sql.NullTime
+
+	err := s.db.QueryRowContext(ctx, `SELECT deleted_at FROM web_messages_state`).Scan(&deletedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, e.Wrap(fn, err)
+	}
+
+	return deletedAt.Time, nil
+}
+
+func (s *Storage) GetWebMessage(ctx context.Context, id int64) (models.WebMessage, error) {
+	const fn = "sqlite.GetWebMessage"
+
+	q := `SELECT ` + webMessageColumns + ` FROM web_messages WHERE id = ?`
+
+	msg, err := scanWebMessage(s.db.QueryRowContext(ctx, q, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.WebMessage{}, storage.ErrNoRecordsFound
+		}
+
+		return models.WebMessage{}, e.Wrap(fn, err)
+	}
+
+	return msg, nil
+}
+
+// SearchWebMessages SQLite has no stemming, so every word of the query matches as a prefix,
+// title matches weigh the most
+func (s *Storage) SearchWebMessages(ctx context.Context, query models.SearchQuery) ([]models.SearchHit, error) {
+	const fn = "sqlite.SearchWebMessages"
+
+	match := ftsQuery(query.Query)
+	if match == "" {
+		return nil, storage.ErrNoRecordsFound
+	}
+
+	q := `
+	SELECT ` + webMessageColumns + `, f.rank, f.snippet
+	FROM web_messages
+	JOIN (
+		SELECT rowid, -bm25(web_messages_fts, 1.0, 0.2, 0.4) AS rank,
+			snippet(web_messages_fts, 2, ?, ?, ' … ', 25) AS snippet
+		FROM web_messages_fts
+		WHERE web_messages_fts MATCH ?
+	) f ON f.rowid = web_messages.id
+	ORDER BY f.rank DESC, created_at DESC, id DESC
+	LIMIT ? OFFSET ?`
+
+	rows, err := s.db.QueryContext(ctx, q, models.SnippetStart, models.SnippetStop, match, query.Limit, query.Offset)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	var hits []models.SearchHit
+
+	for rows.Next() {
+		var hit models.SearchHit
+
+		hit.Message, err = scanWebMessage(rows, &hit.Rank, &hit.Snippet)
+		if err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		hits = append(hits, hit)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	if len(hits) == 0 {
+		return nil, storage.ErrNoRecordsFound
+	}
+
+	return hits, nil
+}
+
+// ftsQuery converts the websearch syntax of the Postgres backend to FTS5:
+// words match as prefixes, "quoted phrases" exactly, or is OR and -word excludes the word
+func ftsQuery(query string) string {
+	var (
+		// include are the AND-ed groups of OR-ed terms
+		include [][]string
+		exclude []string
+		or      bool
+	)
+
+	add := func(term string, neg bool) {
+		switch {
+		case neg:
+			exclude = append(exclude, term)
+		case or && len(include) != 0:
+			include[len(include)-1] = append(include[len(include)-1], term)
+		default:
+			include = append(include, []string{term})
+		}
+
+		or = false
+	}
+
+	for rest := strings.TrimSpace(query); rest != ""; rest = strings.TrimSpace(rest) {
+		neg := strings.HasPrefix(rest, "-")
+		if neg {
+			rest = rest[1:]
+		}
+
+		if strings.HasPrefix(rest, `"`) {
+			phrase, tail, _ := strings.Cut(rest[1:], `"`)
+			rest = tail
+
+			if words := ftsWords(phrase); len(words) != 0 {
+				add(`"`+strings.Join(words, " ")+`"`, neg)
+			}
+
+			continue
+		}
+
+		word, tail, _ := strings.Cut(rest, " ")
+		rest = tail
+
+		if !neg && strings.EqualFold(word, "or") {
+			or = len(include) != 0
+			continue
+		}
+
+		for _, w := range ftsWords(word) {
+			add(`"`+w+`"*`, neg)
+		}
+	}
+
+	if len(include) == 0 {
+		return ""
+	}
+
+	// OR groups are parenthesized, "a or b c" is (a OR b) AND c
+	groups := make([]string, 0, len(include))
+
+	for _, group := range include {
+		if len(group) == 1 {
+			groups = append(groups, group[0])
+			continue
+		}
+
+		groups = append(groups, "("+strings.Join(group, " OR ")+")")
+	}
+
+	res := strings.Join(groups, " AND ")
+
+	for _, term := range exclude {
+		res += " NOT " + term
+	}
+
+	return res
+}
+
+// ftsWords splits the text like the unicode61 tokenizer
+func ftsWords(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanWebMessage extra receives the columns selected after webMessageColumns
+func scanWebMessage(row scanner, extra ...any) (models.WebMessage, error) {
+	var msg models.WebMessage
+	var metadataStr, entitiesStr sql.NullString
+	var updatedAt sql.NullTime
+
+	dest := []any{&msg.ID, &msg.GroupName, &msg.Title, &msg.Link, &msg.Text, &metadataStr, &msg.CreatedAt, &msg.Type, &entitiesStr,
+		&msg.SourceRef, &msg.Backfilled, &updatedAt}
+
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return models.WebMessage{}, err
+	}
+
+	// News added before updated_at was tracked count as changed when created
+	msg.UpdatedAt = msg.CreatedAt
+	if updatedAt.Valid {
+		msg.UpdatedAt = updatedAt.Time
+	}
+
+	if metadataStr.Valid {
+		err = json.Unmarshal([]byte(metadataStr.String), &msg.Metadata)
+		if err != nil {
+			return models.WebMessage{}, err
+		}
+	}
+
+	if entitiesStr.Valid {
+		err = json.Unmarshal([]byte(entitiesStr.String), &msg.Entities)
+		if err != nil {
+			return models.WebMessage{}, err
+		}
+	}
+
+	return msg, nil
+}
+
+// metadataJSON Messages without metadata are stored as NULL
+func metadataJSON(metadata []models.MetaPair) (sql.NullString, error) {
+	if metadata == nil {
+		return sql.NullString{}, nil
+	}
+
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	return sql.NullString{String: string(metadataJSON), Valid: true}, nil
+}
+
+// entitiesJSON Messages without entities are stored as NULL
+func entitiesJSON(entities []models.Entity) (sql.NullString, error) {
+	if len(entities) == 0 {
+		return sql.NullString{}, nil
+	}
+
+	entitiesJSON, err := json.Marshal(entities)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	return sql.NullString{String: string(entitiesJSON), Valid: true}, nil
+}
+
+// createdAt Messages without a date are stored with the current time, as by CURRENT_TIMESTAMP in Postgres
+func createdAt(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now().UTC()
+	}
+
+	return t.UTC()
+}
+
+// valueRows returns n rows of cols placeholders
+func valueRows(n, cols int) string {
+	return strings.Repeat(", ("+placeholders(cols)+")", n)[2:]
+}
+
+func placeholders(n int) string {
+	return strings.Repeat(", ?", n)[2:]
+}
+
+// affected returns notFound when the statement changed nothing
+func affected(fn string, res sql.Result, notFound error) error {
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if rows == 0 {
+		return notFound
+	}
+
+	return nil
+}
+
+func (s *Storage) GetTgChannels(ctx context.Context) ([]models.TgChannel, error) {
+	const fn = "sqlite.GetTgChannels"
+
+	q := `SELECT id, name, COALESCE(description, '') FROM tg_channels`
+
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	var channels []models.TgChannel
+
+	for rows.Next() {
+		var channel models.TgChannel
+
+		if err := rows.Scan(&channel.ChannelID, &channel.Name, &channel.Description); err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		channels = append(channels, channel)
+	}
+
+	if len(channels) == 0 {
+		return nil, storage.ErrNoRecordsFound
+	}
+
+	return channels, nil
+}
+
+func (s *Storage) GetTgGroups(ctx context.Context) ([]models.TgGroup, error) {
+	const fn = "sqlite.GetTgGroups"
+
+	q := `SELECT id, name, COALESCE(description, '') FROM tg_groups`
+
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	var groups []models.TgGroup
+
+	for rows.Next() {
+		var group models.TgGroup
+
+		if err := rows.Scan(&group.GroupID, &group.Name, &group.Description); err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		groups = append(groups, group)
+	}
+
+	if len(groups) == 0 {
+		return nil, storage.ErrNoRecordsFound
+	}
+
+	return groups, nil
+}
+
+// InsertVkMessages Edited posts replace the stored ones and update their news
+func (s *Storage) InsertVkMessages(ctx context.Context, msgs []models.VkMessage) error {
+	const fn = "sqlite.InsertVkMessages"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	defer tx.Rollback()
+
+	insertQ := `INSERT INTO vk_messages (msg_id, group_id, text, metadata, created_at, edited_at, backfilled)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (msg_id, group_id) DO NOTHING`
+
+	updateQ := `UPDATE vk_messages SET text = ?, metadata = ?, edited_at = ?
+	WHERE msg_id = ? AND group_id = ? AND edited_at IS NOT ?`
+
+	var inserted, edited []sourceKey
+
+	for _, msg := range msgs {
+		args, err := vkMessageArgs(msg)
+		if err != nil {
+			return e.Wrap(fn, err)
+		}
+
+		key := sourceKey{sourceID: int64(msg.GroupID), msgID: msg.MessageID}
+
+		res, err := tx.ExecContext(ctx, insertQ, args...)
+		if err != nil {
+			return e.Wrap(fn, err)
+		}
+
+		if n, err := res.RowsAffected(); err != nil {
+			return e.Wrap(fn, err)
+		} else if n != 0 {
+			inserted = append(inserted, key)
+			continue
+		}
+
+		if msg.EditedAt.IsZero() {
+			continue
+		}
+
+		// text, metadata, edited_at, msg_id, group_id, edited_at
+		res, err = tx.ExecContext(ctx, updateQ, args[2], args[3], args[5], args[0], args[1], args[5])
+		if err != nil {
+			return e.Wrap(fn, err)
+		}
+
+		if n, err := res.RowsAffected(); err != nil {
+			return e.Wrap(fn, err)
+		} else if n != 0 {
+			edited = append(edited, key)
+		}
+	}
+
+	if err := addNews(ctx, tx, "vk", inserted); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := updateNews(ctx, tx, "vk", edited); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := s.commitNews(tx); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+// InsertBackfillVkMessages Returns the number of inserted messages, stored ones are skipped
+func (s *Storage) InsertBackfillVkMessages(ctx context.Context, msgs []models.VkMessage) (int, error) {
+	const fn = "sqlite.InsertBackfillVkMessages"
+
+	if len(msgs) == 0 {
+		return 0, nil
+	}
+
+	var args []any
+
+	for _, msg := range msgs {
+		msgArgs, err := vkMessageArgs(msg)
+		if err != nil {
+			return 0, e.Wrap(fn, err)
+		}
+
+		args = append(args, msgArgs...)
+	}
+
+	q := `INSERT INTO vk_messages (msg_id, group_id, text, metadata, created_at, edited_at, backfilled) VALUES ` +
+		valueRows(len(msgs), 7) + `
+	ON CONFLICT DO NOTHING
+	RETURNING group_id, msg_id`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, e.Wrap(fn, err)
+	}
+
+	defer tx.Rollback()
+
+	keys, err := insertKeys(ctx, tx, q, args)
+	if err != nil {
+		return 0, e.Wrap(fn, err)
+	}
+
+	if err := addNews(ctx, tx, "vk", keys); err != nil {
+		return 0, e.Wrap(fn, err)
+	}
+
+	if err := s.commitNews(tx); err != nil {
+		return 0, e.Wrap(fn, err)
+	}
+
+	return len(keys), nil
+}
+
+// vkMessageArgs msg_id, group_id, text, metadata, created_at, edited_at, backfilled
+func vkMessageArgs(msg models.VkMessage) ([]any, error) {
+	metadataSQL, err := metadataJSON(msg.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	editedAt := sql.NullTime{
+		Time:  msg.EditedAt.UTC(),
+		Valid: !msg.EditedAt.IsZero(),
+	}
+
+	return []any{msg.MessageID, msg.GroupID, msg.Text, metadataSQL, msg.CreatedAt.UTC(), editedAt, msg.Backfilled}, nil
+}
+
+func (s *Storage) GetVkGroups(ctx context.Context) ([]models.VkGroup, error) {
+	const fn = "sqlite.GetVkGroups"
+
+	q := `SELECT id, name, domain FROM vk_groups`
+
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	var groups []models.VkGroup
+
+	for rows.Next() {
+		var group models.VkGroup
+
+		if err := rows.Scan(&group.ID, &group.Name, &group.Domain); err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		groups = append(groups, group)
+	}
+
+	if len(groups) == 0 {
+		return nil, storage.ErrNoRecordsFound
+	}
+
+	return groups, nil
+}
+
+func (s *Storage) GetVkGroupByID(ctx context.Context, groupID int) (models.VkGroup, error) {
+	const fn = "sqlite.GetVkGroupByID"
+
+	q := `SELECT id, name, domain FROM vk_groups WHERE id = ?`
+
+	var group models.VkGroup
+
+	if err := s.db.QueryRowContext(ctx, q, groupID).Scan(&group.ID, &group.Name, &group.Domain); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.VkGroup{}, storage.ErrNoRecordsFound
+		}
+
+		return models.VkGroup{}, e.Wrap(fn, err)
+	}
+
+	return group, nil
+}
+
+// GetVkCursor Returns the id of the newest stored post of the group, 0 for a new group
+func (s *Storage) GetVkCursor(ctx context.Context, groupID int) (int, error) {
+	const fn = "sqlite.GetVkCursor"
+
+	q := `SELECT last_post_id FROM vk_groups WHERE id = ?`
+
+	var postID int
+
+	if err := s.db.QueryRowContext(ctx, q, groupID).Scan(&postID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, storage.ErrNoRecordsFound
+		}
+
+		return 0, e.Wrap(fn, err)
+	}
+
+	return postID, nil
+}
+
+// SetVkCursor The cursor never moves back
+func (s *Storage) SetVkCursor(ctx context.Context, groupID int, postID int) error {
+	const fn = "sqlite.SetVkCursor"
+
+	q := `UPDATE vk_groups SET last_post_id = MAX(last_post_id, ?) WHERE id = ?`
+
+	res, err := s.db.ExecContext(ctx, q, postID, groupID)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return affected(fn, res, e.Wrap(fn, storage.ErrNoRecordsFound))
+}
+
+// GetVkInterval Returns zero durations when the interval was not set by the command
+func (s *Storage) GetVkInterval(ctx context.Context, groupID int) (time.Duration, time.Duration, error) {
+	const fn = "sqlite.GetVkInterval"
+
+	q := `SELECT COALESCE(min_interval, 0), COALESCE(max_interval, 0) FROM vk_groups WHERE id = ?`
+
+	var minSec, maxSec int64
+
+	if err := s.db.QueryRowContext(ctx, q, groupID).Scan(&minSec, &maxSec); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, 0, storage.ErrNoRecordsFound
+		}
+
+		return 0, 0, e.Wrap(fn, err)
+	}
+
+	return time.Duration(minSec) * time.Second, time.Duration(maxSec) * time.Second, nil
+}
+
+func (s *Storage) SetVkInterval(ctx context.Context, groupID int, min, max time.Duration) error {
+	const fn = "sqlite.SetVkInterval"
+
+	q := `UPDATE vk_groups SET min_interval = ?, max_interval = ? WHERE id = ?`
+
+	res, err := s.db.ExecContext(ctx, q, int64(min.Seconds()), int64(max.Seconds()), groupID)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return affected(fn, res, e.Wrap(fn, storage.ErrNoRecordsFound))
+}
+
+func (s *Storage) DeleteVkGroup(ctx context.Context, vkDomain string) error {
+	const fn = "sqlite.DeleteVkGroup"
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM vk_groups WHERE domain = ?`, vkDomain)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return affected(fn, res, storage.ErrNoRecordsFound)
+}
+
+func (s *Storage) InsertRssFeed(ctx context.Context, feed models.RssFeed) (int64, error) {
+	const fn = "sqlite.InsertRssFeed"
+
+	q := `INSERT INTO rss_feeds (url, title) VALUES (?, ?) RETURNING id`
+
+	var id int64
+
+	err := s.db.QueryRowContext(ctx, q, feed.Url, feed.Title).Scan(&id)
+	if err != nil {
+		return 0, e.Wrap(fn, err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) GetRssFeeds(ctx context.Context) ([]models.RssFeed, error) {
+	const fn = "sqlite.GetRssFeeds"
+
+	q := `SELECT id, url, title FROM rss_feeds`
+
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	var feeds []models.RssFeed
+
+	for rows.Next() {
+		var feed models.RssFeed
+
+		err := rows.Scan(&feed.ID, &feed.Url, &feed.Title)
+		if err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		feeds = append(feeds, feed)
+	}
+
+	if len(feeds) == 0 {
+		return nil, storage.ErrNoRecordsFound
+	}
+
+	return feeds, nil
+}
+
+func (s *Storage) DeleteRssFeed(ctx context.Context, feedURL string) error {
+	const fn = "sqlite.DeleteRssFeed"
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM rss_feeds WHERE url = ?`, feedURL)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return affected(fn, res, storage.ErrNoRecordsFound)
+}
+
+// InsertRssItems Items with an already stored guid are skipped
+func (s *Storage) InsertRssItems(ctx context.Context, items []models.RssItem) error {
+	const fn = "sqlite.InsertRssItems"
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	var args []any
+
+	for _, item := range items {
+		metadataSQL, err := metadataJSON(item.Metadata)
+		if err != nil {
+			return e.Wrap(fn, err)
+		}
+
+		args = append(args, item.GUID, item.FeedID, item.Title, item.Link, item.Text, item.Author, metadataSQL, item.CreatedAt.UTC())
+	}
+
+	q := `INSERT INTO rss_items (guid, feed_id, title, link, text, author, metadata, created_at) VALUES ` +
+		strings.Repeat(", (?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?)", len(items))[2:] + `
+	ON CONFLICT (guid, feed_id) DO NOTHING
+	RETURNING feed_id, guid`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	defer tx.Rollback()
+
+	keys, err := insertKeys(ctx, tx, q, args)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := addNews(ctx, tx, "rss", keys); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := s.commitNews(tx); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) GetFilterRules(ctx context.Context) ([]models.FilterRule, error) {
+	const fn = "sqlite.GetFilterRules"
+
+	q := `SELECT id, scope, rule, value FROM filter_rules ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	var rules []models.FilterRule
+
+	for rows.Next() {
+		var rule models.FilterRule
+
+		err := rows.Scan(&rule.ID, &rule.Scope, &rule.Rule, &rule.Value)
+		if err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	if len(rules) == 0 {
+		return nil, storage.ErrNoRecordsFound
+	}
+
+	return rules, nil
+}
+
+func (s *Storage) InsertFilterRule(ctx context.Context, rule models.FilterRule) (int64, error) {
+	const fn = "sqlite.InsertFilterRule"
+
+	q := `INSERT INTO filter_rules (scope, rule, value) VALUES (?, ?, ?)
+		ON CONFLICT (scope, rule, value) DO UPDATE SET value = excluded.value
+		RETURNING id`
+
+	var id int64
+
+	err := s.db.QueryRowContext(ctx, q, rule.Scope, rule.Rule, rule.Value).Scan(&id)
+	if err != nil {
+		return 0, e.Wrap(fn, err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) DeleteFilterRule(ctx context.Context, id int64) error {
+	const fn = "sqlite.DeleteFilterRule"
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM filter_rules WHERE id = ?`, id)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return affected(fn, res, storage.ErrNoRecordsFound)
+}
+
+func (s *Storage) TgGroupIsModerated(ctx context.Context, groupID int64) (bool, error) {
+	const fn = "sqlite.TgGroupIsModerated"
+
+	var moderated bool
+
+	err := s.db.QueryRowContext(ctx, `SELECT moderated FROM tg_groups WHERE id = ?`, groupID).Scan(&moderated)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, storage.ErrNoRecordsFound
+		}
+
+		return false, e.Wrap(fn, err)
+	}
+
+	return moderated, nil
+}
+
+func (s *Storage) TgChannelIsModerated(ctx context.Context, channelID int64) (bool, error) {
+	const fn = "sqlite.TgChannelIsModerated"
+
+	var moderated bool
+
+	err := s.db.QueryRowContext(ctx, `SELECT moderated FROM tg_channels WHERE id = ?`, channelID).Scan(&moderated)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, storage.ErrNoRecordsFound
+		}
+
+		return false, e.Wrap(fn, err)
+	}
+
+	return moderated, nil
+}
+
+// SetTgSourceModerated kind is models.KindTgGroup or models.KindTgChannel
+func (s *Storage) SetTgSourceModerated(ctx context.Context, kind string, chatID int64, moderated bool) error {
+	const fn = "sqlite.SetTgSourceModerated"
+
+	table, _, err := tgMessageTables(kind)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	q := fmt.Sprintf(`UPDATE %s SET moderated = ? WHERE id = ?`, table)
+
+	res, err := s.db.ExecContext(ctx, q, moderated, chatID)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return affected(fn, res, storage.ErrNoRecordsFound)
+}
+
+// GetModerators Returns telegram ids of all users
+func (s *Storage) GetModerators(ctx context.Context) ([]int64, error) {
+	const fn = "sqlite.GetModerators"
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id FROM users`)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	var ids []int64
+
+	for rows.Next() {
+		var id int64
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	if len(ids) == 0 {
+		return nil, storage.ErrNoRecordsFound
+	}
+
+	return ids, nil
+}
+
+// SetTgMessageStatus Changes only pending messages, approved ones are published to the feed
+func (s *Storage) SetTgMessageStatus(ctx context.Context, ref models.TgMessageRef, status string) error {
+	const fn = "sqlite.SetTgMessageStatus"
+
+	_, table, err := tgMessageTables(ref.Kind)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	q := fmt.Sprintf(`UPDATE %s SET status = ? WHERE msg_id = ? AND %s = ? AND status = 'pending'`,
+		table, tgChatColumn(ref.Kind))
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, q, status, ref.MessageID, ref.ChatID)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := affected(fn, res, storage.ErrNoRecordsFound); err != nil {
+		return err
+	}
+
+	if status == models.MsgStatusApproved {
+		if err := addNews(ctx, tx, ref.Kind, []sourceKey{{sourceID: ref.ChatID, msgID: ref.MessageID}}); err != nil {
+			return e.Wrap(fn, err)
+		}
+	}
+
+	if err := s.commitNews(tx); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) UpdatePendingTgMessageText(ctx context.Context, ref models.TgMessageRef, text string, entities []models.Entity) error {
+	const fn = "sqlite.UpdatePendingTgMessageText"
+
+	_, table, err := tgMessageTables(ref.Kind)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	entitiesSQL, err := entitiesJSON(entities)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	q := fmt.Sprintf(`UPDATE %s SET text = ?, entities = ? WHERE msg_id = ? AND %s = ? AND status = 'pending'`,
+		table, tgChatColumn(ref.Kind))
+
+	res, err := s.db.ExecContext(ctx, q, text, entitiesSQL, ref.MessageID, ref.ChatID)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return affected(fn, res, storage.ErrNoRecordsFound)
+}
+
+var ErrUnknownTgKind = errors.New("unknown telegram source kind")
+
+// tgMessageTables returns the source and messages tables of a telegram source kind
+func tgMessageTables(kind string) (string, string, error) {
+	switch kind {
+	case models.KindTgGroup:
+		return "tg_groups", "tg_group_messages", nil
+
+	case models.KindTgChannel:
+		return "tg_channels", "tg_channel_messages", nil
+
+	default:
+		return "", "", ErrUnknownTgKind
+	}
+}
+
+func tgChatColumn(kind string) string {
+	if kind == models.KindTgGroup {
+		return "group_id"
+	}
+
+	return "channel_id"
+}
+
+// UpdateTgMessageText Approved messages update their news in the feed
+func (s *Storage) UpdateTgMessageText(ctx context.Context, ref models.TgMessageRef, text string, entities []models.Entity) error {
+	const fn = "sqlite.UpdateTgMessageText"
+
+	_, table, err := tgMessageTables(ref.Kind)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	entitiesSQL, err := entitiesJSON(entities)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	q := fmt.Sprintf(`UPDATE %s SET text = ?1, entities = ?2
+	WHERE msg_id = ?3 AND %s = ?4 AND (text <> ?1 OR entities IS NOT ?2)
+	RETURNING status`,
+		table, tgChatColumn(ref.Kind))
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	defer tx.Rollback()
+
+	var status string
+
+	err = tx.QueryRowContext(ctx, q, text, entitiesSQL, ref.MessageID, ref.ChatID).Scan(&status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrNoRecordsFound
+		}
+
+		return e.Wrap(fn, err)
+	}
+
+	if status == models.MsgStatusApproved {
+		if err := updateNews(ctx, tx, ref.Kind, []sourceKey{{sourceID: ref.ChatID, msgID: ref.MessageID}}); err != nil {
+			return e.Wrap(fn, err)
+		}
+	}
+
+	if err := s.commitNews(tx); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+func updateWebMessageBySourceRef(ctx context.Context, q querier, sourceRef, text string, metadata []models.MetaPair, entities []models.Entity) (models.WebMessage, error) {
+	const fn = "sqlite.updateWebMessageBySourceRef"
+
+	metadataSQL, err := metadataJSON(metadata)
+	if err != nil {
+		return models.WebMessage{}, e.Wrap(fn, err)
+	}
+
+	entitiesSQL, err := entitiesJSON(entities)
+	if err != nil {
+		return models.WebMessage{}, e.Wrap(fn, err)
+	}
+
+	query := `UPDATE web_messages SET text = ?, metadata = ?, entities = ?, updated_at = ? WHERE source_ref = ? RETURNING ` + webMessageColumns
+
+	msg, err := scanWebMessage(q.QueryRowContext(ctx, query, text, metadataSQL, entitiesSQL, time.Now().UTC(), sourceRef))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.WebMessage{}, storage.ErrNoRecordsFound
+		}
+
+		return models.WebMessage{}, e.Wrap(fn, err)
+	}
+
+	return msg, nil
+}
+
+// DeleteWebMessage Removes the message from the feed and writes a models.OutboxDeleted entry
+func (s *Storage) DeleteWebMessage(ctx context.Context, id int64) error {
+	const fn = "sqlite.DeleteWebMessage"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM web_messages WHERE id = ?`, id)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := affected(fn, res, storage.ErrNoRecordsFound); err != nil {
+		return err
+	}
+
+	if err := deletedNews(ctx, tx, []int64{id}); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := s.commitNews(tx); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+// GetRetentionSources Returns all sources the retention policies may apply to
+func (s *Storage) GetRetentionSources(ctx context.Context) ([]models.RetentionSource, error) {
+	const fn = "sqlite.GetRetentionSources"
+
+	q := `
+	SELECT 'tg_group', id, CAST(id AS TEXT) FROM tg_groups
+	UNION ALL
+	SELECT 'tg_channel', id, CAST(id AS TEXT) FROM tg_channels
+	UNION ALL
+	SELECT 'vk', id, domain FROM vk_groups
+	UNION ALL
+	SELECT 'rss', id, url FROM rss_feeds`
+
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	var res []models.RetentionSource
+
+	for rows.Next() {
+		var src models.RetentionSource
+
+		if err := rows.Scan(&src.Kind, &src.ID, &src.Key); err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		res = append(res, src)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	if len(res) == 0 {
+		return nil, storage.ErrNoRecordsFound
+	}
+
+	return res, nil
+}
+
+// jsonTime formats a stored time like to_jsonb does in Postgres
+func jsonTime(col string) string {
+	return fmt.Sprintf(`strftime('%%Y-%%m-%%dT%%H:%%M:%%fZ', %s)`, col)
+}
+
+// messageTable describes the message table of a source kind
+type messageTable struct {
+	name      string
+	sourceCol string
+	msgCol    string
+	// row is the JSON object of a row t, the same fields as to_jsonb in Postgres
+	row string
+	// where skips messages the retention must not touch
+	where string
+}
+
+var messageTables = map[string]messageTable{
+	models.KindTgGroup: {"tg_group_messages", "group_id", "msg_id",
+		`json_object('msg_id', t.msg_id, 'group_id', t.group_id, 'username', t.username, 'text', t.text,
+			'metadata', json(t.metadata), 'entities', json(t.entities), 'status', t.status,
+			'created_at', ` + jsonTime("t.created_at") + `)`,
+		"AND t.status <> 'pending'"},
+	models.KindTgChannel: {"tg_channel_messages", "channel_id", "msg_id",
+		`json_object('msg_id', t.msg_id, 'channel_id', t.channel_id, 'text', t.text,
+			'metadata', json(t.metadata), 'entities', json(t.entities), 'status', t.status,
+			'created_at', ` + jsonTime("t.created_at") + `)`,
+		"AND t.status <> 'pending'"},
+	"vk": {"vk_messages", "group_id", "msg_id",
+		`json_object('msg_id', t.msg_id, 'group_id', t.group_id, 'text', t.text, 'metadata', json(t.metadata),
+			'created_at', ` + jsonTime("t.created_at") + `, 'edited_at', ` + jsonTime("t.edited_at") + `,
+			'backfilled', json(CASE WHEN t.backfilled THEN 'true' ELSE 'false' END))`,
+		""},
+	"rss": {"rss_items", "feed_id", "guid",
+		`json_object('guid', t.guid, 'feed_id', t.feed_id, 'title', t.title, 'link', t.link, 'text', t.text,
+			'author', t.author, 'metadata', json(t.metadata), 'created_at', ` + jsonTime("t.created_at") + `)`,
+		""},
+}
+
+var webMessageJSON = `json_object('id', w.id, 'group_name', w.group_name, 'title', w.title, 'link', w.link,
+	'text', w.text, 'metadata', json(w.metadata), 'entities', json(w.entities),
+	'created_at', ` + jsonTime("w.created_at") + `, 'type', w.type, 'source_ref', w.source_ref,
+	'backfilled', json(CASE WHEN w.backfilled THEN 'true' ELSE 'false' END))`
+
+// GetExpiredMessages Returns up to limit oldest messages of the source created before the time
+// or not among the newest keep messages, zero before and keep are ignored
+func (s *Storage) GetExpiredMessages(ctx context.Context, src models.RetentionSource, before time.Time, keep, limit int) ([]models.ExpiredMessage, error) {
+	const fn = "sqlite.GetExpiredMessages"
+
+	table, ok := messageTables[src.Kind]
+	if !ok {
+		return nil, e.Wrap(fn, fmt.Errorf("unknown source kind %q", src.Kind))
+	}
+
+	beforeSQL := sql.NullTime{
+		Time:  before.UTC(),
+		Valid: !before.IsZero(),
+	}
+
+	q := fmt.Sprintf(`
+	SELECT CAST(m.%[3]s AS TEXT), m.row_json,
+		CASE WHEN w.id IS NULL THEN NULL ELSE %[6]s END
+	FROM (
+		SELECT t.%[3]s, t.created_at, %[5]s AS row_json, ROW_NUMBER() OVER (ORDER BY t.created_at DESC) AS rn
+		FROM %[1]s t
+		WHERE t.%[2]s = ?1 %[4]s
+	) m
+	LEFT JOIN web_messages w ON w.source_ref = ?2 || m.%[3]s
+	WHERE (?3 > 0 AND m.rn > ?3) OR m.created_at < ?4
+	ORDER BY m.created_at
+	LIMIT ?5`, table.name, table.sourceCol, table.msgCol, table.where, table.row, webMessageJSON)
+
+	refPrefix := fmt.Sprintf("%s:%d:", src.Kind, src.ID)
+
+	rows, err := s.db.QueryContext(ctx, q, src.ID, refPrefix, keep, beforeSQL, limit)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer rows.Close()
+
+	var res []models.ExpiredMessage
+
+	for rows.Next() {
+		var (
+			msg           models.ExpiredMessage
+			message, news []byte
+		)
+
+		if err := rows.Scan(&msg.MsgID, &message, &news); err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		msg.Kind = src.Kind
+		msg.SourceID = src.ID
+		msg.Message = message
+		msg.News = news
+
+		res = append(res, msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	if len(res) == 0 {
+		return nil, storage.ErrNoRecordsFound
+	}
+
+	return res, nil
+}
+
+// DeleteExpiredMessages Deletes the source messages with the news made from them,
+// models.OutboxDeleted entries are written for the news
+func (s *Storage) DeleteExpiredMessages(ctx context.Context, src models.RetentionSource, msgIDs []string) error {
+	const fn = "sqlite.DeleteExpiredMessages"
+
+	table, ok := messageTables[src.Kind]
+	if !ok {
+		return e.Wrap(fn, fmt.Errorf("unknown source kind %q", src.Kind))
+	}
+
+	if len(msgIDs) == 0 {
+		return nil
+	}
+
+	refs := make([]any, 0, len(msgIDs))
+	args := []any{src.ID}
+
+	for _, id := range msgIDs {
+		refs = append(refs, fmt.Sprintf("%s:%d:%s", src.Kind, src.ID, id))
+		args = append(args, id)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`DELETE FROM web_messages WHERE source_ref IN (`+placeholders(len(refs))+`) RETURNING id`, refs...)
+	if err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	var ids []int64
+
+	for rows.Next() {
+		var id int64
+
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return e.Wrap(fn, err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	// The ids are text, the column affinity converts them back to integers for the Telegram and VK tables
+	q := fmt.Sprintf(`DELETE FROM %s WHERE %s = ? AND %s IN (%s)`,
+		table.name, table.sourceCol, table.msgCol, placeholders(len(msgIDs)))
+
+	if _, err := tx.ExecContext(ctx, q, args...); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := deletedNews(ctx, tx, ids); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	if err := s.commitNews(tx); err != nil {
+		return e.Wrap(fn, err)
+	}
+
+	return nil
+}
+
+const mediaObjectColumns = `sha256, name, url, COALESCE(content_type, ''), size,
+	COALESCE(width, 0), COALESCE(height, 0), COALESCE(blurhash, ''), variants`
+
+func scanMediaObject(row scanner) (models.MediaObject, error) {
+	var (
+		obj      models.MediaObject
+		variants []byte
+	)
+
+	err := row.Scan(&obj.SHA256, &obj.Name, &obj.Url, &obj.ContentType, &obj.Size,
+		&obj.Width, &obj.Height, &obj.Blurhash, &variants,
+	)
+	if err != nil {
+		return models.MediaObject{}, err
+	}
+
+	if variants != nil {
+		if err := json.Unmarshal(variants, &obj.Variants); err != nil {
+			return models.MediaObject{}, err
+		}
+	}
+
+	return obj, nil
+}
+
+// AcquireTgMediaFile Returns the stored file of the Telegram file and takes a reference to it
+func (s *Storage) AcquireTgMediaFile(ctx context.Context, fileUniqueID string) (models.MediaObject, error) {
+	const fn = "sqlite.AcquireTgMediaFile"
+
+	q := `
+	UPDATE media_objects SET refcount = refcount + 1
+	WHERE sha256 = (SELECT sha256 FROM tg_media_files WHERE file_unique_id = ?)
+	RETURNING ` + mediaObjectColumns
+
+	obj, err := scanMediaObject(s.db.QueryRowContext(ctx, q, fileUniqueID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MediaObject{}, storage.ErrNoRecordsFound
+		}
+
+		return models.MediaObject{}, e.Wrap(fn, err)
+	}
+
+	return obj, nil
+}
+
+// AcquireMediaObject Returns the stored file with the content hash and takes a reference to it,
+// a non-empty fileUniqueID is remembered for AcquireTgMediaFile
+func (s *Storage) AcquireMediaObject(ctx context.Context, sha256, fileUniqueID string) (models.MediaObject, error) {
+	const fn = "sqlite.AcquireMediaObject"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.MediaObject{}, e.Wrap(fn, err)
+	}
+
+	defer tx.Rollback()
+
+	q := `UPDATE media_objects SET refcount = refcount + 1 WHERE sha256 = ? RETURNING ` + mediaObjectColumns
+
+	obj, err := scanMediaObject(tx.QueryRowContext(ctx, q, sha256))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MediaObject{}, storage.ErrNoRecordsFound
+		}
+
+		return models.MediaObject{}, e.Wrap(fn, err)
+	}
+
+	if err := rememberTgMediaFile(ctx, tx, fileUniqueID, obj.SHA256); err != nil {
+		return models.MediaObject{}, e.Wrap(fn, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.MediaObject{}, e.Wrap(fn, err)
+	}
+
+	return obj, nil
+}
+
+// InsertMediaObject Stores the uploaded file with one reference, the file uploaded
+// at the same time by someone else gets one more reference instead
+func (s *Storage) InsertMediaObject(ctx context.Context, obj models.MediaObject, fileUniqueID string) (models.MediaObject, error) {
+	const fn = "sqlite.InsertMediaObject"
+
+	contentTypeSQL := sql.NullString{
+		String: obj.ContentType,
+		Valid:  obj.ContentType != "",
+	}
+
+	variantsSQL := sql.NullString{}
+
+	if obj.Variants != nil {
+		variantsJSON, err := json.Marshal(obj.Variants)
+		if err != nil {
+			return models.MediaObject{}, e.Wrap(fn, err)
+		}
+
+		variantsSQL = sql.NullString{
+			String: string(variantsJSON),
+			Valid:  true,
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.MediaObject{}, e.Wrap(fn, err)
+	}
+
+	defer tx.Rollback()
+
+	q := `
+	INSERT INTO media_objects (sha256, name, url, content_type, size, width, height, blurhash, variants, refcount)
+	VALUES (?, ?, ?, ?, ?, NULLIF(?, 0), NULLIF(?, 0), NULLIF(?, ''), ?, 1)
+	ON CONFLICT (sha256) DO UPDATE SET refcount = refcount + 1
+	RETURNING ` + mediaObjectColumns
+
+	res, err := scanMediaObject(tx.QueryRowContext(ctx, q,
+		obj.SHA256, obj.Name, obj.Url, contentTypeSQL, obj.Size,
+		obj.Width, obj.Height, obj.Blurhash, variantsSQL,
+	))
+	if err != nil {
+		return models.MediaObject{}, e.Wrap(fn, err)
+	}
+
+	if err := rememberTgMediaFile(ctx, tx, fileUniqueID, res.SHA256); err != nil {
+		return models.MediaObject{}, e.Wrap(fn, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.MediaObject{}, e.Wrap(fn, err)
+	}
+
+	return res, nil
+}
+
+func rememberTgMediaFile(ctx context.Context, tx *sql.Tx, fileUniqueID, sha256 string) error {
+	if fileUniqueID == "" {
+		return nil
+	}
+
+	q := `INSERT INTO tg_media_files (file_unique_id, sha256) VALUES (?, ?) ON CONFLICT (file_unique_id) DO NOTHING`
+
+	_, err := tx.ExecContext(ctx, q, fileUniqueID, sha256)
+
+	return err
+}
+
+// ReleaseMediaObjects Drops one reference per name, returns the names of files nobody references anymore
+// with their resized copies.
+// Names unknown to media_objects are returned as is, they were stored before the deduplication
+func (s *Storage) ReleaseMediaObjects(ctx context.Context, names []string) ([]string, error) {
+	const fn = "sqlite.ReleaseMediaObjects"
+
+	counts := make(map[string]int)
+
+	for _, name := range names {
+		counts[name]++
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	defer tx.Rollback()
+
+	var (
+		res     []string
+		tracked []any
+	)
+
+	for _, name := range names {
+		n, ok := counts[name]
+		if !ok {
+			continue
+		}
+
+		delete(counts, name)
+
+		upd, err := tx.ExecContext(ctx, `UPDATE media_objects SET refcount = refcount - ? WHERE name = ?`, n, name)
+		if err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		rows, err := upd.RowsAffected()
+		if err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		if rows == 0 {
+			res = append(res, name)
+			continue
+		}
+
+		tracked = append(tracked, name)
+	}
+
+	if len(tracked) != 0 {
+		q := `DELETE FROM media_objects WHERE name IN (` + placeholders(len(tracked)) + `) AND refcount <= 0 RETURNING name, variants`
+
+		deleted, err := releasedMediaObjects(ctx, tx, q, tracked)
+		if err != nil {
+			return nil, e.Wrap(fn, err)
+		}
+
+		res = append(res, deleted...)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, e.Wrap(fn, err)
+	}
+
+	return res, nil
+}
+
+// releasedMediaObjects returns the deleted files with their resized copies
+func releasedMediaObjects(ctx context.Context, tx *sql.Tx, q string, args []any) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var res []string
+
+	for rows.Next() {
+		var (
+			name     string
+			variants []byte
+		)
+
+		if err := rows.Scan(&name, &variants); err != nil {
+			return nil, err
+		}
+
+		res = append(res, name)
+
+		if variants == nil {
+			continue
+		}
+
+		var copies []models.MediaVariant
+
+		if err := json.Unmarshal(variants, &copies); err != nil {
+			return nil, err
+		}
+
+		for _, v := range copies {
+			res = append(res, v.Name)
+		}
+	}
+
+	return res, rows.Err()
+}
@@ -0,0 +1,174 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"project/internal/config"
+	"project/internal/models"
+	"project/internal/storage"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFtsQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "", want: ""},
+		{query: "  новости  ", want: `"новости"*`},
+		{query: "a b", want: `"a"* AND "b"*`},
+		{query: "a or b", want: `("a"* OR "b"*)`},
+		{query: "a or b c", want: `("a"* OR "b"*) AND "c"*`},
+		{query: "c a or b", want: `"c"* AND ("a"* OR "b"*)`},
+		{query: "a or b or c d", want: `("a"* OR "b"* OR "c"*) AND "d"*`},
+		{query: `"red cat" or dog -bird`, want: `("red cat" OR "dog"*) NOT "bird"*`},
+		{query: "or a", want: `"a"*`},
+		{query: "a or", want: `"a"*`},
+		{query: "-a", want: ""},
+		{query: `it's "a"*b`, want: `"it"* AND "s"* AND "a" AND "b"*`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := ftsQuery(tt.query); got != tt.want {
+				t.Errorf("ftsQuery(%q) = %s, want %s", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFtsQueryMatch(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(`CREATE VIRTUAL TABLE docs USING fts5(text)`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, text := range []string{"apple cherry", "banana cherry", "apple", "cherry", "banana bread"} {
+		if _, err := db.Exec(`INSERT INTO docs (text) VALUES (?)`, text); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "apple or banana cherry", want: []string{"apple cherry", "banana cherry"}},
+		{query: "cherry apple or banana", want: []string{"apple cherry", "banana cherry"}},
+		{query: "appl or bread -cherry", want: []string{"apple", "banana bread"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rows, err := db.Query(`SELECT text FROM docs WHERE docs MATCH ? ORDER BY rowid`, ftsQuery(tt.query))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+
+			var got []string
+
+			for rows.Next() {
+				var text string
+				if err := rows.Scan(&text); err != nil {
+					t.Fatal(err)
+				}
+
+				got = append(got, text)
+			}
+
+			if err := rows.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("%s matched %q, want %q", ftsQuery(tt.query), got, tt.want)
+			}
+		})
+	}
+}
+
+type nopOutbox struct{}
+
+func (nopOutbox) Wake() {}
+
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	cfg := &config.DB{Driver: storage.DriverSQLite, Path: filepath.Join(t.TempDir(), "news.db")}
+
+	s, err := New(context.Background(), cfg, "file://../../../migrations/sqlite", nopOutbox{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { s.db.Close() })
+
+	return s
+}
+
+func TestGetWebMessagesBeforeID(t *testing.T) {
+	s := newTestStorage(t)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Ids do not follow created_at: backfilled and polled news are older than their ids
+	hours := []int{5, 1, 4, 0, 3, 2, 6}
+
+	for i, h := range hours {
+		_, err := s.db.Exec(`INSERT INTO web_messages (group_name, text, created_at, type) VALUES (?, ?, ?, 'vk')`,
+			"g", fmt.Sprint(i), base.Add(time.Duration(h)*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page := func(beforeID int64) []int64 {
+		msgs, err := s.GetWebMessages(context.Background(), models.WebMessageFilter{Limit: 3, BeforeID: beforeID})
+		if err != nil && !errors.Is(err, storage.ErrNoRecordsFound) {
+			t.Fatal(err)
+		}
+
+		ids := make([]int64, 0, len(msgs))
+		for _, msg := range msgs {
+			ids = append(ids, msg.ID)
+		}
+
+		return ids
+	}
+
+	// Newest first by created_at: 7, 1, 3, 5, 6, 2, 4
+	var got []int64
+
+	for cursor, ids := int64(0), page(0); len(ids) != 0; ids = page(cursor) {
+		got = append(got, ids...)
+		cursor = ids[len(ids)-1]
+	}
+
+	if want := []int64{7, 1, 3, 5, 6, 2, 4}; !slices.Equal(got, want) {
+		t.Errorf("pages = %v, want %v", got, want)
+	}
+
+	// A deleted cursor falls back to the smaller ids
+	if _, err := s.db.Exec(`DELETE FROM web_messages WHERE id = 3`); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := page(3), []int64{1, 2}; !slices.Equal(got, want) {
+		t.Errorf("page before deleted 3 = %v, want %v", got, want)
+	}
+}
@@ -4,8 +4,12 @@ import (
 	"errors"
 )
 
+// Drivers of storage.driver
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
 var (
-	ErrNoRecordsFound     = errors.New("no records found")
-	ErrChannelAlreadyOpen = errors.New("channel already open")
-	ErrChannelNotFound    = errors.New("channel not found")
+	ErrNoRecordsFound = errors.New("no records found")
 )
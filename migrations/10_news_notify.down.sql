@@ -0,0 +1,63 @@
+CREATE OR REPLACE FUNCTION notify_insert_tg_group_msg()
+    RETURNS TRIGGER AS $$
+DECLARE
+    msg_json TEXT;
+BEGIN
+    -- Создаем JSON-объект с нужными полями
+    msg_json := json_build_object(
+            'group_name', (SELECT g.name FROM tg_groups g WHERE g.id = NEW.group_id),
+            'username', NEW.username,
+            'text', NEW.text,
+            'metadata', NEW.metadata,
+            'created_at', to_char(NEW.created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"')
+                )::text;
+
+    -- Отправляем уведомление с подготовленным JSON
+    PERFORM pg_notify('insert_tg_group_message', msg_json);
+
+    RETURN NEW;
+END;
+$$
+    LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_insert_tg_channel_msg()
+    RETURNS TRIGGER AS $$
+DECLARE
+    msg_json TEXT;
+BEGIN
+    -- Создаем JSON-объект с нужными полями
+    msg_json := json_build_object(
+            'channel_name', (SELECT g.name FROM tg_channels g WHERE g.id = NEW.channel_id),
+            'text', NEW.text,
+            'metadata', NEW.metadata,
+            'created_at', to_char(NEW.created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"')
+                )::text;
+
+    -- Отправляем уведомление с подготовленным JSON
+    PERFORM pg_notify('insert_tg_channel_message', msg_json);
+
+    RETURN NEW;
+END;
+$$
+    LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_insert_vk_msg()
+    RETURNS TRIGGER AS $$
+DECLARE
+    msg_json TEXT;
+BEGIN
+    -- Создаем JSON-объект с нужными полями
+    msg_json := json_build_object(
+            'group_name', (SELECT g.name FROM vk_groups g WHERE g.id = NEW.group_id),
+            'text', NEW.text,
+            'metadata', NEW.metadata,
+            'created_at', to_char(NEW.created_at AT TIME ZONE 'Europe/Moscow', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"')
+                )::text;
+
+    -- Отправляем уведомление с подготовленным JSON
+    PERFORM pg_notify('insert_vk_message', msg_json);
+
+    RETURN NEW;
+END;
+$$
+    LANGUAGE plpgsql;
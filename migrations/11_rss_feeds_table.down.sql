@@ -0,0 +1 @@
+DROP TABLE IF EXISTS rss_feeds CASCADE;
\ No newline at end of file
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS rss_feeds (
+    id      BIGSERIAL   PRIMARY KEY,
+    url     TEXT        UNIQUE NOT NULL,
+    title   TEXT        NOT NULL
+);
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS insert_rss_item_trigger ON rss_items CASCADE;
+
+DROP FUNCTION IF EXISTS notify_insert_rss_item();
+
+DROP TABLE IF EXISTS rss_items CASCADE;
@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS rss_items (
+    guid        TEXT        NOT NULL,
+    feed_id     BIGINT      NOT NULL,
+    title       TEXT        NOT NULL,
+    link        TEXT        NOT NULL,
+    text        TEXT        NOT NULL,
+    author      TEXT,
+    metadata    JSONB,
+    created_at  TIMESTAMP   DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (guid, feed_id),
+    FOREIGN KEY (feed_id)  REFERENCES rss_feeds (id)  ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE OR REPLACE FUNCTION notify_insert_rss_item()
+    RETURNS TRIGGER AS $$
+DECLARE
+    msg_json TEXT;
+BEGIN
+    msg_json := json_build_object(
+            'group_name', (SELECT f.title FROM rss_feeds f WHERE f.id = NEW.feed_id),
+            'title', NEW.title,
+            'link', NEW.link,
+            'text', NEW.text,
+            'metadata', NEW.metadata,
+            'created_at', to_char(NEW.created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+            'type', 'rss'
+                )::text;
+
+    PERFORM pg_notify('insert_news_message', msg_json);
+
+    RETURN NEW;
+END;
+$$
+    LANGUAGE plpgsql;
+
+CREATE TRIGGER insert_rss_item_trigger
+    AFTER INSERT ON rss_items
+    FOR EACH ROW
+EXECUTE PROCEDURE notify_insert_rss_item();
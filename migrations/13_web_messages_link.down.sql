@@ -0,0 +1,3 @@
+ALTER TABLE web_messages
+    DROP COLUMN IF EXISTS title,
+    DROP COLUMN IF EXISTS link;
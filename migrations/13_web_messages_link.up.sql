@@ -0,0 +1,3 @@
+ALTER TABLE web_messages
+    ADD COLUMN IF NOT EXISTS title  TEXT,
+    ADD COLUMN IF NOT EXISTS link   TEXT;
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS filter_rules (
+    id      BIGSERIAL   PRIMARY KEY,
+    scope   TEXT        NOT NULL,
+    rule    TEXT        NOT NULL,
+    value   TEXT        NOT NULL DEFAULT '',
+    UNIQUE (scope, rule, value)
+);
+
+-- Keywords that were hard-coded in group.isNewsMessage
+INSERT INTO filter_rules (scope, rule, value) VALUES
+    ('tg_group', 'include', 'новост'),
+    ('tg_group', 'include', 'событи'),
+    ('tg_group', 'include', 'информаци'),
+    ('tg_group', 'include', 'объявлени'),
+    ('tg_group', 'include', 'репортаж'),
+    ('tg_group', 'include', 'экстренное'),
+    ('tg_group', 'include', 'важн'),
+    ('tg_group', 'include', 'анализ'),
+    ('tg_group', 'include', 'news'),
+    ('tg_group', 'include', 'event'),
+    ('tg_group', 'include', 'information'),
+    ('tg_group', 'include', 'announcement'),
+    ('tg_group', 'include', 'report'),
+    ('tg_group', 'include', 'breaking'),
+    ('tg_group', 'include', 'urgent'),
+    ('tg_group', 'include', 'update')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,22 @@
+DROP TRIGGER IF EXISTS approve_tg_group_msg_trigger ON tg_group_messages;
+DROP TRIGGER IF EXISTS approve_tg_channel_msg_trigger ON tg_channel_messages;
+
+DROP TRIGGER IF EXISTS insert_tg_group_msg_trigger ON tg_group_messages;
+
+CREATE TRIGGER insert_tg_group_msg_trigger
+    AFTER INSERT ON tg_group_messages
+    FOR EACH ROW
+EXECUTE PROCEDURE notify_insert_tg_group_msg();
+
+DROP TRIGGER IF EXISTS insert_tg_channel_msg_trigger ON tg_channel_messages;
+
+CREATE TRIGGER insert_tg_channel_msg_trigger
+    AFTER INSERT ON tg_channel_messages
+    FOR EACH ROW
+EXECUTE PROCEDURE notify_insert_tg_channel_msg();
+
+ALTER TABLE tg_group_messages DROP COLUMN IF EXISTS status;
+ALTER TABLE tg_channel_messages DROP COLUMN IF EXISTS status;
+
+ALTER TABLE tg_groups DROP COLUMN IF EXISTS moderated;
+ALTER TABLE tg_channels DROP COLUMN IF EXISTS moderated;
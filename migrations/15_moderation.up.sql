@@ -0,0 +1,35 @@
+ALTER TABLE tg_groups ADD COLUMN IF NOT EXISTS moderated BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE tg_channels ADD COLUMN IF NOT EXISTS moderated BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- pending, approved, rejected
+ALTER TABLE tg_group_messages ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'approved';
+ALTER TABLE tg_channel_messages ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'approved';
+
+-- Только одобренные сообщения попадают в ленту
+DROP TRIGGER IF EXISTS insert_tg_group_msg_trigger ON tg_group_messages;
+
+CREATE TRIGGER insert_tg_group_msg_trigger
+    AFTER INSERT ON tg_group_messages
+    FOR EACH ROW
+    WHEN (NEW.status = 'approved')
+EXECUTE PROCEDURE notify_insert_tg_group_msg();
+
+CREATE TRIGGER approve_tg_group_msg_trigger
+    AFTER UPDATE OF status ON tg_group_messages
+    FOR EACH ROW
+    WHEN (OLD.status = 'pending' AND NEW.status = 'approved')
+EXECUTE PROCEDURE notify_insert_tg_group_msg();
+
+DROP TRIGGER IF EXISTS insert_tg_channel_msg_trigger ON tg_channel_messages;
+
+CREATE TRIGGER insert_tg_channel_msg_trigger
+    AFTER INSERT ON tg_channel_messages
+    FOR EACH ROW
+    WHEN (NEW.status = 'approved')
+EXECUTE PROCEDURE notify_insert_tg_channel_msg();
+
+CREATE TRIGGER approve_tg_channel_msg_trigger
+    AFTER UPDATE OF status ON tg_channel_messages
+    FOR EACH ROW
+    WHEN (OLD.status = 'pending' AND NEW.status = 'approved')
+EXECUTE PROCEDURE notify_insert_tg_channel_msg();
@@ -0,0 +1,94 @@
+DROP TRIGGER IF EXISTS delete_web_message_trigger ON web_messages;
+DROP FUNCTION IF EXISTS notify_delete_web_message();
+
+DROP TRIGGER IF EXISTS update_tg_group_msg_trigger ON tg_group_messages;
+DROP FUNCTION IF EXISTS notify_update_tg_group_msg();
+
+DROP TRIGGER IF EXISTS update_tg_channel_msg_trigger ON tg_channel_messages;
+DROP FUNCTION IF EXISTS notify_update_tg_channel_msg();
+
+CREATE OR REPLACE FUNCTION notify_insert_tg_group_msg()
+    RETURNS TRIGGER AS $$
+DECLARE
+    msg_json TEXT;
+BEGIN
+    msg_json := json_build_object(
+            'group_name', 'Группа: ' || (SELECT g.name FROM tg_groups g WHERE g.id = NEW.group_id),
+            'text', 'От: ' || NEW.username || E'\n\n' || NEW.text,
+            'metadata', NEW.metadata,
+            'created_at', to_char(NEW.created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+            'type', 'tg'
+                )::text;
+
+    PERFORM pg_notify('insert_news_message', msg_json);
+
+    RETURN NEW;
+END;
+$$
+    LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_insert_tg_channel_msg()
+    RETURNS TRIGGER AS $$
+DECLARE
+    msg_json TEXT;
+BEGIN
+    msg_json := json_build_object(
+            'group_name', 'Канал: ' || (SELECT g.name FROM tg_channels g WHERE g.id = NEW.channel_id),
+            'text', NEW.text,
+            'metadata', NEW.metadata,
+            'created_at', to_char(NEW.created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+            'type', 'tg'
+                )::text;
+
+    PERFORM pg_notify('insert_news_message', msg_json);
+
+    RETURN NEW;
+END;
+$$
+    LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_insert_vk_msg()
+    RETURNS TRIGGER AS $$
+DECLARE
+    msg_json TEXT;
+BEGIN
+    msg_json := json_build_object(
+            'group_name', (SELECT g.name FROM vk_groups g WHERE g.id = NEW.group_id),
+            'text', NEW.text,
+            'metadata', NEW.metadata,
+            'created_at', to_char(NEW.created_at AT TIME ZONE 'Europe/Moscow', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+            'type', 'vk'
+                )::text;
+
+    PERFORM pg_notify('insert_news_message', msg_json);
+
+    RETURN NEW;
+END;
+$$
+    LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_insert_rss_item()
+    RETURNS TRIGGER AS $$
+DECLARE
+    msg_json TEXT;
+BEGIN
+    msg_json := json_build_object(
+            'group_name', (SELECT f.title FROM rss_feeds f WHERE f.id = NEW.feed_id),
+            'title', NEW.title,
+            'link', NEW.link,
+            'text', NEW.text,
+            'metadata', NEW.metadata,
+            'created_at', to_char(NEW.created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+            'type', 'rss'
+                )::text;
+
+    PERFORM pg_notify('insert_news_message', msg_json);
+
+    RETURN NEW;
+END;
+$$
+    LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS web_messages_source_ref_idx;
+
+ALTER TABLE web_messages DROP COLUMN IF EXISTS source_ref;
@@ -0,0 +1,91 @@
+DROP TRIGGER IF EXISTS update_tg_group_msg_trigger ON tg_group_messages;
+
+CREATE TRIGGER update_tg_group_msg_trigger
+    AFTER UPDATE OF text, metadata ON tg_group_messages
+    FOR EACH ROW
+    WHEN (NEW.status = 'approved' AND OLD.status = 'approved')
+EXECUTE PROCEDURE notify_update_tg_group_msg();
+
+DROP TRIGGER IF EXISTS update_tg_channel_msg_trigger ON tg_channel_messages;
+
+CREATE TRIGGER update_tg_channel_msg_trigger
+    AFTER UPDATE OF text, metadata ON tg_channel_messages
+    FOR EACH ROW
+    WHEN (NEW.status = 'approved' AND OLD.status = 'approved')
+EXECUTE PROCEDURE notify_update_tg_channel_msg();
+
+CREATE OR REPLACE FUNCTION notify_insert_tg_group_msg()
+    RETURNS TRIGGER AS $$
+DECLARE
+    msg_json TEXT;
+BEGIN
+    msg_json := json_build_object(
+            'source_ref', 'tg_group:' || NEW.group_id || ':' || NEW.msg_id,
+            'group_name', 'Группа: ' || (SELECT g.name FROM tg_groups g WHERE g.id = NEW.group_id),
+            'text', 'От: ' || NEW.username || E'\n\n' || NEW.text,
+            'metadata', NEW.metadata,
+            'created_at', to_char(NEW.created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+            'type', 'tg'
+                )::text;
+
+    PERFORM pg_notify('insert_news_message', msg_json);
+
+    RETURN NEW;
+END;
+$$
+    LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_insert_tg_channel_msg()
+    RETURNS TRIGGER AS $$
+DECLARE
+    msg_json TEXT;
+BEGIN
+    msg_json := json_build_object(
+            'source_ref', 'tg_channel:' || NEW.channel_id || ':' || NEW.msg_id,
+            'group_name', 'Канал: ' || (SELECT g.name FROM tg_channels g WHERE g.id = NEW.channel_id),
+            'text', NEW.text,
+            'metadata', NEW.metadata,
+            'created_at', to_char(NEW.created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+            'type', 'tg'
+                )::text;
+
+    PERFORM pg_notify('insert_news_message', msg_json);
+
+    RETURN NEW;
+END;
+$$
+    LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_update_tg_group_msg()
+    RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('update_news_message', json_build_object(
+            'source_ref', 'tg_group:' || NEW.group_id || ':' || NEW.msg_id,
+            'text', 'От: ' || NEW.username || E'\n\n' || NEW.text,
+            'metadata', NEW.metadata
+                )::text);
+
+    RETURN NEW;
+END;
+$$
+    LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_update_tg_channel_msg()
+    RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('update_news_message', json_build_object(
+            'source_ref', 'tg_channel:' || NEW.channel_id || ':' || NEW.msg_id,
+            'text', NEW.text,
+            'metadata', NEW.metadata
+                )::text);
+
+    RETURN NEW;
+END;
+$$
+    LANGUAGE plpgsql;
+
+DROP FUNCTION IF EXISTS utf16_length(TEXT);
+
+ALTER TABLE web_messages DROP COLUMN IF EXISTS entities;
+ALTER TABLE tg_channel_messages DROP COLUMN IF EXISTS entities;
+ALTER TABLE tg_group_messages DROP COLUMN IF EXISTS entities;
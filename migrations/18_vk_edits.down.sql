@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS update_vk_msg_trigger ON vk_messages;
+DROP FUNCTION IF EXISTS notify_update_vk_msg();
+
+ALTER TABLE vk_messages DROP COLUMN IF EXISTS edited_at;
@@ -0,0 +1,21 @@
+ALTER TABLE vk_messages ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP;
+
+-- Правки постов VK
+CREATE OR REPLACE FUNCTION notify_update_vk_msg()
+    RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('update_news_message', json_build_object(
+            'source_ref', 'vk:' || NEW.group_id || ':' || NEW.msg_id,
+            'text', NEW.text,
+            'metadata', NEW.metadata
+                )::text);
+
+    RETURN NEW;
+END;
+$$
+    LANGUAGE plpgsql;
+
+CREATE TRIGGER update_vk_msg_trigger
+    AFTER UPDATE OF text, metadata ON vk_messages
+    FOR EACH ROW
+EXECUTE PROCEDURE notify_update_vk_msg();
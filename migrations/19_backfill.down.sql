@@ -0,0 +1,23 @@
+CREATE OR REPLACE FUNCTION notify_insert_vk_msg()
+    RETURNS TRIGGER AS $$
+DECLARE
+    msg_json TEXT;
+BEGIN
+    msg_json := json_build_object(
+            'source_ref', 'vk:' || NEW.group_id || ':' || NEW.msg_id,
+            'group_name', (SELECT g.name FROM vk_groups g WHERE g.id = NEW.group_id),
+            'text', NEW.text,
+            'metadata', NEW.metadata,
+            'created_at', to_char(NEW.created_at AT TIME ZONE 'Europe/Moscow', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+            'type', 'vk'
+                )::text;
+
+    PERFORM pg_notify('insert_news_message', msg_json);
+
+    RETURN NEW;
+END;
+$$
+    LANGUAGE plpgsql;
+
+ALTER TABLE web_messages DROP COLUMN IF EXISTS backfilled;
+ALTER TABLE vk_messages DROP COLUMN IF EXISTS backfilled;
@@ -0,0 +1 @@
+ALTER TABLE vk_groups DROP COLUMN IF EXISTS last_post_id;
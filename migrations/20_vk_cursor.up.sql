@@ -0,0 +1,7 @@
+-- id последнего обработанного поста, переживает перезапуск приложения
+ALTER TABLE vk_groups ADD COLUMN IF NOT EXISTS last_post_id BIGINT NOT NULL DEFAULT 0;
+
+UPDATE vk_groups g
+SET last_post_id = m.max_id
+FROM (SELECT group_id, MAX(msg_id) AS max_id FROM vk_messages WHERE NOT backfilled GROUP BY group_id) m
+WHERE m.group_id = g.id;
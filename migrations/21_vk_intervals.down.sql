@@ -0,0 +1,2 @@
+ALTER TABLE vk_groups DROP COLUMN IF EXISTS max_interval;
+ALTER TABLE vk_groups DROP COLUMN IF EXISTS min_interval;
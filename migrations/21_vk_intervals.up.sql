@@ -0,0 +1,3 @@
+-- Интервалы опроса группы в секундах, заданные командой /vk interval (NULL - из конфига)
+ALTER TABLE vk_groups ADD COLUMN IF NOT EXISTS min_interval INTEGER;
+ALTER TABLE vk_groups ADD COLUMN IF NOT EXISTS max_interval INTEGER;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS web_messages_search_idx;
+
+ALTER TABLE web_messages DROP COLUMN IF EXISTS search_vector;
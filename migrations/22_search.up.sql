@@ -0,0 +1,11 @@
+-- Полнотекстовый поиск по ленте, русская и английская морфология
+ALTER TABLE web_messages ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('russian', COALESCE(title, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+        setweight(to_tsvector('russian', COALESCE(group_name, '')), 'C') ||
+        setweight(to_tsvector('russian', text), 'B') ||
+        setweight(to_tsvector('english', text), 'B')
+        ) STORED;
+
+CREATE INDEX IF NOT EXISTS web_messages_search_idx ON web_messages USING GIN (search_vector);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS tg_group_messages_retention_idx;
+DROP INDEX IF EXISTS tg_channel_messages_retention_idx;
+DROP INDEX IF EXISTS vk_messages_retention_idx;
+DROP INDEX IF EXISTS rss_items_retention_idx;
@@ -0,0 +1,5 @@
+-- Поиск старых сообщений источника для политик хранения
+CREATE INDEX IF NOT EXISTS tg_group_messages_retention_idx ON tg_group_messages (group_id, created_at);
+CREATE INDEX IF NOT EXISTS tg_channel_messages_retention_idx ON tg_channel_messages (channel_id, created_at);
+CREATE INDEX IF NOT EXISTS vk_messages_retention_idx ON vk_messages (group_id, created_at);
+CREATE INDEX IF NOT EXISTS rss_items_retention_idx ON rss_items (feed_id, created_at);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS tg_media_files;
+
+DROP TABLE IF EXISTS media_objects;
@@ -0,0 +1,17 @@
+-- Медиа хранятся по SHA-256 содержимого, refcount - кол-во сообщений, ссылающихся на файл
+CREATE TABLE IF NOT EXISTS media_objects (
+    sha256          TEXT        PRIMARY KEY,
+    name            TEXT        UNIQUE NOT NULL,
+    url             TEXT        NOT NULL,
+    content_type    TEXT,
+    size            BIGINT      NOT NULL,
+    refcount        INTEGER     NOT NULL DEFAULT 0,
+    created_at      TIMESTAMP   DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Уже загруженные файлы Telegram по FileUniqueID, такие файлы не скачиваются повторно
+CREATE TABLE IF NOT EXISTS tg_media_files (
+    file_unique_id  TEXT    PRIMARY KEY,
+    sha256          TEXT    NOT NULL,
+    FOREIGN KEY (sha256)  REFERENCES media_objects (sha256)  ON DELETE CASCADE ON UPDATE CASCADE
+);
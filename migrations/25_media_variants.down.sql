@@ -0,0 +1,5 @@
+ALTER TABLE media_objects
+    DROP COLUMN IF EXISTS width,
+    DROP COLUMN IF EXISTS height,
+    DROP COLUMN IF EXISTS blurhash,
+    DROP COLUMN IF EXISTS variants;
@@ -0,0 +1,6 @@
+-- Размеры, blurhash и уменьшенные копии изображений
+ALTER TABLE media_objects
+    ADD COLUMN IF NOT EXISTS width      INTEGER,
+    ADD COLUMN IF NOT EXISTS height     INTEGER,
+    ADD COLUMN IF NOT EXISTS blurhash   TEXT,
+    ADD COLUMN IF NOT EXISTS variants   JSONB;
@@ -0,0 +1,209 @@
+CREATE OR REPLACE FUNCTION utf16_length(s TEXT)
+    RETURNS INTEGER AS $$
+SELECT COALESCE(SUM(CASE WHEN ascii(c) > 65535 THEN 2 ELSE 1 END), 0)::INTEGER
+FROM regexp_split_to_table(s, '') AS c;
+$$
+    LANGUAGE sql IMMUTABLE;
+
+CREATE OR REPLACE FUNCTION notify_insert_tg_group_msg()
+    RETURNS TRIGGER AS $$
+DECLARE
+    msg_json TEXT;
+BEGIN
+    msg_json := json_build_object(
+            'source_ref', 'tg_group:' || NEW.group_id || ':' || NEW.msg_id,
+            'group_name', 'Группа: ' || (SELECT g.name FROM tg_groups g WHERE g.id = NEW.group_id),
+            'text', 'От: ' || NEW.username || E'\n\n' || NEW.text,
+            'metadata', NEW.metadata,
+            'entities', NEW.entities,
+            'entities_offset', utf16_length('От: ' || NEW.username || E'\n\n'),
+            'created_at', to_char(NEW.created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+            'type', 'tg'
+                )::text;
+
+    PERFORM pg_notify('insert_news_message', msg_json);
+
+    RETURN NEW;
+END;
+$$
+    LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_insert_tg_channel_msg()
+    RETURNS TRIGGER AS $$
+DECLARE
+    msg_json TEXT;
+BEGIN
+    msg_json := json_build_object(
+            'source_ref', 'tg_channel:' || NEW.channel_id || ':' || NEW.msg_id,
+            'group_name', 'Канал: ' || (SELECT g.name FROM tg_channels g WHERE g.id = NEW.channel_id),
+            'text', NEW.text,
+            'metadata', NEW.metadata,
+            'entities', NEW.entities,
+            'entities_offset', 0,
+            'created_at', to_char(NEW.created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+            'type', 'tg'
+                )::text;
+
+    PERFORM pg_notify('insert_news_message', msg_json);
+
+    RETURN NEW;
+END;
+$$
+    LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_update_tg_group_msg()
+    RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('update_news_message', json_build_object(
+            'source_ref', 'tg_group:' || NEW.group_id || ':' || NEW.msg_id,
+            'text', 'От: ' || NEW.username || E'\n\n' || NEW.text,
+            'metadata', NEW.metadata,
+            'entities', NEW.entities,
+            'entities_offset', utf16_length('От: ' || NEW.username || E'\n\n')
+                )::text);
+
+    RETURN NEW;
+END;
+$$
+    LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_update_tg_channel_msg()
+    RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('update_news_message', json_build_object(
+            'source_ref', 'tg_channel:' || NEW.channel_id || ':' || NEW.msg_id,
+            'text', NEW.text,
+            'metadata', NEW.metadata,
+            'entities', NEW.entities,
+            'entities_offset', 0
+                )::text);
+
+    RETURN NEW;
+END;
+$$
+    LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_insert_vk_msg()
+    RETURNS TRIGGER AS $$
+DECLARE
+    msg_json TEXT;
+BEGIN
+    msg_json := json_build_object(
+            'source_ref', 'vk:' || NEW.group_id || ':' || NEW.msg_id,
+            'group_name', (SELECT g.name FROM vk_groups g WHERE g.id = NEW.group_id),
+            'text', NEW.text,
+            'metadata', NEW.metadata,
+            'created_at', to_char(NEW.created_at AT TIME ZONE 'Europe/Moscow', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+            'type', 'vk',
+            'backfilled', NEW.backfilled
+                )::text;
+
+    PERFORM pg_notify('insert_news_message', msg_json);
+
+    RETURN NEW;
+END;
+$$
+    LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_update_vk_msg()
+    RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('update_news_message', json_build_object(
+            'source_ref', 'vk:' || NEW.group_id || ':' || NEW.msg_id,
+            'text', NEW.text,
+            'metadata', NEW.metadata
+                )::text);
+
+    RETURN NEW;
+END;
+$$
+    LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_insert_rss_item()
+    RETURNS TRIGGER AS $$
+DECLARE
+    msg_json TEXT;
+BEGIN
+    msg_json := json_build_object(
+            'source_ref', 'rss:' || NEW.feed_id || ':' || NEW.guid,
+            'group_name', (SELECT f.title FROM rss_feeds f WHERE f.id = NEW.feed_id),
+            'title', NEW.title,
+            'link', NEW.link,
+            'text', NEW.text,
+            'metadata', NEW.metadata,
+            'created_at', to_char(NEW.created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+            'type', 'rss'
+                )::text;
+
+    PERFORM pg_notify('insert_news_message', msg_json);
+
+    RETURN NEW;
+END;
+$$
+    LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_delete_web_message()
+    RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('delete_news_message', json_build_object('id', OLD.id)::text);
+
+    RETURN OLD;
+END;
+$$
+    LANGUAGE plpgsql;
+
+CREATE TRIGGER insert_tg_group_msg_trigger
+    AFTER INSERT ON tg_group_messages
+    FOR EACH ROW
+    WHEN (NEW.status = 'approved')
+EXECUTE PROCEDURE notify_insert_tg_group_msg();
+
+CREATE TRIGGER approve_tg_group_msg_trigger
+    AFTER UPDATE OF status ON tg_group_messages
+    FOR EACH ROW
+    WHEN (OLD.status = 'pending' AND NEW.status = 'approved')
+EXECUTE PROCEDURE notify_insert_tg_group_msg();
+
+CREATE TRIGGER update_tg_group_msg_trigger
+    AFTER UPDATE OF text, metadata, entities ON tg_group_messages
+    FOR EACH ROW
+    WHEN (NEW.status = 'approved' AND OLD.status = 'approved')
+EXECUTE PROCEDURE notify_update_tg_group_msg();
+
+CREATE TRIGGER insert_tg_channel_msg_trigger
+    AFTER INSERT ON tg_channel_messages
+    FOR EACH ROW
+    WHEN (NEW.status = 'approved')
+EXECUTE PROCEDURE notify_insert_tg_channel_msg();
+
+CREATE TRIGGER approve_tg_channel_msg_trigger
+    AFTER UPDATE OF status ON tg_channel_messages
+    FOR EACH ROW
+    WHEN (OLD.status = 'pending' AND NEW.status = 'approved')
+EXECUTE PROCEDURE notify_insert_tg_channel_msg();
+
+CREATE TRIGGER update_tg_channel_msg_trigger
+    AFTER UPDATE OF text, metadata, entities ON tg_channel_messages
+    FOR EACH ROW
+    WHEN (NEW.status = 'approved' AND OLD.status = 'approved')
+EXECUTE PROCEDURE notify_update_tg_channel_msg();
+
+CREATE TRIGGER insert_vk_msg_trigger
+    AFTER INSERT ON vk_messages
+    FOR EACH ROW
+EXECUTE PROCEDURE notify_insert_vk_msg();
+
+CREATE TRIGGER update_vk_msg_trigger
+    AFTER UPDATE OF text, metadata ON vk_messages
+    FOR EACH ROW
+EXECUTE PROCEDURE notify_update_vk_msg();
+
+CREATE TRIGGER insert_rss_item_trigger
+    AFTER INSERT ON rss_items
+    FOR EACH ROW
+EXECUTE PROCEDURE notify_insert_rss_item();
+
+CREATE TRIGGER delete_web_message_trigger
+    AFTER DELETE ON web_messages
+    FOR EACH ROW
+EXECUTE PROCEDURE notify_delete_web_message();
@@ -0,0 +1,24 @@
+-- События ленты публикует приложение после записи, триггеры pg_notify больше не нужны
+-- Время новостей в событиях приложения всегда UTC. Триггер VK переводил created_at в Europe/Moscow,
+-- но оставлял суффикс Z, поэтому время VK новостей в событиях меняется на 3 часа.
+-- Откат восстанавливает прежние триггеры вместе с этим поведением
+DROP TRIGGER IF EXISTS insert_tg_group_msg_trigger ON tg_group_messages;
+DROP TRIGGER IF EXISTS approve_tg_group_msg_trigger ON tg_group_messages;
+DROP TRIGGER IF EXISTS update_tg_group_msg_trigger ON tg_group_messages;
+DROP TRIGGER IF EXISTS insert_tg_channel_msg_trigger ON tg_channel_messages;
+DROP TRIGGER IF EXISTS approve_tg_channel_msg_trigger ON tg_channel_messages;
+DROP TRIGGER IF EXISTS update_tg_channel_msg_trigger ON tg_channel_messages;
+DROP TRIGGER IF EXISTS insert_vk_msg_trigger ON vk_messages;
+DROP TRIGGER IF EXISTS update_vk_msg_trigger ON vk_messages;
+DROP TRIGGER IF EXISTS insert_rss_item_trigger ON rss_items;
+DROP TRIGGER IF EXISTS delete_web_message_trigger ON web_messages;
+
+DROP FUNCTION IF EXISTS notify_insert_tg_group_msg();
+DROP FUNCTION IF EXISTS notify_update_tg_group_msg();
+DROP FUNCTION IF EXISTS notify_insert_tg_channel_msg();
+DROP FUNCTION IF EXISTS notify_update_tg_channel_msg();
+DROP FUNCTION IF EXISTS notify_insert_vk_msg();
+DROP FUNCTION IF EXISTS notify_update_vk_msg();
+DROP FUNCTION IF EXISTS notify_insert_rss_item();
+DROP FUNCTION IF EXISTS notify_delete_web_message();
+DROP FUNCTION IF EXISTS utf16_length(TEXT);
@@ -0,0 +1,11 @@
+-- Изменения ленты пишутся в одной транзакции с сообщением источника и новостью,
+-- relay доставляет их подписчикам и удаляет после доставки.
+-- claimed_until - до этого времени запись занята одним relay, после - доставляется повторно
+CREATE TABLE IF NOT EXISTS news_outbox (
+    id              BIGSERIAL   PRIMARY KEY,
+    event           TEXT        NOT NULL,
+    news_id         BIGINT      NOT NULL,
+    news            JSONB,
+    created_at      TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    claimed_until   TIMESTAMP
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS web_messages_state;
+
+ALTER TABLE web_messages DROP COLUMN IF EXISTS updated_at;
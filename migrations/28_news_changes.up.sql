@@ -0,0 +1,10 @@
+-- Время правки новости и последнего удаления из ленты, по ним лента отдаёт Last-Modified.
+-- Новости, добавленные до миграции, считаются изменёнными в момент создания
+ALTER TABLE web_messages ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS web_messages_state (
+    id          INTEGER     PRIMARY KEY CHECK (id = 1),
+    deleted_at  TIMESTAMP
+);
+
+INSERT INTO web_messages_state (id) VALUES (1) ON CONFLICT DO NOTHING;
@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS tg_media_files;
+DROP TABLE IF EXISTS media_objects;
+DROP TABLE IF EXISTS filter_rules;
+DROP TABLE IF EXISTS web_messages_fts;
+DROP TABLE IF EXISTS web_messages;
+DROP TABLE IF EXISTS rss_items;
+DROP TABLE IF EXISTS rss_feeds;
+DROP TABLE IF EXISTS vk_messages;
+DROP TABLE IF EXISTS vk_groups;
+DROP TABLE IF EXISTS tg_channel_messages;
+DROP TABLE IF EXISTS tg_channels;
+DROP TABLE IF EXISTS tg_group_messages;
+DROP TABLE IF EXISTS tg_groups;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS roles;
@@ -0,0 +1,199 @@
+-- Схема SQLite соответствует миграциям Postgres 01-25 одним файлом.
+-- События ленты пишутся в news_outbox в одной транзакции с сообщением (02_news_outbox).
+
+CREATE TABLE IF NOT EXISTS roles (
+    id      INTEGER     PRIMARY KEY,
+    name    TEXT        UNIQUE
+);
+
+INSERT INTO roles (id, name) VALUES
+    (0, 'system'),
+    (1, 'sub user'),
+    (2, 'admin');
+
+CREATE TABLE IF NOT EXISTS users (
+    id          INTEGER     PRIMARY KEY,
+    username    TEXT        UNIQUE,
+    first_name  TEXT,
+    last_name   TEXT,
+    role_id     INTEGER     NOT NULL,
+    FOREIGN KEY (role_id)   REFERENCES roles (id)   ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS tg_groups (
+    id              INTEGER     PRIMARY KEY,
+    name            TEXT        NOT NULL,
+    description     TEXT,
+    moderated       INTEGER     NOT NULL DEFAULT 0
+);
+
+-- pending, approved, rejected
+CREATE TABLE IF NOT EXISTS tg_group_messages (
+    msg_id      INTEGER     NOT NULL,
+    group_id    INTEGER     NOT NULL,
+    username    TEXT        NOT NULL,
+    text        TEXT        NOT NULL,
+    metadata    TEXT,
+    entities    TEXT,
+    status      TEXT        NOT NULL DEFAULT 'approved',
+    created_at  TIMESTAMP   NOT NULL,
+    PRIMARY KEY (msg_id, group_id),
+    FOREIGN KEY (group_id)  REFERENCES tg_groups (id)  ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS tg_group_messages_retention_idx ON tg_group_messages (group_id, created_at);
+
+CREATE TABLE IF NOT EXISTS tg_channels (
+    id              INTEGER     PRIMARY KEY,
+    name            TEXT        NOT NULL,
+    description     TEXT,
+    moderated       INTEGER     NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS tg_channel_messages (
+    msg_id      INTEGER     NOT NULL,
+    channel_id  INTEGER     NOT NULL,
+    text        TEXT        NOT NULL,
+    metadata    TEXT,
+    entities    TEXT,
+    status      TEXT        NOT NULL DEFAULT 'approved',
+    created_at  TIMESTAMP   NOT NULL,
+    PRIMARY KEY (msg_id, channel_id),
+    FOREIGN KEY (channel_id)  REFERENCES tg_channels (id)  ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS tg_channel_messages_retention_idx ON tg_channel_messages (channel_id, created_at);
+
+-- last_post_id - id последнего обработанного поста, интервалы опроса в секундах (NULL - из конфига)
+CREATE TABLE IF NOT EXISTS vk_groups (
+    id              INTEGER     PRIMARY KEY,
+    name            TEXT        NOT NULL,
+    domain          TEXT        NOT NULL,
+    last_post_id    INTEGER     NOT NULL DEFAULT 0,
+    min_interval    INTEGER,
+    max_interval    INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS vk_messages (
+    msg_id      INTEGER     NOT NULL,
+    group_id    INTEGER     NOT NULL,
+    text        TEXT        NOT NULL,
+    metadata    TEXT,
+    created_at  TIMESTAMP   NOT NULL,
+    edited_at   TIMESTAMP,
+    backfilled  INTEGER     NOT NULL DEFAULT 0,
+    PRIMARY KEY (msg_id, group_id),
+    FOREIGN KEY (group_id)  REFERENCES vk_groups (id)  ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS vk_messages_retention_idx ON vk_messages (group_id, created_at);
+
+CREATE TABLE IF NOT EXISTS rss_feeds (
+    id      INTEGER     PRIMARY KEY AUTOINCREMENT,
+    url     TEXT        UNIQUE NOT NULL,
+    title   TEXT        NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS rss_items (
+    guid        TEXT        NOT NULL,
+    feed_id     INTEGER     NOT NULL,
+    title       TEXT        NOT NULL,
+    link        TEXT        NOT NULL,
+    text        TEXT        NOT NULL,
+    author      TEXT,
+    metadata    TEXT,
+    created_at  TIMESTAMP   NOT NULL,
+    PRIMARY KEY (guid, feed_id),
+    FOREIGN KEY (feed_id)  REFERENCES rss_feeds (id)  ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS rss_items_retention_idx ON rss_items (feed_id, created_at);
+
+-- AUTOINCREMENT: id удалённых новостей не переиспользуются, клиенты догружают ленту по id
+CREATE TABLE IF NOT EXISTS web_messages (
+    id          INTEGER     PRIMARY KEY AUTOINCREMENT,
+    group_name  TEXT        NOT NULL,
+    title       TEXT,
+    link        TEXT,
+    text        TEXT        NOT NULL,
+    metadata    TEXT,
+    entities    TEXT,
+    created_at  TIMESTAMP   NOT NULL,
+    type        TEXT,
+    source_ref  TEXT        UNIQUE,
+    backfilled  INTEGER     NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS web_messages_created_at_idx ON web_messages (created_at, id);
+
+-- Полнотекстовый поиск по ленте, без морфологии: слова ищутся по началу
+CREATE VIRTUAL TABLE IF NOT EXISTS web_messages_fts USING fts5(
+    title, group_name, text,
+    content = 'web_messages',
+    content_rowid = 'id',
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS web_messages_fts_insert AFTER INSERT ON web_messages BEGIN
+    INSERT INTO web_messages_fts (rowid, title, group_name, text)
+    VALUES (NEW.id, COALESCE(NEW.title, ''), NEW.group_name, NEW.text);
+END;
+
+CREATE TRIGGER IF NOT EXISTS web_messages_fts_delete AFTER DELETE ON web_messages BEGIN
+    INSERT INTO web_messages_fts (web_messages_fts, rowid, title, group_name, text)
+    VALUES ('delete', OLD.id, COALESCE(OLD.title, ''), OLD.group_name, OLD.text);
+END;
+
+CREATE TRIGGER IF NOT EXISTS web_messages_fts_update AFTER UPDATE OF title, group_name, text ON web_messages BEGIN
+    INSERT INTO web_messages_fts (web_messages_fts, rowid, title, group_name, text)
+    VALUES ('delete', OLD.id, COALESCE(OLD.title, ''), OLD.group_name, OLD.text);
+    INSERT INTO web_messages_fts (rowid, title, group_name, text)
+    VALUES (NEW.id, COALESCE(NEW.title, ''), NEW.group_name, NEW.text);
+END;
+
+CREATE TABLE IF NOT EXISTS filter_rules (
+    id      INTEGER     PRIMARY KEY AUTOINCREMENT,
+    scope   TEXT        NOT NULL,
+    rule    TEXT        NOT NULL,
+    value   TEXT        NOT NULL DEFAULT '',
+    UNIQUE (scope, rule, value)
+);
+
+INSERT OR IGNORE INTO filter_rules (scope, rule, value) VALUES
+    ('tg_group', 'include', 'новост'),
+    ('tg_group', 'include', 'событи'),
+    ('tg_group', 'include', 'информаци'),
+    ('tg_group', 'include', 'объявлени'),
+    ('tg_group', 'include', 'репортаж'),
+    ('tg_group', 'include', 'экстренное'),
+    ('tg_group', 'include', 'важн'),
+    ('tg_group', 'include', 'анализ'),
+    ('tg_group', 'include', 'news'),
+    ('tg_group', 'include', 'event'),
+    ('tg_group', 'include', 'information'),
+    ('tg_group', 'include', 'announcement'),
+    ('tg_group', 'include', 'report'),
+    ('tg_group', 'include', 'breaking'),
+    ('tg_group', 'include', 'urgent'),
+    ('tg_group', 'include', 'update');
+
+-- Медиа хранятся по SHA-256 содержимого, refcount - кол-во сообщений, ссылающихся на файл
+CREATE TABLE IF NOT EXISTS media_objects (
+    sha256          TEXT        PRIMARY KEY,
+    name            TEXT        UNIQUE NOT NULL,
+    url             TEXT        NOT NULL,
+    content_type    TEXT,
+    size            INTEGER     NOT NULL,
+    refcount        INTEGER     NOT NULL DEFAULT 0,
+    width           INTEGER,
+    height          INTEGER,
+    blurhash        TEXT,
+    variants        TEXT,
+    created_at      TIMESTAMP   DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS tg_media_files (
+    file_unique_id  TEXT    PRIMARY KEY,
+    sha256          TEXT    NOT NULL,
+    FOREIGN KEY (sha256)  REFERENCES media_objects (sha256)  ON DELETE CASCADE ON UPDATE CASCADE
+);
@@ -0,0 +1,10 @@
+-- Изменения ленты пишутся в одной транзакции с сообщением источника и новостью,
+-- relay доставляет их подписчикам и удаляет после доставки
+CREATE TABLE IF NOT EXISTS news_outbox (
+    id              INTEGER     PRIMARY KEY AUTOINCREMENT,
+    event           TEXT        NOT NULL,
+    news_id         INTEGER     NOT NULL,
+    news            TEXT,
+    created_at      TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    claimed_until   TIMESTAMP
+);